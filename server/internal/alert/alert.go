@@ -0,0 +1,215 @@
+// Package alert records emergency squawks and SPI flags seen during ingestion
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
+)
+
+const (
+	TypeSquawk = "squawk"
+	TypeSPI    = "spi"
+)
+
+// emergencySquawks are hijack, radio failure and general emergency
+var emergencySquawks = map[string]bool{"7500": true, "7600": true, "7700": true}
+
+const (
+	// trackBefore is how much history is attached when an alert is created
+	trackBefore = 15 * time.Minute
+	// trackAfter keeps extending the track after the condition was last seen.
+	// A new alert is only created once this has passed.
+	trackAfter = 10 * time.Minute
+)
+
+type Querier interface {
+	GetRecentTrack(ctx context.Context, arg repository.GetRecentTrackParams) ([]repository.GetRecentTrackRow, error)
+	InsertAlert(ctx context.Context, arg repository.InsertAlertParams) (int32, error)
+	UpdateAlertTrack(ctx context.Context, arg repository.UpdateAlertTrackParams) error
+}
+
+type publisher interface {
+	Publish(ctx context.Context, event string, data any)
+}
+
+type TrackPoint struct {
+	Time     time.Time `json:"time"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Altitude *float64  `json:"altitude"`
+}
+
+// Emergency is the data published for emergency squawk alerts
+type Emergency struct {
+	AlertID  int32     `json:"alert_id"`
+	Icao24   string    `json:"icao24"`
+	Callsign string    `json:"callsign"`
+	Squawk   string    `json:"squawk"`
+	Time     time.Time `json:"time"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Altitude *float64  `json:"altitude"`
+}
+
+type key struct {
+	icao24    string
+	alertType string
+}
+
+type active struct {
+	id       int32
+	track    []TrackPoint
+	lastSeen time.Time
+}
+
+// Detector creates an alert the first time an aircraft squawks an emergency
+// code or sets SPI, then keeps extending its track while it stays in range
+type Detector struct {
+	Queries   Querier
+	Publisher publisher
+
+	mu     sync.Mutex
+	active map[key]*active
+}
+
+func NewDetector(queries Querier) *Detector {
+	return &Detector{
+		Queries: queries,
+		active:  map[key]*active{},
+	}
+}
+
+// AfterPoll implements opensky.PollHook
+func (d *Detector) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, p := range positions {
+		if !p.Icao24.Valid || !p.Latitude.Valid || !p.Longitude.Valid {
+			continue
+		}
+
+		point := TrackPoint{
+			Time: time.Unix(int64(p.ToTimestamp), 0).UTC(),
+			Lat:  p.Latitude.Float64,
+			Lon:  p.Longitude.Float64,
+		}
+		if p.BaroAltitude.Valid {
+			point.Altitude = &p.BaroAltitude.Float64
+		}
+
+		conditions := map[string]bool{
+			TypeSquawk: emergencySquawks[p.Squawk.String],
+			TypeSPI:    p.Spi.Valid && p.Spi.Bool,
+		}
+
+		for alertType, triggered := range conditions {
+			k := key{icao24: p.Icao24.String, alertType: alertType}
+			a, ok := d.active[k]
+
+			if ok && point.Time.Sub(a.lastSeen) <= trackAfter {
+				if triggered {
+					a.lastSeen = point.Time
+				}
+				a.track = append(a.track, point)
+				d.updateTrack(ctx, a)
+				continue
+			}
+
+			delete(d.active, k)
+			if triggered {
+				d.create(ctx, k, p, point)
+			}
+		}
+	}
+
+	cutoff := time.Now().Add(-trackAfter)
+	for k, a := range d.active {
+		if a.lastSeen.Before(cutoff) {
+			delete(d.active, k)
+		}
+	}
+}
+
+func (d *Detector) create(ctx context.Context, k key, p repository.InsertPositionParams, point TrackPoint) {
+	rows, err := d.Queries.GetRecentTrack(ctx, repository.GetRecentTrackParams{
+		Icao24: p.Icao24,
+		Since:  point.Time.Add(-trackBefore),
+	})
+	if err != nil {
+		log.Printf("alert track fetch failed: %v", err)
+	}
+
+	track := make([]TrackPoint, 0, len(rows)+1)
+	for _, row := range rows {
+		if !row.Latitude.Valid || !row.Longitude.Valid {
+			continue
+		}
+		// the triggering fix is already stored and appended below
+		if !row.TimePosition.Before(point.Time) {
+			continue
+		}
+
+		tp := TrackPoint{
+			Time: row.TimePosition,
+			Lat:  row.Latitude.Float64,
+			Lon:  row.Longitude.Float64,
+		}
+		if row.BaroAltitude.Valid {
+			tp.Altitude = &row.BaroAltitude.Float64
+		}
+		track = append(track, tp)
+	}
+	track = append(track, point)
+
+	encoded, _ := json.Marshal(track)
+	id, err := d.Queries.InsertAlert(ctx, repository.InsertAlertParams{
+		Type:         sql.NullString{String: k.alertType, Valid: true},
+		Icao24:       p.Icao24,
+		Callsign:     p.Callsign,
+		Squawk:       p.Squawk,
+		TimePosition: point.Time,
+		Latitude:     p.Latitude,
+		Longitude:    p.Longitude,
+		BaroAltitude: p.BaroAltitude,
+		Track:        encoded,
+	})
+	if err != nil {
+		log.Printf("alert insert failed: %v", err)
+		return
+	}
+
+	d.active[k] = &active{id: id, track: track, lastSeen: point.Time}
+	log.Printf("%s alert %d for %s (squawk %s)", k.alertType, id, k.icao24, p.Squawk.String)
+
+	if d.Publisher != nil && k.alertType == TypeSquawk {
+		d.Publisher.Publish(ctx, webhook.EventEmergencySquawk, Emergency{
+			AlertID:  id,
+			Icao24:   p.Icao24.String,
+			Callsign: p.Callsign.String,
+			Squawk:   p.Squawk.String,
+			Time:     point.Time,
+			Lat:      point.Lat,
+			Lon:      point.Lon,
+			Altitude: point.Altitude,
+		})
+	}
+}
+
+func (d *Detector) updateTrack(ctx context.Context, a *active) {
+	encoded, _ := json.Marshal(a.track)
+	err := d.Queries.UpdateAlertTrack(ctx, repository.UpdateAlertTrackParams{
+		ID:    a.id,
+		Track: encoded,
+	})
+	if err != nil {
+		log.Printf("alert track update failed: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package alert_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/alert"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
+)
+
+type mockQueries struct {
+	history []repository.GetRecentTrackRow
+	alerts  []repository.InsertAlertParams
+	tracks  map[int32]json.RawMessage
+}
+
+func (m *mockQueries) GetRecentTrack(ctx context.Context, arg repository.GetRecentTrackParams) ([]repository.GetRecentTrackRow, error) {
+	return m.history, nil
+}
+
+func (m *mockQueries) InsertAlert(ctx context.Context, arg repository.InsertAlertParams) (int32, error) {
+	m.alerts = append(m.alerts, arg)
+	id := int32(len(m.alerts))
+	m.tracks[id] = arg.Track
+	return id, nil
+}
+
+func (m *mockQueries) UpdateAlertTrack(ctx context.Context, arg repository.UpdateAlertTrackParams) error {
+	m.tracks[arg.ID] = arg.Track
+	return nil
+}
+
+type mockPublisher struct {
+	events []string
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, event string, data any) {
+	m.events = append(m.events, event)
+}
+
+func position(squawk string, lat float64, t time.Time) repository.InsertPositionParams {
+	return repository.InsertPositionParams{
+		Icao24:      sql.NullString{String: "abc123", Valid: true},
+		Callsign:    sql.NullString{String: "FIN1", Valid: true},
+		ToTimestamp: float64(t.Unix()),
+		Latitude:    sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude:   sql.NullFloat64{Float64: 24.95, Valid: true},
+		Squawk:      sql.NullString{String: squawk, Valid: true},
+	}
+}
+
+func TestSquawkAlertWithTrack(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	mock := &mockQueries{
+		tracks: map[int32]json.RawMessage{},
+		history: []repository.GetRecentTrackRow{
+			{
+				TimePosition: now.Add(-time.Minute),
+				Latitude:     sql.NullFloat64{Float64: 60.20, Valid: true},
+				Longitude:    sql.NullFloat64{Float64: 24.95, Valid: true},
+			},
+			{
+				TimePosition: now,
+				Latitude:     sql.NullFloat64{Float64: 60.25, Valid: true},
+				Longitude:    sql.NullFloat64{Float64: 24.95, Valid: true},
+			},
+		},
+	}
+	pub := &mockPublisher{}
+	detector := alert.NewDetector(mock)
+	detector.Publisher = pub
+	ctx := context.Background()
+
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position("7700", 60.25, now)})
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position("7700", 60.28, now.Add(45*time.Second))})
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position("1000", 60.30, now.Add(90*time.Second))})
+
+	if len(mock.alerts) != 1 {
+		t.Fatalf("expected a single alert, got %d", len(mock.alerts))
+	}
+	if mock.alerts[0].Type.String != alert.TypeSquawk || mock.alerts[0].Squawk.String != "7700" {
+		t.Errorf("unexpected alert: %+v", mock.alerts[0])
+	}
+
+	var track []alert.TrackPoint
+	if err := json.Unmarshal(mock.tracks[1], &track); err != nil {
+		t.Fatal("invalid track JSON")
+	}
+	if len(track) != 4 || track[0].Lat != 60.20 || track[3].Lat != 60.30 {
+		t.Errorf("expected history plus following fixes, got %+v", track)
+	}
+
+	if len(pub.events) != 1 || pub.events[0] != webhook.EventEmergencySquawk {
+		t.Errorf("expected one emergency squawk event, got %v", pub.events)
+	}
+}
+
+func TestNormalSquawkIsIgnored(t *testing.T) {
+	mock := &mockQueries{tracks: map[int32]json.RawMessage{}}
+	detector := alert.NewDetector(mock)
+
+	detector.AfterPoll(context.Background(), []repository.InsertPositionParams{position("2000", 60.25, time.Now())})
+
+	if len(mock.alerts) != 0 {
+		t.Errorf("expected no alerts, got %d", len(mock.alerts))
+	}
+}
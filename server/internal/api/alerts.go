@@ -0,0 +1,83 @@
+// Package api's alerts endpoint lists emergency squawk and SPI alerts
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/alert"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type Alert struct {
+	ID       int32           `json:"id"`
+	Type     string          `json:"type"`
+	Icao24   string          `json:"icao24"`
+	Callsign string          `json:"callsign"`
+	Squawk   string          `json:"squawk"`
+	Time     time.Time       `json:"time"`
+	Lat      *float64        `json:"lat"`
+	Lon      *float64        `json:"lon"`
+	Altitude *float64        `json:"altitude"`
+	Track    json.RawMessage `json:"track"`
+}
+
+type AlertsQuerier interface {
+	ListAlerts(ctx context.Context, arg repository.ListAlertsParams) ([]repository.Alert, error)
+}
+
+func AlertsHandler(queries AlertsQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var alertType sql.NullString
+
+		if v := req.URL.Query().Get("type"); v != "" {
+			if v != alert.TypeSquawk && v != alert.TypeSPI {
+				http.Error(res, "invalid type", http.StatusBadRequest)
+				return
+			}
+			alertType = sql.NullString{String: v, Valid: true}
+		}
+
+		from, to, err := timeWindow(req, maxTimeWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := queries.ListAlerts(req.Context(), repository.ListAlertsParams{
+			Type:     alertType,
+			FromTime: from,
+			ToTime:   to,
+		})
+		if err != nil {
+			http.Error(res, "error fetching alerts", http.StatusInternalServerError)
+			return
+		}
+
+		alerts := make([]Alert, 0, len(rows))
+		for _, row := range rows {
+			a := Alert{
+				ID:       row.ID,
+				Type:     row.Type.String,
+				Icao24:   row.Icao24.String,
+				Callsign: row.Callsign.String,
+				Squawk:   row.Squawk.String,
+				Time:     row.TimePosition,
+				Track:    row.Track,
+			}
+			if row.Latitude.Valid && row.Longitude.Valid {
+				a.Lat, a.Lon = &row.Latitude.Float64, &row.Longitude.Float64
+			}
+			if row.BaroAltitude.Valid {
+				a.Altitude = &row.BaroAltitude.Float64
+			}
+			alerts = append(alerts, a)
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(alerts)
+	}
+}
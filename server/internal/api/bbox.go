@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+)
+
+// parseBBox reads a bbox=minLon,minLat,maxLon,maxLat parameter. A minLon
+// greater than maxLon is a box crossing the antimeridian.
+func parseBBox(s string) (opensky.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return opensky.BoundingBox{}, errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return opensky.BoundingBox{}, errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+		v[i] = parsed
+	}
+
+	box := opensky.BoundingBox{LonMin: v[0], LatMin: v[1], LonMax: v[2], LatMax: v[3]}
+	if box.LonMin < -180 || box.LonMax > 180 || box.LatMin < -90 || box.LatMax > 90 {
+		return opensky.BoundingBox{}, errors.New("bbox out of range")
+	}
+	if box.LatMin >= box.LatMax {
+		return opensky.BoundingBox{}, errors.New("bbox minLat must be below maxLat")
+	}
+	if box.LonMin == box.LonMax {
+		return opensky.BoundingBox{}, errors.New("bbox has no width")
+	}
+
+	return box, nil
+}
+
+func crossesAntimeridian(box opensky.BoundingBox) bool {
+	return box.LonMin > box.LonMax
+}
+
+// lonSpan is the width of the box in degrees of longitude
+func lonSpan(box opensky.BoundingBox) float64 {
+	if crossesAntimeridian(box) {
+		return box.LonMax + 360 - box.LonMin
+	}
+
+	return box.LonMax - box.LonMin
+}
+
+// bboxParams reads the optional bbox parameter as nullable query arguments.
+// Unset values do not filter.
+func bboxParams(req *http.Request) (latMin, latMax, lonMin, lonMax sql.NullFloat64, err error) {
+	v := req.URL.Query().Get("bbox")
+	if v == "" {
+		return latMin, latMax, lonMin, lonMax, nil
+	}
+
+	box, err := parseBBox(v)
+	if err != nil {
+		return latMin, latMax, lonMin, lonMax, err
+	}
+
+	return sql.NullFloat64{Float64: box.LatMin, Valid: true},
+		sql.NullFloat64{Float64: box.LatMax, Valid: true},
+		sql.NullFloat64{Float64: box.LonMin, Valid: true},
+		sql.NullFloat64{Float64: box.LonMax, Valid: true},
+		nil
+}
+
+// snapToBins widens a box out to whole 1/bin cells, so every cell it touches
+// is counted in full whether it comes from raw fixes or from the rollups.
+// Queries keep the lower edges and drop the upper ones.
+func snapToBins(latMin, latMax, lonMin, lonMax sql.NullFloat64, binSize int) (sql.NullFloat64, sql.NullFloat64, sql.NullFloat64, sql.NullFloat64) {
+	snap := func(v sql.NullFloat64, round func(float64) float64) sql.NullFloat64 {
+		if !v.Valid {
+			return v
+		}
+		return sql.NullFloat64{Float64: round(v.Float64*float64(binSize)) / float64(binSize), Valid: true}
+	}
+
+	return snap(latMin, math.Floor), snap(latMax, math.Ceil), snap(lonMin, math.Floor), snap(lonMax, math.Ceil)
+}
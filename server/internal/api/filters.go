@@ -0,0 +1,114 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	icao24Pattern   = regexp.MustCompile(`^[0-9a-f]{6}$`)
+	callsignPattern = regexp.MustCompile(`^[A-Z0-9]{1,8}$`)
+)
+
+// maxIcao24Filter bounds the size of the icao24 list
+const maxIcao24Filter = 100
+
+// heatmapFilter holds the attribute filters shared by the heatmap, tile and
+// raster endpoints. Unset fields do not filter.
+type heatmapFilter struct {
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+}
+
+// parseHeatmapFilter reads alt_min, alt_max (m), velocity_min, velocity_max
+// (m/s), vertical=climb|descend|level, callsign (prefix), icao24 (comma
+// separated) and origin_country
+func parseHeatmapFilter(req *http.Request) (heatmapFilter, error) {
+	query := req.URL.Query()
+	var f heatmapFilter
+	var err error
+
+	if f.AltMin, err = floatParam(query.Get("alt_min"), "alt_min"); err != nil {
+		return f, err
+	}
+	if f.AltMax, err = floatParam(query.Get("alt_max"), "alt_max"); err != nil {
+		return f, err
+	}
+	if f.AltMin.Valid && f.AltMax.Valid && f.AltMin.Float64 > f.AltMax.Float64 {
+		return f, errors.New("alt_min must not exceed alt_max")
+	}
+
+	if f.VelocityMin, err = floatParam(query.Get("velocity_min"), "velocity_min"); err != nil {
+		return f, err
+	}
+	if f.VelocityMax, err = floatParam(query.Get("velocity_max"), "velocity_max"); err != nil {
+		return f, err
+	}
+	if f.VelocityMin.Valid && f.VelocityMax.Valid && f.VelocityMin.Float64 > f.VelocityMax.Float64 {
+		return f, errors.New("velocity_min must not exceed velocity_max")
+	}
+
+	switch query.Get("vertical") {
+	case "":
+	case "climb":
+		f.VerticalSign = sql.NullInt32{Int32: 1, Valid: true}
+	case "descend":
+		f.VerticalSign = sql.NullInt32{Int32: -1, Valid: true}
+	case "level":
+		f.VerticalSign = sql.NullInt32{Int32: 0, Valid: true}
+	default:
+		return f, errors.New("vertical must be climb, descend or level")
+	}
+
+	if v := query.Get("callsign"); v != "" {
+		// restricted to letters and digits so it can't carry LIKE wildcards
+		prefix := strings.ToUpper(strings.TrimSpace(v))
+		if !callsignPattern.MatchString(prefix) {
+			return f, errors.New("invalid callsign")
+		}
+		f.CallsignPrefix = sql.NullString{String: prefix, Valid: true}
+	}
+
+	if v := query.Get("icao24"); v != "" {
+		list := strings.Split(strings.ToLower(v), ",")
+		if len(list) > maxIcao24Filter {
+			return f, errors.New("too many icao24 values")
+		}
+		for i, icao24 := range list {
+			list[i] = strings.TrimSpace(icao24)
+			if !icao24Pattern.MatchString(list[i]) {
+				return f, errors.New("invalid icao24: " + icao24)
+			}
+		}
+		f.Icao24List = sql.NullString{String: strings.Join(list, ","), Valid: true}
+	}
+
+	if v := strings.TrimSpace(query.Get("origin_country")); v != "" {
+		f.OriginCountry = sql.NullString{String: v, Valid: true}
+	}
+
+	return f, nil
+}
+
+func floatParam(v, name string) (sql.NullFloat64, error) {
+	if v == "" {
+		return sql.NullFloat64{}, nil
+	}
+
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return sql.NullFloat64{}, errors.New("invalid " + name)
+	}
+
+	return sql.NullFloat64{Float64: parsed, Valid: true}, nil
+}
@@ -0,0 +1,138 @@
+// Package api's flow endpoint returns the dominant heading and speed per cell
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/flow"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// defaultFlowWindow is used when no time window is given. Flows need more
+// fixes per cell than density does.
+const defaultFlowWindow = 24 * time.Hour
+
+// FlowCell is one arrow. Lat/Lon is the center of the cell, Heading the
+// circular mean in degrees from north and Dispersion the circular variance of
+// the headings, 0 when every fix agrees.
+type FlowCell struct {
+	Lat        float64  `json:"lat"`
+	Lon        float64  `json:"lon"`
+	Count      int64    `json:"count"`
+	Heading    float64  `json:"heading"`
+	Dispersion float64  `json:"dispersion"`
+	Velocity   *float64 `json:"velocity"`
+}
+
+type FlowQuerier interface {
+	GetFlowBins(ctx context.Context, arg repository.GetFlowBinsParams) ([]repository.GetFlowBinsRow, error)
+}
+
+// FlowHandler bins fixes like the heatmap and returns each cell's mean heading,
+// dispersion, mean velocity (m/s) and count. Cells without a mean heading,
+// where no fix reported one or the headings cancel out, are left out.
+func FlowHandler(queries FlowQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		binSize := binParam(req)
+		if binSize <= 0 {
+			http.Error(res, "invalid bin", http.StatusBadRequest)
+			return
+		}
+
+		from, to, err := timeWindow(req, defaultFlowWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		latMin, latMax, lonMin, lonMax, err := bboxParams(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := parseHeatmapFilter(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := queries.GetFlowBins(req.Context(), repository.GetFlowBinsParams{
+			BinSize:        sql.NullFloat64{Float64: float64(binSize), Valid: true},
+			FromTime:       from.Time,
+			ToTime:         to.Time,
+			LatMin:         latMin,
+			LatMax:         latMax,
+			LonMin:         lonMin,
+			LonMax:         lonMax,
+			AltMin:         filter.AltMin,
+			AltMax:         filter.AltMax,
+			VelocityMin:    filter.VelocityMin,
+			VelocityMax:    filter.VelocityMax,
+			VerticalSign:   filter.VerticalSign,
+			CallsignPrefix: filter.CallsignPrefix,
+			Icao24List:     filter.Icao24List,
+			OriginCountry:  filter.OriginCountry,
+		})
+		if err != nil {
+			http.Error(res, "error fetching flow", http.StatusInternalServerError)
+			return
+		}
+
+		half := 0.5 / float64(binSize)
+		cells := make([]FlowCell, 0, len(rows))
+		for _, row := range rows {
+			if !row.LatBin.Valid || !row.LonBin.Valid {
+				continue
+			}
+			heading, dispersion, ok := flow.Mean(row.SinSum.Float64, row.CosSum.Float64, row.Headings)
+			if !ok {
+				continue
+			}
+
+			c := FlowCell{
+				Lat:        row.LatBin.Float64 + half,
+				Lon:        row.LonBin.Float64 + half,
+				Count:      row.Count,
+				Heading:    heading,
+				Dispersion: dispersion,
+			}
+			if row.AvgVelocity.Valid {
+				c.Velocity = &row.AvgVelocity.Float64
+			}
+			cells = append(cells, c)
+		}
+
+		res.Header().Set("Vary", "Accept")
+		if wantsGeoJSON(req) {
+			res.Header().Set("Content-Type", "application/geo+json")
+			json.NewEncoder(res).Encode(flowFeatures(cells, binSize))
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(cells)
+	}
+}
+
+// flowFeatures turns each cell into a Point at its center, to be drawn as an
+// arrow rotated by heading
+func flowFeatures(cells []FlowCell, binSize int) geojson.FeatureCollection {
+	features := make([]geojson.Feature, 0, len(cells))
+	for _, c := range cells {
+		features = append(features, geojson.NewFeature(geojson.Geometry{Type: "Point", Coordinates: [2]float64{c.Lon, c.Lat}}, map[string]any{
+			"count":      c.Count,
+			"heading":    c.Heading,
+			"dispersion": c.Dispersion,
+			"velocity":   c.Velocity,
+			"bin":        binSize,
+		}))
+	}
+
+	return geojson.NewFeatureCollection(features)
+}
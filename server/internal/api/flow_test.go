@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/flow"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockFlowQueries struct {
+	args repository.GetFlowBinsParams
+}
+
+func (m *mockFlowQueries) GetFlowBins(ctx context.Context, args repository.GetFlowBinsParams) ([]repository.GetFlowBinsRow, error) {
+	m.args = args
+
+	// arrivals heading just either side of north, and a cell where they cancel out
+	northSin, northCos := flow.Sum([]float64{350, 10, 0})
+	opposite, oppositeCos := flow.Sum([]float64{90, 270})
+	return []repository.GetFlowBinsRow{
+		{
+			LatBin:      sql.NullFloat64{Float64: 60.25, Valid: true},
+			LonBin:      sql.NullFloat64{Float64: 24.75, Valid: true},
+			Count:       4,
+			Headings:    3,
+			SinSum:      sql.NullFloat64{Float64: northSin, Valid: true},
+			CosSum:      sql.NullFloat64{Float64: northCos, Valid: true},
+			AvgVelocity: sql.NullFloat64{Float64: 120, Valid: true},
+		},
+		{
+			LatBin:   sql.NullFloat64{Float64: 60.5, Valid: true},
+			LonBin:   sql.NullFloat64{Float64: 25, Valid: true},
+			Count:    2,
+			Headings: 2,
+			SinSum:   sql.NullFloat64{Float64: opposite, Valid: true},
+			CosSum:   sql.NullFloat64{Float64: oppositeCos, Valid: true},
+		},
+	}, nil
+}
+
+func TestFlowHandler(t *testing.T) {
+	mock := &mockFlowQueries{}
+	req := httptest.NewRequest("GET", "/api/flow?bin=40&vertical=descend", nil)
+	w := httptest.NewRecorder()
+
+	FlowHandler(mock)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if mock.args.BinSize.Float64 != 40 || mock.args.VerticalSign.Int32 != -1 {
+		t.Errorf("unexpected args: %+v", mock.args)
+	}
+	if d := mock.args.ToTime.Sub(mock.args.FromTime); d != defaultFlowWindow {
+		t.Errorf("expected the default window, got %v", d)
+	}
+
+	var cells []FlowCell
+	if err := json.NewDecoder(w.Body).Decode(&cells); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(cells) != 1 {
+		t.Fatalf("expected the cancelling cell to be left out, got %+v", cells)
+	}
+
+	c := cells[0]
+	// averaged as angles, 350 and 10 are north rather than south
+	if math.Min(c.Heading, 360-c.Heading) > 1e-9 {
+		t.Errorf("expected a northerly heading, got %v", c.Heading)
+	}
+	if c.Dispersion <= 0 || c.Dispersion > 0.05 {
+		t.Errorf("unexpected dispersion %v", c.Dispersion)
+	}
+	if c.Lat != 60.2625 || c.Lon != 24.7625 || c.Count != 4 || c.Velocity == nil || *c.Velocity != 120 {
+		t.Errorf("unexpected cell: %+v", c)
+	}
+}
+
+func TestFlowHandlerGeoJSON(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/flow?bin=40&format=geojson&from=2025-06-01T00:00:00Z&to=2025-06-01T06:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	mock := &mockFlowQueries{}
+	FlowHandler(mock)(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Fatalf("expected GeoJSON content type, got %q", ct)
+	}
+	if !mock.args.FromTime.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected from: %v", mock.args.FromTime)
+	}
+
+	var fc geojson.FeatureCollection
+	if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Geometry.Type != "Point" || fc.Features[0].Properties["velocity"] != float64(120) {
+		t.Fatalf("unexpected features: %+v", fc.Features)
+	}
+}
+
+func TestFlowHandlerRejectsBadParams(t *testing.T) {
+	for _, query := range []string{"bin=0", "from=yesterday", "bbox=1,2,3", "vertical=sideways"} {
+		w := httptest.NewRecorder()
+		FlowHandler(&mockFlowQueries{})(w, httptest.NewRequest("GET", "/api/flow?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package api's geofence endpoints manage polygon geofences and their events
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+const (
+	// defaultGeofenceEvents and maxGeofenceEvents bound the events returned
+	// per request, newest first
+	defaultGeofenceEvents = 500
+	maxGeofenceEvents     = 5000
+)
+
+type Geofence struct {
+	ID        int32           `json:"id"`
+	Name      string          `json:"name"`
+	Geometry  json.RawMessage `json:"geometry"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type GeofenceEvent struct {
+	ID         int32     `json:"id"`
+	GeofenceID int32     `json:"geofence_id"`
+	Icao24     string    `json:"icao24"`
+	Callsign   string    `json:"callsign"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Altitude   *float64  `json:"altitude"`
+}
+
+type GeofenceQuerier interface {
+	CreateGeofence(ctx context.Context, arg repository.CreateGeofenceParams) (repository.Geofence, error)
+	ListGeofences(ctx context.Context) ([]repository.Geofence, error)
+	GetGeofence(ctx context.Context, id int32) (repository.Geofence, error)
+	DeleteGeofence(ctx context.Context, id int32) (int64, error)
+	ListGeofenceEvents(ctx context.Context, arg repository.ListGeofenceEventsParams) ([]repository.GeofenceEvent, error)
+}
+
+func ListGeofencesHandler(queries GeofenceQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		rows, err := queries.ListGeofences(req.Context())
+		if err != nil {
+			http.Error(res, "error fetching geofences", http.StatusInternalServerError)
+			return
+		}
+
+		fences := make([]Geofence, 0, len(rows))
+		for _, row := range rows {
+			fences = append(fences, toGeofence(row))
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(fences)
+	}
+}
+
+func CreateGeofenceHandler(queries GeofenceQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var input Geofence
+		if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+			http.Error(res, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		if input.Name == "" {
+			http.Error(res, "name is required", http.StatusBadRequest)
+			return
+		}
+		if _, err := geojson.ParsePolygon(input.Geometry); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		row, err := queries.CreateGeofence(req.Context(), repository.CreateGeofenceParams{
+			Name:     sql.NullString{String: input.Name, Valid: true},
+			Geometry: input.Geometry,
+		})
+		if err != nil {
+			http.Error(res, "error creating geofence", http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(toGeofence(row))
+	}
+}
+
+func GetGeofenceHandler(queries GeofenceQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		row, err := queries.GetGeofence(req.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(res, "geofence not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, "error fetching geofence", http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(toGeofence(row))
+	}
+}
+
+func DeleteGeofenceHandler(queries GeofenceQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		deleted, err := queries.DeleteGeofence(req.Context(), id)
+		if err != nil {
+			http.Error(res, "error deleting geofence", http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			http.Error(res, "geofence not found", http.StatusNotFound)
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GeofenceEventsHandler returns a fence's newest entries and exits within the
+// time window, up to limit= of them
+func GeofenceEventsHandler(queries GeofenceQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		from, to, err := timeWindow(req, maxTimeWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultGeofenceEvents
+		if v := req.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > maxGeofenceEvents {
+				http.Error(res, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// an unknown fence has no events, but shouldn't look like a quiet one
+		_, err = queries.GetGeofence(req.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(res, "geofence not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, "error fetching geofence", http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := queries.ListGeofenceEvents(req.Context(), repository.ListGeofenceEventsParams{
+			GeofenceID: id,
+			FromTime:   from,
+			ToTime:     to,
+			RowLimit:   int32(limit),
+		})
+		if err != nil {
+			http.Error(res, "error fetching geofence events", http.StatusInternalServerError)
+			return
+		}
+
+		events := make([]GeofenceEvent, 0, len(rows))
+		for _, row := range rows {
+			event := GeofenceEvent{
+				ID:         row.ID,
+				GeofenceID: row.GeofenceID,
+				Icao24:     row.Icao24.String,
+				Callsign:   row.Callsign.String,
+				Event:      row.Event.String,
+				Time:       row.TimePosition,
+			}
+			if row.BaroAltitude.Valid {
+				event.Altitude = &row.BaroAltitude.Float64
+			}
+			events = append(events, event)
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(events)
+	}
+}
+
+func toGeofence(row repository.Geofence) Geofence {
+	return Geofence{
+		ID:        row.ID,
+		Name:      row.Name.String,
+		Geometry:  row.Geometry,
+		CreatedAt: row.CreatedAt,
+	}
+}
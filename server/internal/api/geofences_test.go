@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// mockGeofenceQueries knows fence 3 and records the events query
+type mockGeofenceQueries struct {
+	events *repository.ListGeofenceEventsParams
+}
+
+func (m *mockGeofenceQueries) CreateGeofence(ctx context.Context, arg repository.CreateGeofenceParams) (repository.Geofence, error) {
+	return repository.Geofence{}, nil
+}
+
+func (m *mockGeofenceQueries) ListGeofences(ctx context.Context) ([]repository.Geofence, error) {
+	return nil, nil
+}
+
+func (m *mockGeofenceQueries) GetGeofence(ctx context.Context, id int32) (repository.Geofence, error) {
+	if id != 3 {
+		return repository.Geofence{}, sql.ErrNoRows
+	}
+	return repository.Geofence{ID: 3}, nil
+}
+
+func (m *mockGeofenceQueries) DeleteGeofence(ctx context.Context, id int32) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockGeofenceQueries) ListGeofenceEvents(ctx context.Context, arg repository.ListGeofenceEventsParams) ([]repository.GeofenceEvent, error) {
+	m.events = &arg
+	return []repository.GeofenceEvent{{
+		ID:           1,
+		GeofenceID:   arg.GeofenceID,
+		Icao24:       sql.NullString{String: "461e1f", Valid: true},
+		Event:        sql.NullString{String: "enter", Valid: true},
+		TimePosition: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	}}, nil
+}
+
+func eventsRequest(id, query string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/geofences/"+id+"/events?"+query, nil)
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestGeofenceEventsHandler(t *testing.T) {
+	mock := &mockGeofenceQueries{}
+	w := httptest.NewRecorder()
+	GeofenceEventsHandler(mock)(w, eventsRequest("3", ""))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if mock.events == nil || mock.events.RowLimit != defaultGeofenceEvents || !mock.events.FromTime.Valid {
+		t.Fatalf("expected a bounded query, got %+v", mock.events)
+	}
+
+	var events []GeofenceEvent
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(events) != 1 || events[0].Event != "enter" || events[0].GeofenceID != 3 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	mock = &mockGeofenceQueries{}
+	GeofenceEventsHandler(mock)(httptest.NewRecorder(), eventsRequest("3", "limit=20"))
+	if mock.events == nil || mock.events.RowLimit != 20 {
+		t.Errorf("expected limit=20 to be used, got %+v", mock.events)
+	}
+}
+
+func TestGeofenceEventsHandlerRejects(t *testing.T) {
+	for _, tc := range []struct {
+		id, query string
+		code      int
+	}{
+		{"4", "", http.StatusNotFound},
+		{"x", "", http.StatusBadRequest},
+		{"3", "limit=0", http.StatusBadRequest},
+		{"3", "limit=5001", http.StatusBadRequest},
+		{"3", "limit=x", http.StatusBadRequest},
+	} {
+		mock := &mockGeofenceQueries{}
+		w := httptest.NewRecorder()
+		GeofenceEventsHandler(mock)(w, eventsRequest(tc.id, tc.query))
+
+		if w.Code != tc.code || mock.events != nil {
+			t.Errorf("%s %s: expected %d without listing events, got %d", tc.id, tc.query, tc.code, w.Code)
+		}
+	}
+}
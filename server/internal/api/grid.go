@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geo"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/rollup"
+)
+
+const (
+	// sourceBinsPerCell is how many square bins span a hexagon's edge. Bins
+	// go whole to the hexagon holding their center, which puts under 1% of
+	// the fixes in a neighbour at 32.
+	sourceBinsPerCell = 32
+	kmPerDegree       = 111.32
+
+	minCellM = 100
+	maxCellM = 50000
+
+	// maxSourceBins bounds the square bins a bbox spans when hexagons are finer
+	// than the rollups and are built from raw fixes
+	maxSourceBins = 4_000_000
+	// maxMetricCells bounds the metric cells a bbox spans, and cells smaller
+	// than minUnboundedCellM need a bbox at all
+	maxMetricCells    = 1_000_000
+	minUnboundedCellM = 5000
+)
+
+// heatmapGrid is the grid a heatmap is aggregated into. The zero value is the
+// 1/bin degree grid.
+type heatmapGrid struct {
+	h3    bool
+	res   int
+	cellM float64
+}
+
+// parseGrid reads grid=h3 with res=, or cell= in meters like 500m or 2km
+func parseGrid(req *http.Request) (heatmapGrid, error) {
+	query := req.URL.Query()
+
+	var grid heatmapGrid
+	switch query.Get("grid") {
+	case "", "square":
+	case "h3":
+		grid.h3, grid.res = true, defaultH3Res
+		if v := query.Get("res"); v != "" {
+			res, err := strconv.Atoi(v)
+			if err != nil || res < 0 || res > maxH3Res {
+				return grid, errors.New("invalid res")
+			}
+			grid.res = res
+		}
+	default:
+		return grid, errors.New("invalid grid")
+	}
+
+	if v := query.Get("cell"); v != "" {
+		if grid.h3 {
+			return grid, errors.New("cell can't be combined with grid=h3")
+		}
+
+		scale := 1.0
+		if strings.HasSuffix(v, "km") {
+			v, scale = strings.TrimSuffix(v, "km"), 1000
+		} else if strings.HasSuffix(v, "m") {
+			v = strings.TrimSuffix(v, "m")
+		} else {
+			return grid, errors.New("cell must be in m or km")
+		}
+		size, err := strconv.ParseFloat(v, 64)
+		if err != nil || size*scale < minCellM || size*scale > maxCellM {
+			return grid, errors.New("invalid cell")
+		}
+		grid.cellM = size * scale
+	}
+
+	return grid, nil
+}
+
+func (g heatmapGrid) square() bool {
+	return !g.h3 && g.cellM == 0
+}
+
+// metric reports whether the grid is ETRS-TM35FIN cells, which are counted
+// from projected fixes rather than square bins
+func (g heatmapGrid) metric() bool {
+	return g.cellM != 0
+}
+
+// sourceBin is the square bin hexagons are built from. Coarse hexagons use a
+// rollup bin when one is fine enough.
+func (g heatmapGrid) sourceBin() int {
+	needed := int(math.Ceil(kmPerDegree * sourceBinsPerCell / h3EdgeKm[g.res]))
+	for _, bin := range rollup.BinSizes {
+		if bin >= needed {
+			return bin
+		}
+	}
+	return needed
+}
+
+// checkSourceArea requires a bbox when the source bin is finer than every
+// rollup, and bounds how many source bins it spans
+func checkSourceArea(binSize int, latMin, latMax, lonMin, lonMax sql.NullFloat64) error {
+	if binSize <= slices.Max(rollup.BinSizes) {
+		return nil
+	}
+	if !latMin.Valid {
+		return errors.New("grid needs a bbox at this resolution")
+	}
+
+	box := opensky.BoundingBox{LatMin: latMin.Float64, LatMax: latMax.Float64, LonMin: lonMin.Float64, LonMax: lonMax.Float64}
+	if (box.LatMax-box.LatMin)*lonSpan(box)*float64(binSize)*float64(binSize) > maxSourceBins {
+		return errors.New("bbox too large for this grid")
+	}
+
+	return nil
+}
+
+// checkCellArea requires a bbox for small metric cells, and bounds how many
+// cells it spans
+func checkCellArea(cellM float64, latMin, latMax, lonMin, lonMax sql.NullFloat64) error {
+	if !latMin.Valid {
+		if cellM < minUnboundedCellM {
+			return errors.New("grid needs a bbox at this resolution")
+		}
+		return nil
+	}
+
+	box := opensky.BoundingBox{LatMin: latMin.Float64, LatMax: latMax.Float64, LonMin: lonMin.Float64, LonMax: lonMax.Float64}
+	heightKm := (box.LatMax - box.LatMin) * kmPerDegree
+	widthKm := lonSpan(box) * kmPerDegree * math.Cos((box.LatMin+box.LatMax)/2*math.Pi/180)
+	if heightKm*widthKm/(cellM/1000*cellM/1000) > maxMetricCells {
+		return errors.New("bbox too large for this grid")
+	}
+
+	return nil
+}
+
+// metricCells counts fixes in the ETRS-TM35FIN cells holding them. The
+// database projects every fix, so counts are exact up to the cell edges.
+func metricCells(ctx context.Context, queries HeatmapQuerier, params repository.GetHeatmapDataDynamicParams, cellM float64) ([]HeatPoint, error) {
+	rows, err := queries.GetMetricCells(ctx, repository.GetMetricCellsParams{
+		CellSize:       cellM,
+		FromTime:       params.FromTime,
+		ToTime:         params.ToTime,
+		LatMin:         params.LatMin,
+		LatMax:         params.LatMax,
+		LonMin:         params.LonMin,
+		LonMax:         params.LonMax,
+		AltMin:         params.AltMin,
+		AltMax:         params.AltMax,
+		VelocityMin:    params.VelocityMin,
+		VelocityMax:    params.VelocityMax,
+		VerticalSign:   params.VerticalSign,
+		CallsignPrefix: params.CallsignPrefix,
+		Icao24List:     params.Icao24List,
+		OriginCountry:  params.OriginCountry,
+		Hours:          params.Hours,
+		TimeZone:       params.TimeZone,
+		Weekdays:       params.Weekdays,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grid := geo.Grid{Projection: geo.TM35FIN, Size: cellM}
+	cells := make([]HeatPoint, 0, len(rows))
+	for _, row := range rows {
+		col, r := int(row.CellCol), int(row.CellRow)
+		lat, lon := grid.Center(col, r)
+		corners := grid.Corners(col, r)
+
+		cells = append(cells, HeatPoint{
+			Lat:      lat,
+			Lon:      lon,
+			Count:    row.Count,
+			Boundary: corners[:],
+		})
+	}
+
+	return cells, nil
+}
+
+// cellFeatures turns each cell of an H3 or metric grid into a Polygon
+func cellFeatures(cells []HeatPoint, grid heatmapGrid) geojson.FeatureCollection {
+	features := make([]geojson.Feature, 0, len(cells))
+	for _, c := range cells {
+		properties := map[string]any{"count": c.Count, "value": c.Value, "metric": c.Metric}
+		if grid.h3 {
+			properties["cell"] = c.Cell
+			properties["res"] = grid.res
+		} else {
+			properties["cell_size_m"] = grid.cellM
+		}
+
+		// GeoJSON rings are closed
+		ring := append(append([][2]float64{}, c.Boundary...), c.Boundary[0])
+		features = append(features, geojson.NewFeature(geojson.Geometry{Type: "Polygon", Coordinates: geojson.Polygon{ring}}, properties))
+	}
+
+	return geojson.NewFeatureCollection(features)
+}
@@ -0,0 +1,51 @@
+package api
+
+import "github.com/ChristianVilen/flight-heatmap/server/internal/h3"
+
+const (
+	// defaultH3Res has hexagons of about 5 km², close to the default square bin
+	defaultH3Res = 7
+	// maxH3Res bounds how fine the square bins feeding the hexagons get
+	maxH3Res = 10
+)
+
+// h3EdgeKm is the average hexagon edge length per resolution
+var h3EdgeKm = [maxH3Res + 1]float64{1107.71, 418.68, 158.24, 59.81, 22.61, 8.54, 3.23, 1.22, 0.461, 0.174, 0.0659}
+
+// h3Bins re-bins square bins into the hexagons containing their centers,
+// which sourceBin keeps fine enough to place nearly every fix correctly.
+// Hexagons carry counts only, as distinct aircraft can't be summed.
+func h3Bins(points []HeatPoint, binSize, res int) []HeatPoint {
+	half := 0.5 / float64(binSize)
+
+	cells := []HeatPoint{}
+	index := map[h3.Cell]int{}
+	for _, p := range points {
+		cell, err := h3.FromLatLng(p.Lat+half, p.Lon+half, res)
+		if err != nil {
+			continue
+		}
+		if i, ok := index[cell]; ok {
+			cells[i].Count += p.Count
+			continue
+		}
+
+		center := cell.LatLng()
+		boundary := cell.Boundary()
+		ring := make([][2]float64, 0, len(boundary)+1)
+		for _, v := range boundary {
+			ring = append(ring, [2]float64{v.Lng, v.Lat})
+		}
+
+		index[cell] = len(cells)
+		cells = append(cells, HeatPoint{
+			Lat:      center.Lat,
+			Lon:      center.Lng,
+			Count:    p.Count,
+			Cell:     cell.String(),
+			Boundary: ring,
+		})
+	}
+
+	return cells
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/h3"
+)
+
+// TestH3BinsMatchFixes compares the hexagon each fix's square bin goes to
+// with the hexagon holding the fix
+func TestH3BinsMatchFixes(t *testing.T) {
+	for _, res := range []int{5, 7, 9} {
+		grid := heatmapGrid{h3: true, res: res}
+		binSize := float64(grid.sourceBin())
+
+		// fixes spread over a few dozen hexagons around Helsinki-Vantaa
+		span := h3EdgeKm[res] * 10 / kmPerDegree
+		rng := rand.New(rand.NewSource(1))
+
+		const fixes = 5000
+		var total int64
+		misplaced := 0
+		for range fixes {
+			lat, lon := 60.3+rng.Float64()*span, 24.9+rng.Float64()*2*span
+			want, err := h3.FromLatLng(lat, lon, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// binned as the queries do
+			bin := HeatPoint{Lat: math.Floor(lat*binSize) / binSize, Lon: math.Floor(lon*binSize) / binSize, Count: 1}
+			cells := h3Bins([]HeatPoint{bin}, int(binSize), res)
+			if len(cells) != 1 {
+				t.Fatalf("res %d: expected one hexagon, got %+v", res, cells)
+			}
+			if cells[0].Cell != want.String() {
+				misplaced++
+			}
+			total += cells[0].Count
+		}
+
+		if total != fixes {
+			t.Errorf("res %d: expected every fix counted once, got %d", res, total)
+		}
+		if rate := float64(misplaced) / fixes; rate > 0.015 {
+			t.Errorf("res %d: %.1f%% of the fixes are in the wrong hexagon", res, rate*100)
+		}
+	}
+}
@@ -5,66 +5,256 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/tile"
 )
 
+// HeatPoint is one bin. Aircraft and the samples are only set when the
+// heatmap is binned from raw data, where they let the client drill down into
+// a cell; samples=1 makes sure it is. H3 hexagons and metric cells carry
+// their [lon, lat] boundary, with Lat/Lon at the center, and hexagons their
+// cell ID. Value is the requested metric, the count unless another one was
+// asked for. Hexagons are built from whole square bins, so their counts near
+// edges are approximate.
 type HeatPoint struct {
-	ID    int32   `json:"id"`
-	Lat   float64 `json:"lat"`
-	Lon   float64 `json:"lon"`
-	Count int64   `json:"count"`
+	Lat           float64      `json:"lat"`
+	Lon           float64      `json:"lon"`
+	Count         int64        `json:"count"`
+	Value         float64      `json:"value"`
+	Metric        string       `json:"metric"`
+	Aircraft      int64        `json:"aircraft,omitempty"`
+	SampleIDs     []int32      `json:"sample_ids,omitempty"`
+	SampleIcao24s []string     `json:"sample_icao24s,omitempty"`
+	Cell          string       `json:"cell,omitempty"`
+	Boundary      [][2]float64 `json:"boundary,omitempty"`
 }
 
+// heatmapSampleSize bounds the position IDs and icao24s returned per bin
+const heatmapSampleSize = 10
+
 type HeatmapQuerier interface {
+	RollupQuerier
 	GetHeatmapDataDynamic(ctx context.Context, args repository.GetHeatmapDataDynamicParams) ([]repository.GetHeatmapDataDynamicRow, error)
+	GetHeatmapValueBins(ctx context.Context, args repository.GetHeatmapValueBinsParams) ([]repository.GetHeatmapValueBinsRow, error)
+	GetMetricCells(ctx context.Context, args repository.GetMetricCellsParams) ([]repository.GetMetricCellsRow, error)
 }
 
-func HeatmapHandler(queries HeatmapQuerier) http.HandlerFunc {
+// HeatmapHandler bins positions for the heat layer. hours and weekdays
+// selectors are evaluated in timeZone. grid=h3&res=N aggregates into H3
+// hexagons and cell=500m into ETRS-TM35FIN squares instead of 1/bin degrees.
+// Hexagons are built from square bins, each going to the hexagon holding its
+// center, so their counts near edges are approximate; metric cells count
+// every fix where it was projected. Fine grids need a bbox.
+// metric= picks what the bins' value is. A bbox is widened to the whole bins
+// it touches.
+func HeatmapHandler(queries HeatmapQuerier, timeZone string) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		binSize := 80 // default bin granularity
-		var interval sql.NullString
+		binSize := binParam(req)
 
-		if v := req.URL.Query().Get("bin"); v != "" {
-			if parsed, err := strconv.Atoi(v); err == nil {
-				binSize = parsed
-			}
+		grid, err := parseGrid(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if grid.h3 {
+			binSize = grid.sourceBin()
 		}
 
-		// Only set interval if it's explicitly passed
-		if v := req.URL.Query().Get("minutes"); v != "" {
-			if parsed, err := strconv.Atoi(v); err == nil {
-				interval = sql.NullString{String: fmt.Sprintf("%d", parsed), Valid: true}
-			}
+		metric, err := parseMetric(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// averages and medians can't be merged into coarser cells
+		if metric != metricCount && !grid.square() {
+			http.Error(res, "metric needs the square grid", http.StatusBadRequest)
+			return
+		}
+
+		from, to, err := timeWindow(req, maxTimeWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := parseHeatmapFilter(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		hours, weekdays, err := parseTimeSelectors(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// only bin the viewport when the client sends one
+		latMin, latMax, lonMin, lonMax, err := bboxParams(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if grid.metric() {
+			err = checkCellArea(grid.cellM, latMin, latMax, lonMin, lonMax)
 		} else {
-			interval = sql.NullString{Valid: false} // explicitly invalid = no filtering
+			latMin, latMax, lonMin, lonMax = snapToBins(latMin, latMax, lonMin, lonMax, binSize)
+			if grid.h3 {
+				err = checkSourceArea(binSize, latMin, latMax, lonMin, lonMax)
+			}
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		raw, err := queries.GetHeatmapDataDynamic(req.Context(), repository.GetHeatmapDataDynamicParams{
-			BinSize:  sql.NullFloat64{Float64: float64(binSize), Valid: true},
-			Interval: interval,
-		})
+		params := repository.GetHeatmapDataDynamicParams{
+			BinSize:        sql.NullFloat64{Float64: float64(binSize), Valid: true},
+			SampleSize:     heatmapSampleSize,
+			FromTime:       from,
+			ToTime:         to,
+			LatMin:         latMin,
+			LatMax:         latMax,
+			LonMin:         lonMin,
+			LonMax:         lonMax,
+			AltMin:         filter.AltMin,
+			AltMax:         filter.AltMax,
+			VelocityMin:    filter.VelocityMin,
+			VelocityMax:    filter.VelocityMax,
+			VerticalSign:   filter.VerticalSign,
+			CallsignPrefix: filter.CallsignPrefix,
+			Icao24List:     filter.Icao24List,
+			OriginCountry:  filter.OriginCountry,
+			Hours:          hours,
+			TimeZone:       sql.NullString{String: timeZone, Valid: true},
+			Weekdays:       weekdays,
+		}
+
+		// samples=1 asks for the drill-down the rollups don't keep
+		samples := req.URL.Query().Get("samples") == "1"
+
+		var points []HeatPoint
+		covered := false
+		if metric == metricCount && !samples && !grid.metric() && canUseRollups(binSize, filter, hours, weekdays) {
+			points, covered, err = rollupHeatmap(req.Context(), queries, params)
+		}
+		switch {
+		case err != nil || covered:
+		case grid.metric():
+			points, err = metricCells(req.Context(), queries, params, grid.cellM)
+		case metric != metricCount:
+			points, err = valueHeatmap(req.Context(), queries, params, metric)
+		case grid.h3:
+			// hexagons carry counts only, so the samples aren't aggregated
+			points, err = valueHeatmap(req.Context(), queries, params, metricCount)
+		default:
+			points, err = rawHeatmap(req.Context(), queries, params)
+		}
 		if err != nil {
 			http.Error(res, "error fetching heatmap", http.StatusInternalServerError)
 			return
 		}
 
-		points := make([]HeatPoint, 0, len(raw))
-		for _, row := range raw {
-			if row.LatBin.Valid && row.LonBin.Valid {
-				points = append(points, HeatPoint{
-					ID:    row.ID,
-					Lat:   row.LatBin.Float64,
-					Lon:   row.LonBin.Float64,
-					Count: row.Count,
-				})
+		if grid.h3 {
+			points = h3Bins(points, binSize, grid.res)
+		}
+		for i := range points {
+			if metric == metricCount {
+				points[i].Value = float64(points[i].Count)
 			}
+			points[i].Metric = metric
+		}
+
+		res.Header().Set("Vary", "Accept")
+		if wantsGeoJSON(req) {
+			res.Header().Set("Content-Type", "application/geo+json")
+			if !grid.square() {
+				json.NewEncoder(res).Encode(cellFeatures(points, grid))
+			} else {
+				json.NewEncoder(res).Encode(heatmapFeatures(points, binSize))
+			}
+			return
 		}
 
 		res.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(res).Encode(points)
 	}
 }
+
+// binParam reads bin=, or picks the bin for the map's zoom= level
+func binParam(req *http.Request) int {
+	binSize := 80 // default bin granularity
+
+	if v := req.URL.Query().Get("bin"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			binSize = parsed
+		}
+	} else if v := req.URL.Query().Get("zoom"); v != "" {
+		// let the server pick the bin for the map's zoom level
+		if parsed, err := strconv.Atoi(v); err == nil {
+			binSize = tile.BinForZoom(parsed)
+		}
+	}
+
+	return binSize
+}
+
+// rawHeatmap bins raw fixes with the aircraft and samples for drill-down
+func rawHeatmap(ctx context.Context, queries HeatmapQuerier, params repository.GetHeatmapDataDynamicParams) ([]HeatPoint, error) {
+	raw, err := queries.GetHeatmapDataDynamic(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]HeatPoint, 0, len(raw))
+	for _, row := range raw {
+		if !row.LatBin.Valid || !row.LonBin.Valid {
+			continue
+		}
+
+		points = append(points, HeatPoint{
+			Lat:           row.LatBin.Float64,
+			Lon:           row.LonBin.Float64,
+			Count:         row.Count,
+			Aircraft:      row.Aircraft,
+			SampleIDs:     row.SampleIds,
+			SampleIcao24s: row.SampleIcao24s,
+		})
+	}
+
+	return points, nil
+}
+
+// wantsGeoJSON negotiates GeoJSON output via ?format=geojson or the Accept header
+func wantsGeoJSON(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "geojson" {
+		return true
+	}
+
+	return strings.Contains(req.Header.Get("Accept"), "application/geo+json")
+}
+
+// heatmapFeatures turns each bin into a Polygon cell so the output loads
+// directly into GIS tools
+func heatmapFeatures(points []HeatPoint, binSize int) geojson.FeatureCollection {
+	size := 1 / float64(binSize)
+
+	features := make([]geojson.Feature, 0, len(points))
+	for _, p := range points {
+		features = append(features, geojson.NewFeature(geojson.CellPolygon(p.Lat, p.Lon, size), map[string]any{
+			"count":         p.Count,
+			"value":         p.Value,
+			"metric":        p.Metric,
+			"aircraft":      p.Aircraft,
+			"bin":           binSize,
+			"cell_size_deg": size,
+		}))
+	}
+
+	return geojson.NewFeatureCollection(features)
+}
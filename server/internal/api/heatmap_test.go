@@ -4,30 +4,96 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geo"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/h3"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
 )
 
-type mockQueries struct{}
+type mockQueries struct {
+	args       repository.GetHeatmapDataDynamicParams
+	rawCalls   []repository.GetHeatmapDataDynamicParams
+	valueCalls []repository.GetHeatmapValueBinsParams
+	cellCalls  []repository.GetMetricCellsParams
+	watermark  time.Time
+	rollupArgs *repository.GetRollupBinsParams
+}
+
+func (m *mockQueries) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	if m.watermark.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.watermark, nil
+}
+
+func (m *mockQueries) GetRollupBins(ctx context.Context, args repository.GetRollupBinsParams) ([]repository.GetRollupBinsRow, error) {
+	m.rollupArgs = &args
+	return []repository.GetRollupBinsRow{
+		{
+			LatBin: sql.NullFloat64{Float64: 60.25, Valid: true},
+			LonBin: sql.NullFloat64{Float64: 24.75, Valid: true},
+			Count:  100,
+		},
+		{
+			LatBin: sql.NullFloat64{Float64: 60.5, Valid: true},
+			LonBin: sql.NullFloat64{Float64: 25, Valid: true},
+			Count:  7,
+		},
+	}, nil
+}
 
 func (m *mockQueries) GetHeatmapDataDynamic(ctx context.Context, args repository.GetHeatmapDataDynamicParams) ([]repository.GetHeatmapDataDynamicRow, error) {
+	m.args = args
+	m.rawCalls = append(m.rawCalls, args)
 	return []repository.GetHeatmapDataDynamicRow{
+		{
+			LatBin:        sql.NullFloat64{Float64: 60.25, Valid: true},
+			LonBin:        sql.NullFloat64{Float64: 24.75, Valid: true},
+			Count:         int64(12),
+			Aircraft:      3,
+			SampleIds:     []int32{41, 40},
+			SampleIcao24s: []string{"461e1f", "46b8a1", "4ca7b2"},
+		},
+	}, nil
+}
+
+// mockValues are the metrics of the mock's only bin, it has no vertical rates
+var mockValues = map[string]float64{"aircraft": 3, "avg_altitude": 1500, "min_altitude": 300}
+
+func (m *mockQueries) GetHeatmapValueBins(ctx context.Context, args repository.GetHeatmapValueBinsParams) ([]repository.GetHeatmapValueBinsRow, error) {
+	m.valueCalls = append(m.valueCalls, args)
+	value, ok := mockValues[args.Metric]
+	return []repository.GetHeatmapValueBinsRow{
 		{
 			LatBin: sql.NullFloat64{Float64: 60.25, Valid: true},
 			LonBin: sql.NullFloat64{Float64: 24.75, Valid: true},
 			Count:  int64(12),
+			Value:  sql.NullFloat64{Float64: value, Valid: ok},
 		},
 	}, nil
 }
 
+// GetMetricCells returns the cell holding the mock's bin
+func (m *mockQueries) GetMetricCells(ctx context.Context, args repository.GetMetricCellsParams) ([]repository.GetMetricCellsRow, error) {
+	m.cellCalls = append(m.cellCalls, args)
+	col, row := geo.Grid{Projection: geo.TM35FIN, Size: args.CellSize}.CellAt(60.25, 24.75)
+	return []repository.GetMetricCellsRow{
+		{CellCol: int32(col), CellRow: int32(row), Count: 12},
+	}, nil
+}
+
 func TestHeatmapHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/api/heatmap?bin=80&minutes=15", nil)
 	w := httptest.NewRecorder()
 
-	handler := HeatmapHandler(&mockQueries{})
+	handler := HeatmapHandler(&mockQueries{}, "Europe/Helsinki")
 	handler(w, req)
 
 	resp := w.Result()
@@ -37,12 +103,547 @@ func TestHeatmapHandler(t *testing.T) {
 		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
 	}
 
-	var data []repository.GetHeatmapDataDynamicRow
+	var data []HeatPoint
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		t.Fatal("invalid JSON response")
 	}
 
-	if len(data) != 1 || data[0].Count != 12 {
-		t.Errorf("unexpected data: %+v", data)
+	if len(data) != 1 || data[0].Count != 12 || data[0].Aircraft != 3 {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+	if len(data[0].SampleIDs) != 2 || data[0].SampleIDs[0] != 41 || len(data[0].SampleIcao24s) != 3 {
+		t.Errorf("unexpected samples: %+v", data[0])
+	}
+}
+
+func TestHeatmapHandlerSampleSize(t *testing.T) {
+	mock := &mockQueries{}
+	w := httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap", nil))
+
+	if mock.args.SampleSize != heatmapSampleSize {
+		t.Errorf("expected sample size %d, got %d", heatmapSampleSize, mock.args.SampleSize)
+	}
+}
+
+func TestHeatmapHandlerGeoJSON(t *testing.T) {
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/heatmap?bin=80&format=geojson", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/api/heatmap?bin=80", nil)
+			r.Header.Set("Accept", "application/geo+json")
+			return r
+		}(),
+	} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+			t.Fatalf("expected GeoJSON content type, got %q", ct)
+		}
+
+		var fc struct {
+			Type     string `json:"type"`
+			Features []struct {
+				Geometry struct {
+					Type        string         `json:"type"`
+					Coordinates [][][2]float64 `json:"coordinates"`
+				} `json:"geometry"`
+				Properties map[string]any `json:"properties"`
+			} `json:"features"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+			t.Fatal("invalid JSON response")
+		}
+
+		if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+			t.Fatalf("unexpected collection: %+v", fc)
+		}
+
+		f := fc.Features[0]
+		if f.Geometry.Type != "Polygon" || len(f.Geometry.Coordinates[0]) != 5 {
+			t.Errorf("unexpected geometry: %+v", f.Geometry)
+		}
+		if f.Geometry.Coordinates[0][2] != [2]float64{24.7625, 60.2625} {
+			t.Errorf("unexpected cell corner: %v", f.Geometry.Coordinates[0][2])
+		}
+		if f.Properties["count"] != float64(12) {
+			t.Errorf("unexpected properties: %+v", f.Properties)
+		}
+	}
+}
+
+func TestHeatmapHandlerBBox(t *testing.T) {
+	mock := &mockQueries{}
+	w := httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?bbox=24.5,60.1,25.5,60.5", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if mock.args.LatMin.Float64 != 60.1 || mock.args.LatMax.Float64 != 60.5 || mock.args.LonMin.Float64 != 24.5 || mock.args.LonMax.Float64 != 25.5 {
+		t.Errorf("unexpected bbox args: %+v", mock.args)
+	}
+
+	// crossing the antimeridian keeps minLon above maxLon for the query
+	mock = &mockQueries{}
+	w = httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?bbox=170,-20,-170,10", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK for antimeridian box, got %d", w.Code)
+	}
+	if mock.args.LonMin.Float64 != 170 || mock.args.LonMax.Float64 != -170 {
+		t.Errorf("unexpected antimeridian args: %+v", mock.args)
+	}
+
+	// the box is widened to the whole bins it touches
+	mock = &mockQueries{}
+	HeatmapHandler(mock, "Europe/Helsinki")(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/heatmap?bin=80&bbox=24.51,60.11,25.49,60.49", nil))
+	if mock.args.LatMin.Float64 != 60.1 || mock.args.LatMax.Float64 != 60.5 || mock.args.LonMin.Float64 != 24.5 || mock.args.LonMax.Float64 != 25.5 {
+		t.Errorf("expected the box snapped to bins: %+v", mock.args)
+	}
+
+	mock = &mockQueries{}
+	HeatmapHandler(mock, "Europe/Helsinki")(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/heatmap", nil))
+	if mock.args.LatMin.Valid || mock.args.LonMin.Valid {
+		t.Errorf("expected no bbox filter by default: %+v", mock.args)
+	}
+
+	for _, bbox := range []string{"1,2,3", "a,60,25,61", "24,61,25,60", "24,60,24,61", "-181,60,25,61", "24,60,25,91"} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?bbox="+bbox, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("bbox=%s: expected 400, got %d", bbox, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerFilters(t *testing.T) {
+	mock := &mockQueries{}
+	w := httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET",
+		"/api/heatmap?alt_max=2000&velocity_min=50&vertical=descend&callsign=fin&icao24=461F2A,46b8a1&origin_country=Finland", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+
+	args := mock.args
+	if args.AltMin.Valid || !args.AltMax.Valid || args.AltMax.Float64 != 2000 {
+		t.Errorf("unexpected altitude filter: %+v %+v", args.AltMin, args.AltMax)
+	}
+	if !args.VelocityMin.Valid || args.VelocityMin.Float64 != 50 || args.VelocityMax.Valid {
+		t.Errorf("unexpected velocity filter: %+v %+v", args.VelocityMin, args.VelocityMax)
+	}
+	if !args.VerticalSign.Valid || args.VerticalSign.Int32 != -1 {
+		t.Errorf("unexpected vertical filter: %+v", args.VerticalSign)
+	}
+	if args.CallsignPrefix.String != "FIN" || args.Icao24List.String != "461f2a,46b8a1" || args.OriginCountry.String != "Finland" {
+		t.Errorf("unexpected text filters: %+v", args)
+	}
+
+	for _, query := range []string{
+		"alt_min=high",
+		"alt_min=3000&alt_max=1000",
+		"velocity_max=fast",
+		"velocity_min=200&velocity_max=100",
+		"vertical=up",
+		"callsign=FIN%25",
+		"icao24=461f2a,xyz",
+	} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerTimeWindow(t *testing.T) {
+	mock := &mockQueries{}
+	w := httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?from=2026-10-13T06:00:00%2B03:00&to=2026-10-13T09:00:00%2B03:00", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if got := mock.args.FromTime.Time; !mock.args.FromTime.Valid || got != time.Date(2026, 10, 13, 3, 0, 0, 0, time.UTC) {
+		t.Errorf("expected from in UTC, got %v", got)
+	}
+	if got := mock.args.ToTime.Time; !mock.args.ToTime.Valid || got != time.Date(2026, 10, 13, 6, 0, 0, 0, time.UTC) {
+		t.Errorf("expected to in UTC, got %v", got)
+	}
+
+	// minutes extends from forwards
+	mock = &mockQueries{}
+	HeatmapHandler(mock, "Europe/Helsinki")(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/heatmap?from=2026-10-13T03:00:00Z&minutes=180", nil))
+	if got := mock.args.ToTime.Time; got != time.Date(2026, 10, 13, 6, 0, 0, 0, time.UTC) {
+		t.Errorf("expected from+minutes to end at 06:00, got %v", got)
+	}
+
+	// minutes counts back from to
+	mock = &mockQueries{}
+	HeatmapHandler(mock, "Europe/Helsinki")(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/heatmap?to=2026-10-13T06:00:00Z&minutes=60", nil))
+	if got := mock.args.FromTime.Time; got != time.Date(2026, 10, 13, 5, 0, 0, 0, time.UTC) {
+		t.Errorf("expected to-minutes to start at 05:00, got %v", got)
+	}
+
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2026-10-13",
+		"minutes=-5",
+		"from=2026-10-13T06:00:00Z&to=2026-10-13T03:00:00Z",
+		"from=2026-01-01T00:00:00Z&to=2026-03-01T00:00:00Z",
+		"from=2026-10-13T03:00:00Z&to=2026-10-13T06:00:00Z&minutes=10",
+	} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerTimeSelectors(t *testing.T) {
+	cases := []struct {
+		query    string
+		hours    string
+		weekdays string
+	}{
+		{"hours=6-9", "6,7,8", ""},
+		{"hours=22-2,12", "0,1,12,22,23", ""},
+		{"hours=0-24", "0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23", ""},
+		{"weekdays=mon-fri", "", "1,2,3,4,5"},
+		{"weekdays=sat,sun", "", "6,7"},
+		{"weekdays=Fri-Mon&hours=23", "23", "1,5,6,7"},
+	}
+
+	for _, c := range cases {
+		mock := &mockQueries{}
+		w := httptest.NewRecorder()
+		HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+c.query, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d", c.query, w.Code)
+		}
+		if mock.args.Hours.String != c.hours || mock.args.Hours.Valid != (c.hours != "") {
+			t.Errorf("%s: expected hours %q, got %+v", c.query, c.hours, mock.args.Hours)
+		}
+		if mock.args.Weekdays.String != c.weekdays || mock.args.Weekdays.Valid != (c.weekdays != "") {
+			t.Errorf("%s: expected weekdays %q, got %+v", c.query, c.weekdays, mock.args.Weekdays)
+		}
+		if mock.args.TimeZone.String != "Europe/Helsinki" {
+			t.Errorf("%s: expected the configured time zone, got %+v", c.query, mock.args.TimeZone)
+		}
+	}
+
+	for _, query := range []string{"hours=25", "hours=6-6", "hours=a-b", "weekdays=funday", "weekdays=mon-"} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerRollups(t *testing.T) {
+	watermark := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	mock := &mockQueries{watermark: watermark}
+	w := httptest.NewRecorder()
+	HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET",
+		"/api/heatmap?bin=80&from=2026-10-16T09:30:00Z&to=2026-10-19T12:30:00Z", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+
+	args := mock.rollupArgs
+	if args == nil {
+		t.Fatal("expected the rollups to be queried")
+	}
+	if args.BinSize != 80 || !args.FromTime.Equal(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)) || !args.ToTime.Equal(watermark) {
+		t.Errorf("unexpected rollup range: %+v", args)
+	}
+	if !args.DayFrom.Equal(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)) || !args.DayTo.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected whole days: %v to %v", args.DayFrom, args.DayTo)
+	}
+
+	// the partial first hour and everything after the watermark are raw, and
+	// only counted as the merged bins have no samples
+	if len(mock.valueCalls) != 2 || len(mock.rawCalls) != 0 {
+		t.Fatalf("expected 2 raw count queries, got %d and %d with samples", len(mock.valueCalls), len(mock.rawCalls))
+	}
+	head, tail := mock.valueCalls[0], mock.valueCalls[1]
+	if head.Metric != metricCount {
+		t.Errorf("expected counts only, got %q", head.Metric)
+	}
+	if !head.FromTime.Time.Equal(time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)) || !head.ToTime.Time.Equal(args.FromTime) {
+		t.Errorf("unexpected head window: %v to %v", head.FromTime, head.ToTime)
+	}
+	if !tail.FromTime.Time.Equal(watermark) || !tail.ToTime.Time.Equal(time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected tail window: %v to %v", tail.FromTime, tail.ToTime)
+	}
+
+	var data []HeatPoint
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+
+	// 100 from the rollups and 12 from each raw query land in the same bin
+	if len(data) != 2 || data[0].Count != 124 || data[1].Count != 7 {
+		t.Fatalf("unexpected merged bins: %+v", data)
+	}
+	if data[0].Aircraft != 0 || data[0].SampleIDs != nil {
+		t.Errorf("expected merged bins without samples: %+v", data[0])
+	}
+}
+
+func TestHeatmapHandlerRollupsSkipped(t *testing.T) {
+	watermark := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	for _, query := range []string{
+		"bin=80&minutes=30",
+		"bin=20",
+		"bin=80&alt_max=2000",
+		"bin=80&hours=6-9",
+		// markers drill down into the bins
+		"bin=80&samples=1",
+	} {
+		mock := &mockQueries{watermark: watermark}
+		w := httptest.NewRecorder()
+		HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d", query, w.Code)
+		}
+		if mock.rollupArgs != nil || len(mock.rawCalls) != 1 {
+			t.Errorf("%s: expected a single raw query, got %d and rollups %v", query, len(mock.rawCalls), mock.rollupArgs)
+		}
+	}
+}
+
+func TestHeatmapHandlerH3(t *testing.T) {
+	mock := &mockQueries{}
+	req := httptest.NewRequest("GET", "/api/heatmap?grid=h3&res=7&bbox=24.5,60.1,25,60.4", nil)
+	w := httptest.NewRecorder()
+
+	HeatmapHandler(mock, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	// res 7 hexagons are finer than the rollups, so they come from raw bins
+	// without the samples they would drop
+	if len(mock.valueCalls) != 1 || len(mock.rawCalls) != 0 {
+		t.Fatalf("expected a single count query, got %d and %d with samples", len(mock.valueCalls), len(mock.rawCalls))
+	}
+	if args := mock.valueCalls[0]; args.BinSize.Float64 != 2920 || args.Metric != metricCount {
+		t.Errorf("unexpected source bin: %v", args.BinSize)
+	}
+
+	var data []HeatPoint
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+
+	want, _ := h3.FromLatLng(60.25+0.5/2920, 24.75+0.5/2920, 7)
+	if len(data) != 1 || data[0].Cell != want.String() || data[0].Count != 12 {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+	if data[0].Aircraft != 0 || data[0].SampleIDs != nil {
+		t.Errorf("expected hexagons to carry counts only: %+v", data[0])
+	}
+	if len(data[0].Boundary) != 6 {
+		t.Errorf("unexpected boundary: %v", data[0].Boundary)
+	}
+	if center := want.LatLng(); data[0].Lat != center.Lat || data[0].Lon != center.Lng {
+		t.Errorf("expected the hexagon center, got %v, %v", data[0].Lat, data[0].Lon)
+	}
+}
+
+func TestHeatmapHandlerH3Rollups(t *testing.T) {
+	mock := &mockQueries{watermark: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	req := httptest.NewRequest("GET", "/api/heatmap?grid=h3&res=4&format=geojson&from=2025-05-31T00:00:00Z&to=2025-06-01T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	HeatmapHandler(mock, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if mock.rollupArgs == nil || mock.rollupArgs.BinSize != 160 {
+		t.Fatalf("expected coarse hexagons to use the 160 bin rollups, got %+v", mock.rollupArgs)
+	}
+
+	var fc geojson.FeatureCollection
+	if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(fc.Features) == 0 {
+		t.Fatal("expected hexagon features")
+	}
+	for _, f := range fc.Features {
+		if f.Geometry.Type != "Polygon" || f.Properties["res"] != float64(4) || f.Properties["cell"] == "" {
+			t.Errorf("unexpected feature: %+v", f)
+		}
+	}
+}
+
+func TestHeatmapHandlerH3RejectsBadParams(t *testing.T) {
+	for _, query := range []string{"grid=hex", "grid=h3&res=11", "grid=h3&res=-1", "grid=h3&res=x"} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerFineGridsNeedABBox(t *testing.T) {
+	for _, query := range []string{
+		"grid=h3&res=7",
+		"cell=500m",
+		// four million 54055 bins span less than a twentieth of a degree square
+		"grid=h3&res=10&bbox=24.5,60,25,60.5",
+		"cell=100m&bbox=24,60,26,61",
+	} {
+		mock := &mockQueries{}
+		w := httptest.NewRecorder()
+		HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest || len(mock.valueCalls) != 0 || len(mock.cellCalls) != 0 {
+			t.Errorf("%s: expected 400 without a query, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerMetricCells(t *testing.T) {
+	mock := &mockQueries{}
+	req := httptest.NewRequest("GET", "/api/heatmap?cell=500m&bbox=24.5,60,25,60.5", nil)
+	w := httptest.NewRecorder()
+
+	HeatmapHandler(mock, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if len(mock.valueCalls) != 0 || len(mock.cellCalls) != 1 || mock.cellCalls[0].CellSize != 500 {
+		t.Errorf("expected the fixes to be counted in 500 m cells: %+v", mock.cellCalls)
+	}
+	// the bbox isn't widened to square bins
+	if args := mock.cellCalls[0]; args.LatMin.Float64 != 60 || args.LonMax.Float64 != 25 {
+		t.Errorf("unexpected bbox: %+v", args)
+	}
+
+	var data []HeatPoint
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(data) != 1 || data[0].Count != 12 || len(data[0].Boundary) != 4 {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+
+	// the corners are in WGS84 and 500 m apart
+	sw, se := data[0].Boundary[0], data[0].Boundary[1]
+	if sw[1] < 60 || sw[1] > 60.5 || sw[0] < 24.5 || sw[0] > 25 {
+		t.Errorf("unexpected corner: %v", sw)
+	}
+	if d := opensky.Haversine(sw[1], sw[0], se[1], se[0]) * 1000; math.Abs(d-500) > 2 {
+		t.Errorf("expected a 500 m edge, got %.1f", d)
+	}
+}
+
+func TestHeatmapHandlerMetricCellsGeoJSON(t *testing.T) {
+	mock := &mockQueries{}
+	req := httptest.NewRequest("GET", "/api/heatmap?cell=20km&format=geojson", nil)
+	w := httptest.NewRecorder()
+
+	HeatmapHandler(mock, "Europe/Helsinki")(w, req)
+
+	// 20 km cells are coarse enough to need no bbox
+	if len(mock.cellCalls) != 1 || mock.cellCalls[0].LatMin.Valid {
+		t.Errorf("unexpected cell query: %+v", mock.cellCalls)
+	}
+
+	var fc geojson.FeatureCollection
+	if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Properties["cell_size_m"] != float64(20000) {
+		t.Fatalf("unexpected features: %+v", fc.Features)
+	}
+}
+
+func TestHeatmapHandlerMetricCellsRejectsBadParams(t *testing.T) {
+	for _, query := range []string{"cell=500", "cell=10m", "cell=100km", "cell=xm", "grid=h3&cell=500m"} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHeatmapHandlerMetric(t *testing.T) {
+	for _, tc := range []struct {
+		query  string
+		metric string
+		value  float64
+	}{
+		{"", "count", 12},
+		{"metric=aircraft", "aircraft", 3},
+		{"metric=min_altitude", "min_altitude", 300},
+		{"metric=avg_altitude", "avg_altitude", 1500},
+	} {
+		mock := &mockQueries{}
+		w := httptest.NewRecorder()
+		HeatmapHandler(mock, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?bin=80&"+tc.query, nil))
+
+		var data []HeatPoint
+		if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+			t.Fatal("invalid JSON response")
+		}
+		if len(data) != 1 || data[0].Metric != tc.metric || data[0].Value != tc.value || data[0].Count != 12 {
+			t.Errorf("%s: unexpected data: %+v", tc.query, data)
+		}
+	}
+}
+
+func TestHeatmapHandlerMetricSkipsRollupsAndEmptyBins(t *testing.T) {
+	mock := &mockQueries{watermark: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	req := httptest.NewRequest("GET", "/api/heatmap?bin=80&metric=median_vertical_rate&from=2025-05-31T00:00:00Z&to=2025-06-01T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	HeatmapHandler(mock, "Europe/Helsinki")(w, req)
+
+	if mock.rollupArgs != nil || len(mock.rawCalls) != 0 || len(mock.valueCalls) != 1 {
+		t.Error("expected metrics to be binned from raw data without samples")
+	}
+
+	var data []HeatPoint
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	// the only bin has no vertical rates
+	if len(data) != 0 {
+		t.Errorf("expected bins without a value to be left out, got %+v", data)
+	}
+}
+
+func TestHeatmapHandlerMetricRejectsBadParams(t *testing.T) {
+	for _, query := range []string{"metric=altitude", "metric=avg_altitude&grid=h3", "metric=aircraft&cell=500m"} {
+		w := httptest.NewRecorder()
+		HeatmapHandler(&mockQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
 	}
 }
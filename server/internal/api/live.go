@@ -0,0 +1,41 @@
+// Package api's live endpoint returns the latest fix of every aircraft in the air
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/live"
+)
+
+type LiveSnapshotter interface {
+	Snapshot(now time.Time, maxAge time.Duration) []live.Aircraft
+}
+
+// LiveAircraftHandler serves the in-memory index. max_age (seconds) overrides
+// the configured staleness window, up to the index's retention; older fixes
+// are no longer kept, so a longer max_age is rejected.
+func LiveAircraftHandler(index LiveSnapshotter, defaultMaxAge time.Duration) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		maxAge := defaultMaxAge
+
+		if v := req.URL.Query().Get("max_age"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(res, "invalid max_age", http.StatusBadRequest)
+				return
+			}
+			if time.Duration(parsed)*time.Second > live.Retention {
+				http.Error(res, fmt.Sprintf("max_age must be at most %d", int(live.Retention.Seconds())), http.StatusBadRequest)
+				return
+			}
+			maxAge = time.Duration(parsed) * time.Second
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(index.Snapshot(time.Now(), maxAge))
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/live"
+)
+
+type mockSnapshotter struct {
+	maxAge time.Duration
+}
+
+func (m *mockSnapshotter) Snapshot(now time.Time, maxAge time.Duration) []live.Aircraft {
+	m.maxAge = maxAge
+	return nil
+}
+
+func TestLiveAircraftHandlerMaxAge(t *testing.T) {
+	for _, tc := range []struct {
+		query  string
+		code   int
+		maxAge time.Duration
+	}{
+		{"", http.StatusOK, 2 * time.Minute},
+		{"max_age=600", http.StatusOK, 10 * time.Minute},
+		{"max_age=1800", http.StatusOK, live.Retention},
+		// the index no longer holds fixes this old
+		{"max_age=1801", http.StatusBadRequest, 0},
+		{"max_age=0", http.StatusBadRequest, 0},
+		{"max_age=x", http.StatusBadRequest, 0},
+	} {
+		mock := &mockSnapshotter{}
+		w := httptest.NewRecorder()
+		LiveAircraftHandler(mock, 2*time.Minute)(w, httptest.NewRequest("GET", "/api/aircraft/live?"+tc.query, nil))
+
+		if w.Code != tc.code || mock.maxAge != tc.maxAge {
+			t.Errorf("%s: expected %d with %v, got %d with %v", tc.query, tc.code, tc.maxAge, w.Code, mock.maxAge)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// metricCount is the default metric, the number of fixes in a bin
+const metricCount = "count"
+
+// heatmapMetrics are the metrics besides the count that GetHeatmapValueBins
+// can aggregate
+var heatmapMetrics = []string{"aircraft", "avg_altitude", "min_altitude", "max_altitude", "avg_velocity", "median_vertical_rate"}
+
+// parseMetric reads metric=, one of count, aircraft (distinct icao24),
+// avg_altitude, min_altitude, max_altitude (m), avg_velocity (m/s) and
+// median_vertical_rate (m/s)
+func parseMetric(req *http.Request) (string, error) {
+	metric := req.URL.Query().Get("metric")
+	if metric == "" || metric == metricCount {
+		return metricCount, nil
+	}
+	if !slices.Contains(heatmapMetrics, metric) {
+		return "", errors.New("invalid metric")
+	}
+
+	return metric, nil
+}
+
+// valueHeatmap bins raw fixes into counts and the value of one metric, without
+// the drill-down samples. Bins without a value, like ones where no fix
+// reported an altitude, are left out.
+func valueHeatmap(ctx context.Context, queries HeatmapQuerier, params repository.GetHeatmapDataDynamicParams, metric string) ([]HeatPoint, error) {
+	rows, err := queries.GetHeatmapValueBins(ctx, repository.GetHeatmapValueBinsParams{
+		BinSize:        params.BinSize,
+		Metric:         metric,
+		FromTime:       params.FromTime,
+		ToTime:         params.ToTime,
+		LatMin:         params.LatMin,
+		LatMax:         params.LatMax,
+		LonMin:         params.LonMin,
+		LonMax:         params.LonMax,
+		AltMin:         params.AltMin,
+		AltMax:         params.AltMax,
+		VelocityMin:    params.VelocityMin,
+		VelocityMax:    params.VelocityMax,
+		VerticalSign:   params.VerticalSign,
+		CallsignPrefix: params.CallsignPrefix,
+		Icao24List:     params.Icao24List,
+		OriginCountry:  params.OriginCountry,
+		Hours:          params.Hours,
+		TimeZone:       params.TimeZone,
+		Weekdays:       params.Weekdays,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]HeatPoint, 0, len(rows))
+	for _, row := range rows {
+		if !row.LatBin.Valid || !row.LonBin.Valid {
+			continue
+		}
+		if metric != metricCount && !row.Value.Valid {
+			continue
+		}
+
+		points = append(points, HeatPoint{
+			Lat:   row.LatBin.Float64,
+			Lon:   row.LonBin.Float64,
+			Count: row.Count,
+			Value: row.Value.Float64,
+		})
+	}
+
+	return points, nil
+}
@@ -0,0 +1,84 @@
+// Package api's noise endpoint returns an estimated noise exposure grid
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/noise"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// defaultNoiseWindow is used when no time window is given
+const defaultNoiseWindow = 24 * time.Hour
+
+// noiseMetric names the estimated level as a HeatPoint metric
+const noiseMetric = "noise_db"
+
+type NoiseQuerier interface {
+	GetNoiseFixes(ctx context.Context, arg repository.GetNoiseFixesParams) ([]repository.GetNoiseFixesRow, error)
+}
+
+// NoiseHandler returns the exposure grid as HeatPoints so the heat layer can
+// render it directly. Value carries the estimated level in dB, under the
+// noise_db metric. Without a window the last day is estimated.
+func NoiseHandler(queries NoiseQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		binSize := 80
+
+		if v := req.URL.Query().Get("bin"); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+				binSize = parsed
+			}
+		}
+
+		from, to, err := timeWindow(req, defaultNoiseWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		weighted := req.URL.Query().Get("weight") == "category"
+
+		rows, err := queries.GetNoiseFixes(req.Context(), repository.GetNoiseFixesParams{
+			FromTime: from,
+			ToTime:   to,
+		})
+		if err != nil {
+			http.Error(res, "error fetching noise fixes", http.StatusInternalServerError)
+			return
+		}
+
+		fixes := make([]noise.Fix, 0, len(rows))
+		for _, row := range rows {
+			if !row.Latitude.Valid || !row.Longitude.Valid || !row.BaroAltitude.Valid {
+				continue
+			}
+
+			fixes = append(fixes, noise.Fix{
+				Lat:      row.Latitude.Float64,
+				Lon:      row.Longitude.Float64,
+				Altitude: row.BaroAltitude.Float64,
+				Category: row.Category.Int32,
+			})
+		}
+
+		cells := noise.Grid(fixes, float64(binSize), weighted)
+
+		points := make([]HeatPoint, 0, len(cells))
+		for _, c := range cells {
+			points = append(points, HeatPoint{
+				Lat:    c.Lat,
+				Lon:    c.Lon,
+				Value:  c.Level,
+				Metric: noiseMetric,
+			})
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(points)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockNoiseQueries struct {
+	args repository.GetNoiseFixesParams
+}
+
+func (m *mockNoiseQueries) GetNoiseFixes(ctx context.Context, arg repository.GetNoiseFixesParams) ([]repository.GetNoiseFixesRow, error) {
+	m.args = arg
+	return []repository.GetNoiseFixesRow{
+		{
+			Latitude:     sql.NullFloat64{Float64: 60.3, Valid: true},
+			Longitude:    sql.NullFloat64{Float64: 24.9, Valid: true},
+			BaroAltitude: sql.NullFloat64{Float64: 300, Valid: true},
+		},
+	}, nil
+}
+
+func TestNoiseHandler(t *testing.T) {
+	mock := &mockNoiseQueries{}
+	w := httptest.NewRecorder()
+	NoiseHandler(mock)(w, httptest.NewRequest("GET", "/api/noise", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if got := mock.args.ToTime.Time.Sub(mock.args.FromTime.Time); !mock.args.FromTime.Valid || got != defaultNoiseWindow {
+		t.Errorf("expected the default window, got %v", got)
+	}
+
+	var data []HeatPoint
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(data) == 0 {
+		t.Fatal("expected noise cells")
+	}
+	for _, p := range data {
+		if p.Metric != "noise_db" || p.Value <= 0 || p.Count != 0 {
+			t.Errorf("expected the level in dB as the value, got %+v", p)
+		}
+	}
+}
+
+func TestNoiseHandlerWindow(t *testing.T) {
+	mock := &mockNoiseQueries{}
+	w := httptest.NewRecorder()
+	NoiseHandler(mock)(w, httptest.NewRequest("GET", "/api/noise?from=2025-06-01T00:00:00Z&to=2025-06-02T00:00:00Z", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if !mock.args.FromTime.Time.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected window start %v", mock.args.FromTime)
+	}
+}
@@ -0,0 +1,274 @@
+// Package api's points endpoints manage monitoring points and their overflights
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/overflight"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/spatial"
+)
+
+const (
+	defaultPointRadiusM = 2000.0
+	// maxPointRadiusM bounds the circle overflights are counted in
+	maxPointRadiusM = 50000.0
+	// defaultOverflightWindow is used when no time window is given
+	defaultOverflightWindow = 24 * time.Hour
+)
+
+type MonitoringPoint struct {
+	ID        int32     `json:"id"`
+	Name      string    `json:"name"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	RadiusM   float64   `json:"radius_m"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MonitoringPointQuerier interface {
+	CreateMonitoringPoint(ctx context.Context, arg repository.CreateMonitoringPointParams) (repository.MonitoringPoint, error)
+	ListMonitoringPoints(ctx context.Context) ([]repository.MonitoringPoint, error)
+	GetMonitoringPoint(ctx context.Context, id int32) (repository.MonitoringPoint, error)
+	UpdateMonitoringPoint(ctx context.Context, arg repository.UpdateMonitoringPointParams) (repository.MonitoringPoint, error)
+	DeleteMonitoringPoint(ctx context.Context, id int32) (int64, error)
+}
+
+type OverflightQuerier interface {
+	GetMonitoringPoint(ctx context.Context, id int32) (repository.MonitoringPoint, error)
+}
+
+func ListPointsHandler(queries MonitoringPointQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		rows, err := queries.ListMonitoringPoints(req.Context())
+		if err != nil {
+			http.Error(res, "error fetching points", http.StatusInternalServerError)
+			return
+		}
+
+		points := make([]MonitoringPoint, 0, len(rows))
+		for _, row := range rows {
+			points = append(points, toMonitoringPoint(row))
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(points)
+	}
+}
+
+func CreatePointHandler(queries MonitoringPointQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		input, err := decodePoint(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		row, err := queries.CreateMonitoringPoint(req.Context(), repository.CreateMonitoringPointParams{
+			Name:      sql.NullString{String: input.Name, Valid: true},
+			Latitude:  sql.NullFloat64{Float64: input.Lat, Valid: true},
+			Longitude: sql.NullFloat64{Float64: input.Lon, Valid: true},
+			RadiusM:   sql.NullFloat64{Float64: input.RadiusM, Valid: true},
+		})
+		if err != nil {
+			http.Error(res, "error creating point", http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(toMonitoringPoint(row))
+	}
+}
+
+func GetPointHandler(queries MonitoringPointQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		row, err := queries.GetMonitoringPoint(req.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(res, "point not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, "error fetching point", http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(toMonitoringPoint(row))
+	}
+}
+
+func UpdatePointHandler(queries MonitoringPointQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		input, err := decodePoint(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		row, err := queries.UpdateMonitoringPoint(req.Context(), repository.UpdateMonitoringPointParams{
+			ID:        id,
+			Name:      sql.NullString{String: input.Name, Valid: true},
+			Latitude:  sql.NullFloat64{Float64: input.Lat, Valid: true},
+			Longitude: sql.NullFloat64{Float64: input.Lon, Valid: true},
+			RadiusM:   sql.NullFloat64{Float64: input.RadiusM, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(res, "point not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, "error updating point", http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(toMonitoringPoint(row))
+	}
+}
+
+func DeletePointHandler(queries MonitoringPointQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		deleted, err := queries.DeleteMonitoringPoint(req.Context(), id)
+		if err != nil {
+			http.Error(res, "error deleting point", http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			http.Error(res, "point not found", http.StatusNotFound)
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// OverflightsHandler returns overflight statistics for a monitoring point,
+// over the last day unless a window is given. The stored radius can be
+// overridden with radius= in meters, up to maxPointRadiusM.
+func OverflightsHandler(queries OverflightQuerier, positions spatial.Repository) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		point, err := queries.GetMonitoringPoint(req.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(res, "point not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, "error fetching point", http.StatusInternalServerError)
+			return
+		}
+
+		radiusM := point.RadiusM.Float64
+		if v := req.URL.Query().Get("radius"); v != "" {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed <= 0 || parsed > maxPointRadiusM {
+				http.Error(res, "invalid radius", http.StatusBadRequest)
+				return
+			}
+			radiusM = parsed
+		}
+
+		from, to, err := timeWindow(req, defaultOverflightWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lat, lon := point.Latitude.Float64, point.Longitude.Float64
+		rows, err := positions.Near(req.Context(), lat, lon, radiusM, from, to)
+		if err != nil {
+			http.Error(res, "error fetching positions", http.StatusInternalServerError)
+			return
+		}
+
+		fixes := make([]overflight.Fix, 0, len(rows))
+		for _, row := range rows {
+			if !row.Latitude.Valid || !row.Longitude.Valid {
+				continue
+			}
+
+			fixes = append(fixes, overflight.Fix{
+				Icao24:   row.Icao24.String,
+				Callsign: row.Callsign.String,
+				Time:     row.TimePosition,
+				Lat:      row.Latitude.Float64,
+				Lon:      row.Longitude.Float64,
+				Altitude: row.BaroAltitude.Float64,
+				Category: row.Category.Int32,
+			})
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(overflight.Analyze(lat, lon, radiusM, fixes))
+	}
+}
+
+func toMonitoringPoint(row repository.MonitoringPoint) MonitoringPoint {
+	return MonitoringPoint{
+		ID:        row.ID,
+		Name:      row.Name.String,
+		Lat:       row.Latitude.Float64,
+		Lon:       row.Longitude.Float64,
+		RadiusM:   row.RadiusM.Float64,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+func decodePoint(req *http.Request) (MonitoringPoint, error) {
+	var input MonitoringPoint
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return input, errors.New("invalid JSON body")
+	}
+
+	if input.Name == "" {
+		return input, errors.New("name is required")
+	}
+	if input.Lat < -90 || input.Lat > 90 || input.Lon < -180 || input.Lon > 180 {
+		return input, errors.New("invalid coordinates")
+	}
+	if input.RadiusM < 0 || input.RadiusM > maxPointRadiusM {
+		return input, errors.New("invalid radius")
+	}
+	if input.RadiusM == 0 {
+		input.RadiusM = defaultPointRadiusM
+	}
+
+	return input, nil
+}
+
+// pathID parses the {id} path value, writing a 400 when it is invalid
+func pathID(res http.ResponseWriter, req *http.Request) (int32, bool) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(res, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+
+	return int32(id), true
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// mockPointQueries keeps the points in memory
+type mockPointQueries struct {
+	points []repository.MonitoringPoint
+}
+
+func (m *mockPointQueries) CreateMonitoringPoint(ctx context.Context, arg repository.CreateMonitoringPointParams) (repository.MonitoringPoint, error) {
+	point := repository.MonitoringPoint{
+		ID:        int32(len(m.points) + 1),
+		Name:      arg.Name,
+		Latitude:  arg.Latitude,
+		Longitude: arg.Longitude,
+		RadiusM:   arg.RadiusM,
+		CreatedAt: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+	m.points = append(m.points, point)
+	return point, nil
+}
+
+func (m *mockPointQueries) ListMonitoringPoints(ctx context.Context) ([]repository.MonitoringPoint, error) {
+	return m.points, nil
+}
+
+func (m *mockPointQueries) GetMonitoringPoint(ctx context.Context, id int32) (repository.MonitoringPoint, error) {
+	for _, p := range m.points {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return repository.MonitoringPoint{}, sql.ErrNoRows
+}
+
+func (m *mockPointQueries) UpdateMonitoringPoint(ctx context.Context, arg repository.UpdateMonitoringPointParams) (repository.MonitoringPoint, error) {
+	return repository.MonitoringPoint{}, sql.ErrNoRows
+}
+
+func (m *mockPointQueries) DeleteMonitoringPoint(ctx context.Context, id int32) (int64, error) {
+	for i, p := range m.points {
+		if p.ID == id {
+			m.points = append(m.points[:i], m.points[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func pointRequest(method, id, body string) *http.Request {
+	req := httptest.NewRequest(method, "/api/points/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestCreatePointHandler(t *testing.T) {
+	mock := &mockPointQueries{}
+	w := httptest.NewRecorder()
+	CreatePointHandler(mock)(w, pointRequest("POST", "", `{"name":"Tikkurila","lat":60.29,"lon":25.04}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d", w.Code)
+	}
+
+	var point MonitoringPoint
+	if err := json.NewDecoder(w.Body).Decode(&point); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if point.ID != 1 || point.Name != "Tikkurila" || point.RadiusM != defaultPointRadiusM {
+		t.Errorf("unexpected point: %+v", point)
+	}
+}
+
+func TestCreatePointHandlerRejectsBadInput(t *testing.T) {
+	for _, body := range []string{
+		`{"lat":60.29,"lon":25.04}`,
+		`{"name":"x","lat":91,"lon":25.04}`,
+		`{"name":"x","lat":60.29,"lon":25.04,"radius_m":-1}`,
+		`{"name":"x","lat":60.29,"lon":25.04,"radius_m":60000}`,
+		`{`,
+	} {
+		mock := &mockPointQueries{}
+		w := httptest.NewRecorder()
+		CreatePointHandler(mock)(w, pointRequest("POST", "", body))
+
+		if w.Code != http.StatusBadRequest || len(mock.points) != 0 {
+			t.Errorf("%s: expected 400 without a point, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestListPointsHandler(t *testing.T) {
+	mock := &mockPointQueries{}
+	for _, body := range []string{`{"name":"a","lat":60,"lon":25}`, `{"name":"b","lat":61,"lon":24,"radius_m":500}`} {
+		CreatePointHandler(mock)(httptest.NewRecorder(), pointRequest("POST", "", body))
+	}
+
+	w := httptest.NewRecorder()
+	ListPointsHandler(mock)(w, httptest.NewRequest("GET", "/api/points", nil))
+
+	var points []MonitoringPoint
+	if err := json.NewDecoder(w.Body).Decode(&points); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(points) != 2 || points[1].Name != "b" || points[1].RadiusM != 500 {
+		t.Errorf("unexpected points: %+v", points)
+	}
+}
+
+func TestDeletePointHandler(t *testing.T) {
+	mock := &mockPointQueries{}
+	CreatePointHandler(mock)(httptest.NewRecorder(), pointRequest("POST", "", `{"name":"a","lat":60,"lon":25}`))
+
+	for _, tc := range []struct {
+		id   string
+		code int
+	}{
+		{"1", http.StatusNoContent},
+		{"1", http.StatusNotFound},
+		{"x", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		DeletePointHandler(mock)(w, pointRequest("DELETE", tc.id, ""))
+
+		if w.Code != tc.code {
+			t.Errorf("delete %s: expected %d, got %d", tc.id, tc.code, w.Code)
+		}
+	}
+}
+
+func TestOverflightsHandler(t *testing.T) {
+	mock := &mockPointQueries{}
+	CreatePointHandler(mock)(httptest.NewRecorder(), pointRequest("POST", "", `{"name":"a","lat":60.3,"lon":24.9}`))
+
+	positions := &mockSpatial{}
+	w := httptest.NewRecorder()
+	OverflightsHandler(mock, positions)(w, pointRequest("GET", "1", ""))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if positions.call != "near" || positions.radiusM != defaultPointRadiusM {
+		t.Errorf("unexpected lookup %s within %v m", positions.call, positions.radiusM)
+	}
+	if !positions.from.Valid || time.Since(positions.from.Time) > defaultOverflightWindow+time.Minute {
+		t.Errorf("expected the default window, got %v", positions.from)
+	}
+
+	for _, query := range []string{"radius=0", "radius=50001", "radius=x"} {
+		w := httptest.NewRecorder()
+		req := pointRequest("GET", "1", "")
+		req.URL.RawQuery = query
+		OverflightsHandler(mock, &mockSpatial{})(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package api's positions endpoint returns stored fixes within an area
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/spatial"
+)
+
+// defaultPositionsWindow is used when no time window is given
+const defaultPositionsWindow = time.Hour
+
+type Position struct {
+	ID       int32     `json:"id"`
+	Icao24   string    `json:"icao24"`
+	Callsign string    `json:"callsign"`
+	Time     time.Time `json:"time"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Altitude *float64  `json:"altitude"`
+	Category int32     `json:"category"`
+}
+
+type PositionsQuerier interface {
+	GetGeofence(ctx context.Context, id int32) (repository.Geofence, error)
+}
+
+// PositionsHandler returns the fixes inside exactly one of bbox=, near=lat,lon
+// with radius= in meters, or geofence= by id. Without from the window is the
+// hour before to, or the last hour.
+func PositionsHandler(queries PositionsQuerier, positions spatial.Repository) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+
+		from, to, err := timeWindow(req, defaultPositionsWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		areas := 0
+		for _, name := range []string{"bbox", "near", "geofence"} {
+			if query.Get(name) != "" {
+				areas++
+			}
+		}
+		if areas != 1 {
+			http.Error(res, "exactly one of bbox, near or geofence is required", http.StatusBadRequest)
+			return
+		}
+
+		var rows []spatial.Position
+		switch {
+		case query.Get("bbox") != "":
+			var box opensky.BoundingBox
+			box, err = parseBBox(query.Get("bbox"))
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rows, err = positions.InBBox(req.Context(), box, from, to)
+
+		case query.Get("near") != "":
+			var lat, lon, radiusM float64
+			lat, lon, err = parseLatLon(query.Get("near"))
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			radiusM, err = strconv.ParseFloat(query.Get("radius"), 64)
+			if err != nil || radiusM <= 0 {
+				http.Error(res, "invalid radius", http.StatusBadRequest)
+				return
+			}
+			rows, err = positions.Near(req.Context(), lat, lon, radiusM, from, to)
+
+		default:
+			var id int64
+			id, err = strconv.ParseInt(query.Get("geofence"), 10, 32)
+			if err != nil {
+				http.Error(res, "invalid geofence", http.StatusBadRequest)
+				return
+			}
+			var fence repository.Geofence
+			fence, err = queries.GetGeofence(req.Context(), int32(id))
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(res, "geofence not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(res, "error fetching geofence", http.StatusInternalServerError)
+				return
+			}
+			var polygon geojson.Polygon
+			polygon, err = geojson.ParsePolygon(fence.Geometry)
+			if err != nil {
+				http.Error(res, "invalid geofence geometry", http.StatusInternalServerError)
+				return
+			}
+			rows, err = positions.InPolygon(req.Context(), polygon, from, to)
+		}
+		if err != nil {
+			http.Error(res, "error fetching positions", http.StatusInternalServerError)
+			return
+		}
+
+		result := make([]Position, 0, len(rows))
+		for _, row := range rows {
+			p := Position{
+				ID:       row.ID,
+				Icao24:   row.Icao24.String,
+				Callsign: strings.TrimSpace(row.Callsign.String),
+				Time:     row.TimePosition,
+				Lat:      row.Latitude.Float64,
+				Lon:      row.Longitude.Float64,
+				Category: row.Category.Int32,
+			}
+			if row.BaroAltitude.Valid {
+				p.Altitude = &row.BaroAltitude.Float64
+			}
+			result = append(result, p)
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(result)
+	}
+}
+
+// parseLatLon reads a lat,lon pair
+func parseLatLon(s string) (lat, lon float64, err error) {
+	latStr, lonStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, errors.New("near must be lat,lon")
+	}
+
+	lat, err = strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return 0, 0, errors.New("near must be lat,lon")
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil {
+		return 0, 0, errors.New("near must be lat,lon")
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, errors.New("invalid coordinates")
+	}
+
+	return lat, lon, nil
+}
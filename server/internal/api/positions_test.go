@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/spatial"
+)
+
+type mockSpatial struct {
+	call    string
+	radiusM float64
+	box     opensky.BoundingBox
+	polygon geojson.Polygon
+	from    sql.NullTime
+}
+
+func (m *mockSpatial) Near(ctx context.Context, lat, lon, radiusM float64, from, to sql.NullTime) ([]spatial.Position, error) {
+	m.call, m.radiusM, m.from = "near", radiusM, from
+	return []spatial.Position{{
+		ID:        1,
+		Icao24:    sql.NullString{String: "461f2b", Valid: true},
+		Callsign:  sql.NullString{String: "FIN7CN  ", Valid: true},
+		Latitude:  sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude: sql.NullFloat64{Float64: lon, Valid: true},
+	}}, nil
+}
+
+func (m *mockSpatial) InPolygon(ctx context.Context, polygon geojson.Polygon, from, to sql.NullTime) ([]spatial.Position, error) {
+	m.call, m.polygon, m.from = "polygon", polygon, from
+	return nil, nil
+}
+
+func (m *mockSpatial) InBBox(ctx context.Context, box opensky.BoundingBox, from, to sql.NullTime) ([]spatial.Position, error) {
+	m.call, m.box, m.from = "bbox", box, from
+	return nil, nil
+}
+
+type mockPositionsQueries struct{}
+
+func (mockPositionsQueries) GetGeofence(ctx context.Context, id int32) (repository.Geofence, error) {
+	if id != 3 {
+		return repository.Geofence{}, sql.ErrNoRows
+	}
+	return repository.Geofence{
+		ID:       3,
+		Geometry: json.RawMessage(`{"type":"Polygon","coordinates":[[[24,60],[25,60],[25,61],[24,60]]]}`),
+	}, nil
+}
+
+func TestPositionsHandler(t *testing.T) {
+	mock := &mockSpatial{}
+	w := httptest.NewRecorder()
+	PositionsHandler(mockPositionsQueries{}, mock)(w, httptest.NewRequest("GET", "/api/positions?near=60.3,24.9&radius=1500", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if mock.call != "near" || mock.radiusM != 1500 {
+		t.Errorf("unexpected call %+v", mock)
+	}
+	if !mock.from.Valid {
+		t.Errorf("expected the last hour by default")
+	}
+
+	var positions []Position
+	if err := json.NewDecoder(w.Body).Decode(&positions); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+	if len(positions) != 1 || positions[0].Callsign != "FIN7CN" || positions[0].Lat != 60.3 || positions[0].Altitude != nil {
+		t.Errorf("unexpected positions %+v", positions)
+	}
+
+	w = httptest.NewRecorder()
+	PositionsHandler(mockPositionsQueries{}, mock)(w, httptest.NewRequest("GET", "/api/positions?bbox=170,10,-170,20", nil))
+	if w.Code != http.StatusOK || mock.call != "bbox" || mock.box.LonMin != 170 {
+		t.Errorf("unexpected bbox call %d %+v", w.Code, mock)
+	}
+
+	w = httptest.NewRecorder()
+	PositionsHandler(mockPositionsQueries{}, mock)(w, httptest.NewRequest("GET", "/api/positions?geofence=3", nil))
+	if w.Code != http.StatusOK || mock.call != "polygon" || len(mock.polygon[0]) != 4 {
+		t.Errorf("unexpected geofence call %d %+v", w.Code, mock)
+	}
+}
+
+func TestPositionsHandlerRejectsBadParams(t *testing.T) {
+	for query, code := range map[string]int{
+		"":                                     http.StatusBadRequest,
+		"bbox=24,60,25,61&near=60,24&radius=5": http.StatusBadRequest,
+		"near=60,24":                           http.StatusBadRequest,
+		"near=95,24&radius=100":                http.StatusBadRequest,
+		"bbox=1,2":                             http.StatusBadRequest,
+		"geofence=x":                           http.StatusBadRequest,
+		"geofence=4":                           http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		PositionsHandler(mockPositionsQueries{}, &mockSpatial{})(w, httptest.NewRequest("GET", "/api/positions?"+query, nil))
+
+		if w.Code != code {
+			t.Errorf("%s: expected %d, got %d", query, code, w.Code)
+		}
+	}
+}
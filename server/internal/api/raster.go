@@ -0,0 +1,237 @@
+// Package api's raster endpoints render the heatmap as PNG tiles and snapshots
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/raster"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/tile"
+)
+
+const (
+	rasterTileSize      = 256
+	defaultSnapshotSize = 512
+	maxSnapshotSize     = 2048
+	// defaultRasterRadius is the kernel's standard deviation in pixels
+	defaultRasterRadius = 10.0
+	maxRasterRadius     = 100.0
+)
+
+// RasterTileHandler serves /api/heatmap/{z}/{x}/{y}.png
+func RasterTileHandler(queries TileQuerier, timeZone string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		t, err := tile.Parse(req.PathValue("z"), req.PathValue("x"), req.PathValue("y"), ".png")
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		params, err := tileBinParams(req, timeZone)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		opts, err := rasterOptions(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Width, opts.Height = rasterTileSize, rasterTileSize
+
+		latMin, lonMin, latMax, lonMax := t.Bounds()
+		box := opensky.BoundingBox{LatMin: latMin, LonMin: lonMin, LatMax: latMax, LonMax: lonMax}
+
+		project := func(lat, lon float64) (float64, float64) {
+			return t.Project(lat, lon, rasterTileSize)
+		}
+
+		points, err := rasterPoints(req.Context(), queries, params, box, tile.BinForZoom(t.Z), opts, project)
+		if err != nil {
+			http.Error(res, "error fetching heatmap", http.StatusInternalServerError)
+			return
+		}
+
+		setTileCache(res, params.ToTime)
+		writePNG(res, raster.Render(points, opts))
+	}
+}
+
+// HeatmapSnapshotHandler serves /api/heatmap.png?bbox=&width=&height= as a
+// single Web Mercator image of the bounding box
+func HeatmapSnapshotHandler(queries TileQuerier, timeZone string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		box, err := parseBBox(req.URL.Query().Get("bbox"))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		params, err := tileBinParams(req, timeZone)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		opts, err := rasterOptions(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if opts.Width, err = sizeParam(req, "width"); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if opts.Height, err = sizeParam(req, "height"); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// across the antimeridian the western longitudes continue past 180
+		wrap := crossesAntimeridian(box)
+		gapCentre := (box.LonMin + box.LonMax) / 2
+		unwrap := func(lon float64) float64 {
+			if wrap && lon < gapCentre {
+				return lon + 360
+			}
+			return lon
+		}
+
+		x0, y0 := tile.Mercator(box.LatMax, box.LonMin)
+		x1, y1 := tile.Mercator(box.LatMin, unwrap(box.LonMax))
+		project := func(lat, lon float64) (float64, float64) {
+			x, y := tile.Mercator(lat, unwrap(lon))
+			return (x - x0) / (x1 - x0) * float64(opts.Width), (y - y0) / (y1 - y0) * float64(opts.Height)
+		}
+
+		// use the bin a map showing the same area at this width would use
+		zoom := int(math.Floor(math.Log2(float64(opts.Width) / (rasterTileSize * (x1 - x0)))))
+		bin := tile.BinForZoom(zoom)
+		if v := req.URL.Query().Get("bin"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(res, "invalid bin", http.StatusBadRequest)
+				return
+			}
+			bin = parsed
+		}
+
+		points, err := rasterPoints(req.Context(), queries, params, box, bin, opts, project)
+		if err != nil {
+			http.Error(res, "error fetching heatmap", http.StatusInternalServerError)
+			return
+		}
+
+		setTileCache(res, params.ToTime)
+		writePNG(res, raster.Render(points, opts))
+	}
+}
+
+// rasterOptions reads radius (pixels), max and ramp
+func rasterOptions(req *http.Request) (raster.Options, error) {
+	opts := raster.Options{Radius: defaultRasterRadius, Ramp: raster.Ramps["heat"]}
+
+	if v := req.URL.Query().Get("radius"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed <= 0 || parsed > maxRasterRadius {
+			return opts, errors.New("invalid radius")
+		}
+		opts.Radius = parsed
+	}
+
+	// a fixed max keeps neighbouring tiles on the same scale
+	if v := req.URL.Query().Get("max"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 {
+			return opts, errors.New("invalid max")
+		}
+		opts.Max = parsed
+	}
+
+	if v := req.URL.Query().Get("ramp"); v != "" {
+		ramp, err := raster.ParseRamp(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Ramp = ramp
+	}
+
+	return opts, nil
+}
+
+func sizeParam(req *http.Request, name string) (int, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return defaultSnapshotSize, nil
+	}
+
+	parsed, err := strconv.Atoi(v)
+	if err != nil || parsed <= 0 || parsed > maxSnapshotSize {
+		return 0, errors.New("invalid " + name)
+	}
+
+	return parsed, nil
+}
+
+// rasterPoints fetches the bins around box, widened by the kernel's reach
+// so density from just outside the image still bleeds in, and projects the
+// bin centres into pixels. params carries the window, filters and selectors.
+func rasterPoints(
+	ctx context.Context,
+	queries TileQuerier,
+	params repository.GetTileBinsParams,
+	box opensky.BoundingBox,
+	bin int,
+	opts raster.Options,
+	project func(lat, lon float64) (float64, float64),
+) ([]raster.Point, error) {
+	reach := 3 * opts.Radius
+	padLat := reach * (box.LatMax - box.LatMin) / float64(opts.Height)
+	padLon := reach * lonSpan(box) / float64(opts.Width)
+
+	size := 1 / float64(bin)
+	params.BinSize = sql.NullFloat64{Float64: float64(bin), Valid: true}
+	params.LatMin = sql.NullFloat64{Float64: math.Floor((box.LatMin-padLat)*float64(bin)) * size, Valid: true}
+	params.LatMax = sql.NullFloat64{Float64: math.Ceil((box.LatMax+padLat)*float64(bin)) * size, Valid: true}
+	params.LonMin = sql.NullFloat64{Float64: math.Floor((box.LonMin-padLon)*float64(bin)) * size, Valid: true}
+	params.LonMax = sql.NullFloat64{Float64: math.Ceil((box.LonMax+padLon)*float64(bin)) * size, Valid: true}
+	rows, err := queries.GetTileBins(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]raster.Point, 0, len(rows))
+	for _, row := range rows {
+		if !row.LatBin.Valid || !row.LonBin.Valid {
+			continue
+		}
+
+		x, y := project(row.LatBin.Float64+size/2, row.LonBin.Float64+size/2)
+		points = append(points, raster.Point{X: x, Y: y, Weight: float64(row.Count)})
+	}
+
+	return points, nil
+}
+
+// writePNG encodes into a buffer first so a failure can still be reported
+func writePNG(res http.ResponseWriter, img *image.NRGBA) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(res, "error encoding image", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "image/png")
+	res.Write(buf.Bytes())
+}
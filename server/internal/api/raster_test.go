@@ -0,0 +1,99 @@
+package api
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeatmapSnapshotHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/heatmap.png?bbox=24.5,60.0,25.5,60.6&width=300&height=200&ramp=viridis", nil)
+	w := httptest.NewRecorder()
+
+	mock := &mockTileQueries{}
+	HeatmapSnapshotHandler(mock, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("response is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 200 {
+		t.Errorf("unexpected size %v", b)
+	}
+
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("expected a transparent background")
+	}
+
+	opaque := 0
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+				opaque++
+			}
+		}
+	}
+	if opaque == 0 {
+		t.Error("expected the bins to be drawn")
+	}
+
+	if mock.args.LatMin.Float64 >= 60.0 || mock.args.LonMax.Float64 <= 25.5 {
+		t.Errorf("expected the query to cover the kernel padding: %+v", mock.args)
+	}
+}
+
+func TestRasterTileHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/heatmap/10/583/295.png?max=5", nil)
+	req.SetPathValue("z", "10")
+	req.SetPathValue("x", "583")
+	req.SetPathValue("y", "295.png")
+	w := httptest.NewRecorder()
+
+	RasterTileHandler(&mockTileQueries{}, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("response is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Errorf("unexpected size %v", b)
+	}
+}
+
+func TestRasterHandlersRejectBadParams(t *testing.T) {
+	for _, target := range []string{
+		"/api/heatmap.png",
+		"/api/heatmap.png?bbox=24,61,25,60",
+		"/api/heatmap.png?bbox=24,60,25,61&width=5000",
+		"/api/heatmap.png?bbox=24,60,25,61&ramp=rainbow",
+		"/api/heatmap.png?bbox=24,60,25,61&radius=-1",
+	} {
+		w := httptest.NewRecorder()
+		HeatmapSnapshotHandler(&mockTileQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", target, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestHeatmapSnapshotAcrossAntimeridian(t *testing.T) {
+	w := httptest.NewRecorder()
+	HeatmapSnapshotHandler(&mockTileQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap.png?bbox=170,-20,-170,10&width=100&height=100", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/rollup"
+)
+
+type RollupQuerier interface {
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	GetRollupBins(ctx context.Context, args repository.GetRollupBinsParams) ([]repository.GetRollupBinsRow, error)
+}
+
+// canUseRollups reports whether the rollups hold everything a request needs.
+// They only keep counts per bin, so filters and selectors go to raw data.
+func canUseRollups(binSize int, filter heatmapFilter, hours, weekdays sql.NullString) bool {
+	return slices.Contains(rollup.BinSizes, binSize) && filter == (heatmapFilter{}) && !hours.Valid && !weekdays.Valid
+}
+
+// rollupHeatmap answers the whole hours up to the watermark from the coarsest
+// rollups, and only the partial hour at the start and the recent window after
+// the watermark from raw data. Merged bins carry counts only. ok is false when
+// the rollups cover none of the window, and the caller bins raw data instead.
+func rollupHeatmap(ctx context.Context, queries HeatmapQuerier, params repository.GetHeatmapDataDynamicParams) (points []HeatPoint, ok bool, err error) {
+	watermark, err := queries.GetRollupWatermark(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var start time.Time
+	if params.FromTime.Valid {
+		start = ceil(params.FromTime.Time, time.Hour)
+	}
+	end := watermark
+	if params.ToTime.Valid && params.ToTime.Time.Before(end) {
+		end = params.ToTime.Time.Truncate(time.Hour)
+	}
+	if !start.Before(end) {
+		return nil, false, nil
+	}
+
+	dayFrom, dayTo := ceil(start, 24*time.Hour), end.Truncate(24*time.Hour)
+	if dayTo.Before(dayFrom) {
+		dayTo = dayFrom
+	}
+
+	rows, err := queries.GetRollupBins(ctx, repository.GetRollupBinsParams{
+		BinSize:  int32(params.BinSize.Float64),
+		DayFrom:  dayFrom,
+		DayTo:    dayTo,
+		FromTime: start,
+		ToTime:   end,
+		LatMin:   params.LatMin,
+		LatMax:   params.LatMax,
+		LonMin:   params.LonMin,
+		LonMax:   params.LonMax,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	points = []HeatPoint{}
+	index := map[[2]float64]int{}
+	add := func(p HeatPoint) {
+		key := [2]float64{p.Lat, p.Lon}
+		if i, ok := index[key]; ok {
+			points[i].Count += p.Count
+			return
+		}
+		index[key] = len(points)
+		points = append(points, HeatPoint{Lat: p.Lat, Lon: p.Lon, Count: p.Count})
+	}
+
+	for _, row := range rows {
+		if row.LatBin.Valid && row.LonBin.Valid {
+			add(HeatPoint{Lat: row.LatBin.Float64, Lon: row.LonBin.Float64, Count: row.Count})
+		}
+	}
+
+	var raw []repository.GetHeatmapDataDynamicParams
+	if params.FromTime.Valid && params.FromTime.Time.Before(start) {
+		head := params
+		head.ToTime = sql.NullTime{Time: start, Valid: true}
+		raw = append(raw, head)
+	}
+	if !params.ToTime.Valid || end.Before(params.ToTime.Time) {
+		tail := params
+		tail.FromTime = sql.NullTime{Time: end, Valid: true}
+		raw = append(raw, tail)
+	}
+
+	for _, p := range raw {
+		recent, err := valueHeatmap(ctx, queries, p, metricCount)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, point := range recent {
+			add(point)
+		}
+	}
+
+	return points, true, nil
+}
+
+func ceil(t time.Time, d time.Duration) time.Time {
+	if truncated := t.Truncate(d); truncated.Before(t) {
+		return truncated.Add(d)
+	}
+	return t
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isoWeekdays maps day names to ISO day numbers as used by Postgres' isodow
+var isoWeekdays = map[string]int{"mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6, "sun": 7}
+
+var weekdayNames = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// parseTimeSelectors reads hours and weekdays as comma separated lists for
+// the query. hours takes hours and ranges such as 6-9,22-5 where the end is
+// exclusive (6-9 is 06:00 to 08:59) and ranges may wrap past midnight.
+// weekdays takes names and inclusive ranges such as mon-fri or sat,sun.
+func parseTimeSelectors(req *http.Request) (hours, weekdays sql.NullString, err error) {
+	if v := req.URL.Query().Get("hours"); v != "" {
+		selected, err := parseSelector(v, 0, 23, true, func(s string) (int, bool) {
+			h, err := strconv.Atoi(s)
+			return h, err == nil && h >= 0 && h <= 24
+		})
+		if err != nil {
+			return hours, weekdays, errors.New("invalid hours")
+		}
+		hours = sql.NullString{String: selected, Valid: true}
+	}
+
+	if v := req.URL.Query().Get("weekdays"); v != "" {
+		selected, err := parseSelector(strings.ToLower(v), 1, 7, false, func(s string) (int, bool) {
+			d, ok := isoWeekdays[s]
+			return d, ok
+		})
+		if err != nil {
+			return hours, weekdays, errors.New("invalid weekdays")
+		}
+		weekdays = sql.NullString{String: selected, Valid: true}
+	}
+
+	return hours, weekdays, nil
+}
+
+// parseSelector expands a list of values and ranges between lo and hi into
+// a sorted comma separated list. Ranges past hi wrap around to lo.
+func parseSelector(s string, lo, hi int, exclusiveEnd bool, parse func(string) (int, bool)) (string, error) {
+	span := hi - lo + 1
+	selected := map[int]bool{}
+
+	for _, part := range strings.Split(s, ",") {
+		startStr, endStr, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		start, ok := parse(startStr)
+		if !ok || start > hi {
+			return "", errors.New("invalid value")
+		}
+		if !isRange {
+			selected[start] = true
+			continue
+		}
+
+		end, ok := parse(endStr)
+		if !ok {
+			return "", errors.New("invalid value")
+		}
+		if !exclusiveEnd {
+			end++
+		}
+
+		diff := end - start
+		if diff == 0 {
+			return "", errors.New("empty range")
+		}
+		count := (diff%span + span) % span
+		if count == 0 {
+			count = span // the whole day or week
+		}
+		for i := range count {
+			selected[lo+(start-lo+i)%span] = true
+		}
+	}
+
+	values := make([]int, 0, len(selected))
+	for v := range selected {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+
+	return strings.Join(parts, ","), nil
+}
@@ -0,0 +1,154 @@
+// Package api's stats endpoints summarise traffic volume over time
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// timeseriesBuckets are the supported bucket sizes
+var timeseriesBuckets = map[string]time.Duration{
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+const (
+	// defaultTimeseriesWindow is used when from is not given
+	defaultTimeseriesWindow = 24 * time.Hour
+	// maxTimeseriesBuckets bounds the size of a response
+	maxTimeseriesBuckets = 5000
+)
+
+type TimeseriesBucket struct {
+	Time      time.Time `json:"time"`
+	Fixes     int64     `json:"fixes"`
+	Aircraft  int64     `json:"aircraft"`
+	Callsigns int64     `json:"callsigns"`
+}
+
+// WeeklyMatrix holds traffic counts by local weekday (Monday first) and hour
+type WeeklyMatrix struct {
+	TimeZone string       `json:"time_zone"`
+	Weekdays []string     `json:"weekdays"`
+	Counts   [7][24]int64 `json:"counts"`
+}
+
+type StatsQuerier interface {
+	GetTimeseries(ctx context.Context, arg repository.GetTimeseriesParams) ([]repository.GetTimeseriesRow, error)
+	GetWeeklyMatrix(ctx context.Context, arg repository.GetWeeklyMatrixParams) ([]repository.GetWeeklyMatrixRow, error)
+}
+
+// TimeseriesHandler returns traffic per bucket (5m, 1h or 1d, in UTC) with
+// empty buckets filled in. It takes the heatmap's bbox and attribute filters.
+func TimeseriesHandler(queries StatsQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		bucket, ok := timeseriesBuckets[req.URL.Query().Get("bucket")]
+		if !ok {
+			http.Error(res, "bucket must be 5m, 1h or 1d", http.StatusBadRequest)
+			return
+		}
+
+		from, to, err := timeWindow(req, defaultTimeseriesWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.Time.Sub(from.Time)/bucket > maxTimeseriesBuckets {
+			http.Error(res, "too many buckets, use a larger bucket", http.StatusBadRequest)
+			return
+		}
+
+		latMin, latMax, lonMin, lonMax, err := bboxParams(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := parseHeatmapFilter(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := queries.GetTimeseries(req.Context(), repository.GetTimeseriesParams{
+			BucketSeconds:  int32(bucket / time.Second),
+			FromTime:       from.Time,
+			ToTime:         to.Time,
+			LatMin:         latMin,
+			LatMax:         latMax,
+			LonMin:         lonMin,
+			LonMax:         lonMax,
+			AltMin:         filter.AltMin,
+			AltMax:         filter.AltMax,
+			VelocityMin:    filter.VelocityMin,
+			VelocityMax:    filter.VelocityMax,
+			VerticalSign:   filter.VerticalSign,
+			CallsignPrefix: filter.CallsignPrefix,
+			Icao24List:     filter.Icao24List,
+			OriginCountry:  filter.OriginCountry,
+		})
+		if err != nil {
+			http.Error(res, "error fetching timeseries", http.StatusInternalServerError)
+			return
+		}
+
+		byTime := make(map[time.Time]repository.GetTimeseriesRow, len(rows))
+		for _, row := range rows {
+			byTime[row.Bucket.UTC()] = row
+		}
+
+		// Truncate lines up with date_bin's 2000-01-01 origin for these sizes
+		buckets := []TimeseriesBucket{}
+		for t := from.Time.Truncate(bucket); t.Before(to.Time); t = t.Add(bucket) {
+			row := byTime[t]
+			buckets = append(buckets, TimeseriesBucket{
+				Time:      t,
+				Fixes:     row.Fixes,
+				Aircraft:  row.Aircraft,
+				Callsigns: row.Callsigns,
+			})
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(buckets)
+	}
+}
+
+// WeeklyMatrixHandler returns a 24x7 matrix of fix counts for the whole
+// region in timeZone, over the time window or the last maxTimeWindow
+func WeeklyMatrixHandler(queries StatsQuerier, timeZone string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		from, to, err := timeWindow(req, maxTimeWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := queries.GetWeeklyMatrix(req.Context(), repository.GetWeeklyMatrixParams{
+			TimeZone: sql.NullString{String: timeZone, Valid: true},
+			FromTime: from,
+			ToTime:   to,
+		})
+		if err != nil {
+			http.Error(res, "error fetching weekly matrix", http.StatusInternalServerError)
+			return
+		}
+
+		matrix := WeeklyMatrix{TimeZone: timeZone, Weekdays: weekdayNames}
+		for _, row := range rows {
+			if row.Weekday < 1 || row.Weekday > 7 || row.Hour < 0 || row.Hour > 23 {
+				continue
+			}
+			matrix.Counts[row.Weekday-1][row.Hour] = row.Count
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(matrix)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockStatsQueries struct {
+	args repository.GetTimeseriesParams
+}
+
+func (m *mockStatsQueries) GetTimeseries(ctx context.Context, args repository.GetTimeseriesParams) ([]repository.GetTimeseriesRow, error) {
+	m.args = args
+	return []repository.GetTimeseriesRow{
+		{Bucket: time.Date(2026, 10, 13, 6, 0, 0, 0, time.UTC), Fixes: 120, Aircraft: 9, Callsigns: 8},
+		{Bucket: time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC), Fixes: 40, Aircraft: 3, Callsigns: 3},
+	}, nil
+}
+
+func (m *mockStatsQueries) GetWeeklyMatrix(ctx context.Context, args repository.GetWeeklyMatrixParams) ([]repository.GetWeeklyMatrixRow, error) {
+	return []repository.GetWeeklyMatrixRow{
+		{Weekday: 1, Hour: 7, Count: 42},
+		{Weekday: 7, Hour: 23, Count: 3},
+	}, nil
+}
+
+func TestTimeseriesHandler(t *testing.T) {
+	mock := &mockStatsQueries{}
+	w := httptest.NewRecorder()
+	TimeseriesHandler(mock)(w, httptest.NewRequest("GET",
+		"/api/stats/timeseries?bucket=1h&from=2026-10-13T06:00:00Z&to=2026-10-13T09:00:00Z&bbox=24,60,26,61&callsign=FIN", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if mock.args.BucketSeconds != 3600 || !mock.args.LatMin.Valid || mock.args.CallsignPrefix.String != "FIN" {
+		t.Errorf("unexpected query args: %+v", mock.args)
+	}
+
+	var buckets []TimeseriesBucket
+	if err := json.NewDecoder(w.Body).Decode(&buckets); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+
+	if len(buckets) != 3 {
+		t.Fatalf("expected 3 hourly buckets, got %d", len(buckets))
+	}
+	if buckets[0].Fixes != 120 || buckets[0].Aircraft != 9 || buckets[0].Callsigns != 8 {
+		t.Errorf("unexpected first bucket: %+v", buckets[0])
+	}
+	if buckets[1].Fixes != 0 || !buckets[1].Time.Equal(time.Date(2026, 10, 13, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected an empty 07:00 bucket, got %+v", buckets[1])
+	}
+	if buckets[2].Fixes != 40 {
+		t.Errorf("unexpected last bucket: %+v", buckets[2])
+	}
+}
+
+func TestTimeseriesHandlerRejectsBadParams(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"bucket=2h",
+		"bucket=5m&from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z",
+		"bucket=1h&bbox=1,2",
+		"bucket=1h&vertical=sideways",
+	} {
+		w := httptest.NewRecorder()
+		TimeseriesHandler(&mockStatsQueries{})(w, httptest.NewRequest("GET", "/api/stats/timeseries?"+query, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestWeeklyMatrixHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	WeeklyMatrixHandler(&mockStatsQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/stats/weekly", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+
+	var matrix WeeklyMatrix
+	if err := json.NewDecoder(w.Body).Decode(&matrix); err != nil {
+		t.Fatal("invalid JSON response")
+	}
+
+	if matrix.TimeZone != "Europe/Helsinki" || len(matrix.Weekdays) != 7 || matrix.Weekdays[0] != "mon" {
+		t.Errorf("unexpected matrix header: %+v", matrix)
+	}
+	if matrix.Counts[0][7] != 42 || matrix.Counts[6][23] != 3 || matrix.Counts[3][12] != 0 {
+		t.Errorf("unexpected counts: %+v", matrix.Counts)
+	}
+}
@@ -0,0 +1,75 @@
+// Package api's stream endpoint pushes each poll's positions as server-sent events
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/stream"
+)
+
+// heartbeatInterval keeps idle connections from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+type StreamSubscriber interface {
+	Subscribe(lastID uint64) ([]stream.Event, <-chan stream.Event, func())
+}
+
+func StreamHandler(broker StreamSubscriber) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var lastID uint64
+		if v := req.Header.Get("Last-Event-ID"); v != "" {
+			parsed, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(res, "invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+			lastID = parsed
+		}
+
+		rc := http.NewResponseController(res)
+
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("Connection", "keep-alive")
+		res.WriteHeader(http.StatusOK)
+
+		replay, events, cancel := broker.Subscribe(lastID)
+		defer cancel()
+
+		for _, ev := range replay {
+			writeEvent(res, ev)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					// dropped for falling behind, the client reconnects and resumes
+					return
+				}
+				writeEvent(res, ev)
+			case <-heartbeat.C:
+				fmt.Fprint(res, ": heartbeat\n\n")
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(res http.ResponseWriter, ev stream.Event) {
+	fmt.Fprintf(res, "id: %d\nevent: positions\ndata: %s\n\n", ev.ID, ev.Data)
+}
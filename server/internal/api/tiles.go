@@ -0,0 +1,157 @@
+// Package api's tile endpoint serves heatmap bins as Mapbox Vector Tiles
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/mvt"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/tile"
+)
+
+const (
+	// tileLayer is the name of the layer holding the heatmap cells
+	tileLayer = "heatmap"
+	// settleDelay is how long after a window ends before late fixes stop
+	// arriving and its tiles can be cached longer
+	settleDelay = 5 * time.Minute
+	// settledMaxAge bounds how long settled tiles are cached, as retention
+	// still thins and expires their fixes later
+	settledMaxAge = time.Hour
+)
+
+type TileQuerier interface {
+	GetTileBins(ctx context.Context, arg repository.GetTileBinsParams) ([]repository.GetTileBinsRow, error)
+}
+
+// tileBinParams reads the time window, filters and hour and weekday selectors
+// the tile endpoints share with HeatmapHandler. Selectors are evaluated in
+// timeZone. The bin and bounding box are left to the caller.
+func tileBinParams(req *http.Request, timeZone string) (repository.GetTileBinsParams, error) {
+	var params repository.GetTileBinsParams
+
+	from, to, err := timeWindow(req, maxTimeWindow)
+	if err != nil {
+		return params, err
+	}
+
+	filter, err := parseHeatmapFilter(req)
+	if err != nil {
+		return params, err
+	}
+
+	hours, weekdays, err := parseTimeSelectors(req)
+	if err != nil {
+		return params, err
+	}
+
+	return repository.GetTileBinsParams{
+		FromTime:       from,
+		ToTime:         to,
+		AltMin:         filter.AltMin,
+		AltMax:         filter.AltMax,
+		VelocityMin:    filter.VelocityMin,
+		VelocityMax:    filter.VelocityMax,
+		VerticalSign:   filter.VerticalSign,
+		CallsignPrefix: filter.CallsignPrefix,
+		Icao24List:     filter.Icao24List,
+		OriginCountry:  filter.OriginCountry,
+		Hours:          hours,
+		TimeZone:       sql.NullString{String: timeZone, Valid: true},
+		Weekdays:       weekdays,
+	}, nil
+}
+
+// VectorTileHandler serves /api/tiles/{z}/{x}/{y}.mvt. The bin size follows
+// from the zoom level and each cell is clipped to the tile.
+func VectorTileHandler(queries TileQuerier, timeZone string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		t, err := tile.Parse(req.PathValue("z"), req.PathValue("x"), req.PathValue("y"), ".mvt")
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		params, err := tileBinParams(req, timeZone)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bin := float64(tile.BinForZoom(t.Z))
+		latMin, lonMin, latMax, lonMax := t.Bounds()
+
+		// widen to whole cells so cells on the tile edge get their full count
+		params.BinSize = sql.NullFloat64{Float64: bin, Valid: true}
+		params.LatMin = sql.NullFloat64{Float64: math.Floor(latMin*bin) / bin, Valid: true}
+		params.LatMax = sql.NullFloat64{Float64: math.Ceil(latMax*bin) / bin, Valid: true}
+		params.LonMin = sql.NullFloat64{Float64: math.Floor(lonMin*bin) / bin, Valid: true}
+		params.LonMax = sql.NullFloat64{Float64: math.Ceil(lonMax*bin) / bin, Valid: true}
+		rows, err := queries.GetTileBins(req.Context(), params)
+		if err != nil {
+			http.Error(res, "error fetching tile", http.StatusInternalServerError)
+			return
+		}
+
+		features := make([]mvt.Feature, 0, len(rows))
+		for _, row := range rows {
+			if !row.LatBin.Valid || !row.LonBin.Valid {
+				continue
+			}
+
+			ring, ok := tileCell(t, row.LatBin.Float64, row.LonBin.Float64, 1/bin)
+			if !ok {
+				continue
+			}
+
+			features = append(features, mvt.Feature{
+				ID:    uint64(len(features) + 1),
+				Rings: [][][2]int{ring},
+				Properties: map[string]any{
+					"count": row.Count,
+					"bin":   int64(bin),
+				},
+			})
+		}
+
+		setTileCache(res, params.ToTime)
+		res.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+		res.Write(mvt.Encode(mvt.Layer{Name: tileLayer, Extent: mvt.DefaultExtent, Features: features}))
+	}
+}
+
+// setTileCache lets tiles of windows that ended a while ago be cached for
+// up to settledMaxAge, as they only change when retention catches up
+func setTileCache(res http.ResponseWriter, to sql.NullTime) {
+	if to.Valid && time.Since(to.Time) > settleDelay {
+		res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(settledMaxAge.Seconds())))
+		return
+	}
+
+	res.Header().Set("Cache-Control", "public, max-age=30")
+}
+
+// tileCell projects a grid cell into tile coordinates and clips it to the
+// tile. ok is false when nothing of the cell is left.
+func tileCell(t tile.Tile, lat, lon, size float64) (ring [][2]int, ok bool) {
+	const extent = mvt.DefaultExtent
+
+	x0, y0 := t.Project(lat+size, lon, extent)
+	x1, y1 := t.Project(lat, lon+size, extent)
+
+	clip := func(v float64) int {
+		return int(math.Round(math.Max(0, math.Min(extent, v))))
+	}
+	left, top, right, bottom := clip(x0), clip(y0), clip(x1), clip(y1)
+	if left >= right || top >= bottom {
+		return nil, false
+	}
+
+	// clockwise on screen, as the spec requires for exterior rings
+	return [][2]int{{left, top}, {right, top}, {right, bottom}, {left, bottom}}, true
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/mvt"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockTileQueries struct {
+	args repository.GetTileBinsParams
+}
+
+func (m *mockTileQueries) GetTileBins(ctx context.Context, args repository.GetTileBinsParams) ([]repository.GetTileBinsRow, error) {
+	m.args = args
+	return []repository.GetTileBinsRow{
+		// inside the tile
+		{LatBin: sql.NullFloat64{Float64: 60.325, Valid: true}, LonBin: sql.NullFloat64{Float64: 25.0, Valid: true}, Count: 7},
+		// straddles the tile's west edge at 24.9609
+		{LatBin: sql.NullFloat64{Float64: 60.3, Valid: true}, LonBin: sql.NullFloat64{Float64: 24.95, Valid: true}, Count: 3},
+		// outside the tile
+		{LatBin: sql.NullFloat64{Float64: 61.0, Valid: true}, LonBin: sql.NullFloat64{Float64: 25.0, Valid: true}, Count: 1},
+	}, nil
+}
+
+func TestVectorTileHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/tiles/10/583/295.mvt?from=2026-01-01T00:00:00Z&to=2026-01-02T00:00:00Z", nil)
+	req.SetPathValue("z", "10")
+	req.SetPathValue("x", "583")
+	req.SetPathValue("y", "295.mvt")
+	w := httptest.NewRecorder()
+
+	mock := &mockTileQueries{}
+	VectorTileHandler(mock, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("expected past window to be cached, got %q", cc)
+	}
+	if mock.args.BinSize.Float64 != 40 || !mock.args.FromTime.Valid || !mock.args.ToTime.Valid {
+		t.Errorf("unexpected query args: %+v", mock.args)
+	}
+
+	layers, err := mvt.Decode(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("tile does not decode: %v", err)
+	}
+	if len(layers) != 1 || layers[0].Name != "heatmap" {
+		t.Fatalf("unexpected layers: %+v", layers)
+	}
+
+	features := layers[0].Features
+	if len(features) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(features))
+	}
+	if features[0].Properties["count"] != int64(7) {
+		t.Errorf("unexpected properties: %+v", features[0].Properties)
+	}
+
+	for _, f := range features {
+		for _, p := range f.Rings[0] {
+			if p[0] < 0 || p[0] > mvt.DefaultExtent || p[1] < 0 || p[1] > mvt.DefaultExtent {
+				t.Errorf("feature %d not clipped to the tile: %v", f.ID, f.Rings[0])
+			}
+		}
+	}
+	if features[1].Rings[0][0][0] != 0 {
+		t.Errorf("expected edge cell to be clipped at x=0, got %v", features[1].Rings[0])
+	}
+}
+
+func TestVectorTileHandlerRejectsBadTile(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/tiles/2/9/0.mvt", nil)
+	req.SetPathValue("z", "2")
+	req.SetPathValue("x", "9")
+	req.SetPathValue("y", "0.mvt")
+	w := httptest.NewRecorder()
+
+	VectorTileHandler(&mockTileQueries{}, "Europe/Helsinki")(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestTileHandlersPassTimeSelectors(t *testing.T) {
+	for name, serve := range map[string]func(*mockTileQueries, *httptest.ResponseRecorder){
+		"mvt": func(mock *mockTileQueries, w *httptest.ResponseRecorder) {
+			req := httptest.NewRequest("GET", "/api/tiles/10/583/295.mvt?hours=6-9&weekdays=mon-fri", nil)
+			req.SetPathValue("z", "10")
+			req.SetPathValue("x", "583")
+			req.SetPathValue("y", "295.mvt")
+			VectorTileHandler(mock, "Europe/Helsinki")(w, req)
+		},
+		"png tile": func(mock *mockTileQueries, w *httptest.ResponseRecorder) {
+			req := httptest.NewRequest("GET", "/api/heatmap/10/583/295.png?hours=6-9&weekdays=mon-fri", nil)
+			req.SetPathValue("z", "10")
+			req.SetPathValue("x", "583")
+			req.SetPathValue("y", "295.png")
+			RasterTileHandler(mock, "Europe/Helsinki")(w, req)
+		},
+		"snapshot": func(mock *mockTileQueries, w *httptest.ResponseRecorder) {
+			req := httptest.NewRequest("GET", "/api/heatmap.png?bbox=24,60,26,61&width=100&height=100&hours=6-9&weekdays=mon-fri", nil)
+			HeatmapSnapshotHandler(mock, "Europe/Helsinki")(w, req)
+		},
+	} {
+		mock := &mockTileQueries{}
+		w := httptest.NewRecorder()
+		serve(mock, w)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d: %s", name, w.Code, w.Body)
+		}
+		if mock.args.Hours.String != "6,7,8" || mock.args.Weekdays.String != "1,2,3,4,5" || mock.args.TimeZone.String != "Europe/Helsinki" {
+			t.Errorf("%s: selectors not passed on: %+v", name, mock.args)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	HeatmapSnapshotHandler(&mockTileQueries{}, "Europe/Helsinki")(w, httptest.NewRequest("GET", "/api/heatmap.png?bbox=24,60,26,61&hours=25", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid hours to be rejected, got %d", w.Code)
+	}
+}
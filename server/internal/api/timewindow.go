@@ -0,0 +1,72 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxTimeWindow caps how much history a bounded request may span
+const maxTimeWindow = 31 * 24 * time.Hour
+
+// timeWindow reads the optional from and to (RFC3339) parameters and
+// minutes, which is combined with whichever end is given: from=&minutes=
+// covers minutes after from, otherwise minutes counts back from to (or now).
+// to defaults to now and from to defaultSpan before to, so every window is
+// bounded, and it is capped to maxTimeWindow.
+func timeWindow(req *http.Request, defaultSpan time.Duration) (from, to sql.NullTime, err error) {
+	query := req.URL.Query()
+
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, errors.New("invalid from")
+		}
+		from = sql.NullTime{Time: parsed.UTC(), Valid: true}
+	}
+
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, errors.New("invalid to")
+		}
+		to = sql.NullTime{Time: parsed.UTC(), Valid: true}
+	}
+
+	if v := query.Get("minutes"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			return from, to, errors.New("invalid minutes")
+		}
+		span := time.Duration(minutes) * time.Minute
+
+		switch {
+		case from.Valid && to.Valid:
+			return from, to, errors.New("minutes cannot be combined with both from and to")
+		case from.Valid:
+			to = sql.NullTime{Time: from.Time.Add(span), Valid: true}
+		case to.Valid:
+			from = sql.NullTime{Time: to.Time.Add(-span), Valid: true}
+		default:
+			from = sql.NullTime{Time: time.Now().UTC().Add(-span), Valid: true}
+		}
+	}
+
+	if from.Valid && to.Valid && !from.Time.Before(to.Time) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	if !to.Valid {
+		to = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	if !from.Valid {
+		from = sql.NullTime{Time: to.Time.Add(-defaultSpan), Valid: true}
+	}
+	if to.Time.Sub(from.Time) > maxTimeWindow {
+		return from, to, errors.New("time range too long")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeWindowIsAlwaysBounded(t *testing.T) {
+	to := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		query    string
+		from, to time.Time
+	}{
+		{"to=2025-06-01T12:00:00Z", to.Add(-time.Hour), to},
+		{"to=2025-06-01T12:00:00Z&minutes=30", to.Add(-30 * time.Minute), to},
+		{"from=2025-06-01T11:00:00Z&to=2025-06-01T12:00:00Z", to.Add(-time.Hour), to},
+	} {
+		from, got, err := timeWindow(httptest.NewRequest("GET", "/?"+tc.query, nil), time.Hour)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.query, err)
+		}
+		if !from.Valid || !from.Time.Equal(tc.from) || !got.Valid || !got.Time.Equal(tc.to) {
+			t.Errorf("%s: expected %v to %v, got %v to %v", tc.query, tc.from, tc.to, from, got)
+		}
+	}
+}
+
+func TestTimeWindowDefaultsToNow(t *testing.T) {
+	before := time.Now().UTC()
+	from, to, err := timeWindow(httptest.NewRequest("GET", "/", nil), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !to.Valid || to.Time.Before(before) || time.Since(to.Time) > time.Minute {
+		t.Errorf("expected to to be now, got %v", to)
+	}
+	if !from.Valid || to.Time.Sub(from.Time) != 24*time.Hour {
+		t.Errorf("expected the default span before to, got %v", from)
+	}
+}
+
+func TestTimeWindowCapsEveryWindow(t *testing.T) {
+	for _, query := range []string{
+		"from=2025-01-01T00:00:00Z&to=2025-06-01T00:00:00Z",
+		// a from far in the past is bounded by now
+		"from=2025-01-01T00:00:00Z",
+		"minutes=50000",
+	} {
+		if _, _, err := timeWindow(httptest.NewRequest("GET", "/?"+query, nil), time.Hour); err == nil {
+			t.Errorf("%s: expected the window to be too long", query)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package api's track endpoint returns an aircraft's flight path as GeoJSON
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/track"
+)
+
+const (
+	// defaultTrackWindow is used when the window has no start
+	defaultTrackWindow = time.Hour
+	// maxTrackWindow bounds how much history a single request can scan
+	maxTrackWindow = 24 * time.Hour
+	// defaultTrackGap splits the path where no fixes were received for a while
+	defaultTrackGap = 5 * time.Minute
+)
+
+type TrackQuerier interface {
+	GetTrack(ctx context.Context, arg repository.GetTrackParams) ([]repository.GetTrackRow, error)
+}
+
+// AircraftTrackHandler returns a LineString Feature, or a MultiLineString when
+// the path has gaps longer than gap (seconds). The altitudes, speeds and times
+// properties mirror the shape of the coordinates.
+func AircraftTrackHandler(queries TrackQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		icao24 := strings.ToLower(req.PathValue("icao24"))
+		if !icao24Pattern.MatchString(icao24) {
+			http.Error(res, "invalid icao24", http.StatusBadRequest)
+			return
+		}
+
+		from, to, err := timeWindow(req, defaultTrackWindow)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !from.Time.Before(to.Time) {
+			http.Error(res, "from must be before to", http.StatusBadRequest)
+			return
+		}
+		if to.Time.Sub(from.Time) > maxTrackWindow {
+			http.Error(res, "time range too long", http.StatusBadRequest)
+			return
+		}
+
+		gap := defaultTrackGap
+		if v := req.URL.Query().Get("gap"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(res, "invalid gap", http.StatusBadRequest)
+				return
+			}
+			gap = time.Duration(parsed) * time.Second
+		}
+
+		var tolerance float64
+		if v := req.URL.Query().Get("tolerance"); v != "" {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed < 0 {
+				http.Error(res, "invalid tolerance", http.StatusBadRequest)
+				return
+			}
+			tolerance = parsed
+		}
+
+		rows, err := queries.GetTrack(req.Context(), repository.GetTrackParams{
+			Icao24:   sql.NullString{String: icao24, Valid: true},
+			FromTime: from.Time,
+			ToTime:   to.Time,
+		})
+		if err != nil {
+			http.Error(res, "error fetching track", http.StatusInternalServerError)
+			return
+		}
+
+		fixes := make([]track.Fix, 0, len(rows))
+		for _, row := range rows {
+			f := track.Fix{
+				Time: row.TimePosition,
+				Lat:  row.Latitude.Float64,
+				Lon:  row.Longitude.Float64,
+			}
+			if row.BaroAltitude.Valid {
+				f.Altitude = &row.BaroAltitude.Float64
+			}
+			if row.Velocity.Valid {
+				f.Velocity = &row.Velocity.Float64
+			}
+			fixes = append(fixes, f)
+		}
+
+		var (
+			lines     [][][2]float64
+			altitudes [][]*float64
+			speeds    [][]*float64
+			times     [][]time.Time
+		)
+		for _, segment := range track.Split(fixes, gap) {
+			// a single fix is not a line
+			if len(segment) < 2 {
+				continue
+			}
+
+			segment = track.Simplify(segment, tolerance)
+
+			line := make([][2]float64, 0, len(segment))
+			alts := make([]*float64, 0, len(segment))
+			spds := make([]*float64, 0, len(segment))
+			ts := make([]time.Time, 0, len(segment))
+			for _, f := range segment {
+				line = append(line, [2]float64{f.Lon, f.Lat})
+				alts = append(alts, f.Altitude)
+				spds = append(spds, f.Velocity)
+				ts = append(ts, f.Time)
+			}
+
+			lines = append(lines, line)
+			altitudes = append(altitudes, alts)
+			speeds = append(speeds, spds)
+			times = append(times, ts)
+		}
+
+		if len(lines) == 0 {
+			http.Error(res, "no track found", http.StatusNotFound)
+			return
+		}
+
+		properties := map[string]any{"icao24": icao24}
+		var geometry geojson.Geometry
+		if len(lines) == 1 {
+			geometry = geojson.Geometry{Type: "LineString", Coordinates: lines[0]}
+			properties["altitudes"] = altitudes[0]
+			properties["speeds"] = speeds[0]
+			properties["times"] = times[0]
+		} else {
+			geometry = geojson.Geometry{Type: "MultiLineString", Coordinates: lines}
+			properties["altitudes"] = altitudes
+			properties["speeds"] = speeds
+			properties["times"] = times
+		}
+
+		res.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(res).Encode(geojson.NewFeature(geometry, properties))
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockTrackQueries struct {
+	args *repository.GetTrackParams
+}
+
+func (m *mockTrackQueries) GetTrack(ctx context.Context, arg repository.GetTrackParams) ([]repository.GetTrackRow, error) {
+	m.args = &arg
+	at := arg.ToTime.Add(-time.Minute)
+	return []repository.GetTrackRow{
+		{TimePosition: at, Latitude: sql.NullFloat64{Float64: 60.3, Valid: true}, Longitude: sql.NullFloat64{Float64: 24.9, Valid: true}},
+		{TimePosition: at.Add(30 * time.Second), Latitude: sql.NullFloat64{Float64: 60.4, Valid: true}, Longitude: sql.NullFloat64{Float64: 25.0, Valid: true}},
+	}, nil
+}
+
+func trackRequest(icao24, query string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/aircraft/"+icao24+"/track?"+query, nil)
+	req.SetPathValue("icao24", icao24)
+	return req
+}
+
+func TestAircraftTrackHandlerWindow(t *testing.T) {
+	mock := &mockTrackQueries{}
+	w := httptest.NewRecorder()
+	AircraftTrackHandler(mock)(w, trackRequest("461E1F", "to=2025-06-01T12:00:00Z&minutes=30"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if mock.args.Icao24.String != "461e1f" {
+		t.Errorf("expected a lowercased icao24, got %q", mock.args.Icao24.String)
+	}
+	if !mock.args.FromTime.Equal(time.Date(2025, 6, 1, 11, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected from: %v", mock.args.FromTime)
+	}
+
+	// without a window the last hour is returned
+	AircraftTrackHandler(mock)(httptest.NewRecorder(), trackRequest("461e1f", ""))
+	if d := mock.args.ToTime.Sub(mock.args.FromTime); d != defaultTrackWindow {
+		t.Errorf("expected the default window, got %v", d)
+	}
+}
+
+func TestAircraftTrackHandlerRejectsBadParams(t *testing.T) {
+	for _, tc := range []struct{ icao24, query string }{
+		{"nothex", ""},
+		{"461e1f0", ""},
+		{"461e1f", "from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z"},
+		{"461e1f", "from=yesterday"},
+		{"461e1f", "gap=0"},
+	} {
+		w := httptest.NewRecorder()
+		AircraftTrackHandler(&mockTrackQueries{})(w, trackRequest(tc.icao24, tc.query))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s?%s: expected 400, got %d", tc.icao24, tc.query, w.Code)
+		}
+	}
+}
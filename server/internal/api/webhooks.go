@@ -0,0 +1,228 @@
+// Package api's webhook endpoints register endpoints and expose their delivery log
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
+)
+
+type Webhook struct {
+	ID        int32     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID         int32     `json:"id"`
+	DeliveryID string    `json:"delivery_id"`
+	Event      string    `json:"event"`
+	Attempt    int32     `json:"attempt"`
+	StatusCode *int32    `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int32     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeadLetter struct {
+	ID         int32           `json:"id"`
+	DeliveryID string          `json:"delivery_id"`
+	Event      string          `json:"event"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int32           `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type WebhookQuerier interface {
+	CreateWebhook(ctx context.Context, arg repository.CreateWebhookParams) (repository.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]repository.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int32) (int64, error)
+	ListWebhookDeliveries(ctx context.Context, webhookID int32) ([]repository.WebhookDelivery, error)
+	ListWebhookDeadLetters(ctx context.Context, webhookID int32) ([]repository.WebhookDeadLetter, error)
+}
+
+// WebhookCache is told when the registered webhooks change
+type WebhookCache interface {
+	Invalidate()
+}
+
+func ListWebhooksHandler(queries WebhookQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		rows, err := queries.ListWebhooks(req.Context())
+		if err != nil {
+			http.Error(res, "error fetching webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		hooks := make([]Webhook, 0, len(rows))
+		for _, row := range rows {
+			hook := toWebhook(row)
+			hook.Secret = "" // only returned on creation
+			hooks = append(hooks, hook)
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(hooks)
+	}
+}
+
+// CreateWebhookHandler registers an endpoint. A signing secret is generated
+// when none is given and returned only in this response.
+func CreateWebhookHandler(queries WebhookQuerier, cache WebhookCache) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var input Webhook
+		if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+			http.Error(res, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		target, err := url.Parse(input.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			http.Error(res, "invalid url", http.StatusBadRequest)
+			return
+		}
+
+		if input.Events == nil {
+			input.Events = []string{}
+		}
+		for _, event := range input.Events {
+			if !slices.Contains(webhook.Events, event) {
+				http.Error(res, "unknown event: "+event, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if input.Secret == "" {
+			input.Secret = webhook.NewSecret()
+		}
+
+		events, _ := json.Marshal(input.Events)
+		row, err := queries.CreateWebhook(req.Context(), repository.CreateWebhookParams{
+			Url:    sql.NullString{String: target.String(), Valid: true},
+			Secret: sql.NullString{String: input.Secret, Valid: true},
+			Events: events,
+		})
+		if err != nil {
+			http.Error(res, "error creating webhook", http.StatusInternalServerError)
+			return
+		}
+		cache.Invalidate()
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(toWebhook(row))
+	}
+}
+
+func DeleteWebhookHandler(queries WebhookQuerier, cache WebhookCache) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		deleted, err := queries.DeleteWebhook(req.Context(), id)
+		if err != nil {
+			http.Error(res, "error deleting webhook", http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			http.Error(res, "webhook not found", http.StatusNotFound)
+			return
+		}
+		cache.Invalidate()
+
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func WebhookDeliveriesHandler(queries WebhookQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		rows, err := queries.ListWebhookDeliveries(req.Context(), id)
+		if err != nil {
+			http.Error(res, "error fetching deliveries", http.StatusInternalServerError)
+			return
+		}
+
+		deliveries := make([]WebhookDelivery, 0, len(rows))
+		for _, row := range rows {
+			delivery := WebhookDelivery{
+				ID:         row.ID,
+				DeliveryID: row.DeliveryID.String,
+				Event:      row.Event.String,
+				Attempt:    row.Attempt,
+				Error:      row.Error.String,
+				DurationMs: row.DurationMs,
+				CreatedAt:  row.CreatedAt,
+			}
+			if row.StatusCode.Valid {
+				delivery.StatusCode = &row.StatusCode.Int32
+			}
+			deliveries = append(deliveries, delivery)
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(deliveries)
+	}
+}
+
+func WebhookDeadLettersHandler(queries WebhookQuerier) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, ok := pathID(res, req)
+		if !ok {
+			return
+		}
+
+		rows, err := queries.ListWebhookDeadLetters(req.Context(), id)
+		if err != nil {
+			http.Error(res, "error fetching dead letters", http.StatusInternalServerError)
+			return
+		}
+
+		letters := make([]WebhookDeadLetter, 0, len(rows))
+		for _, row := range rows {
+			letters = append(letters, WebhookDeadLetter{
+				ID:         row.ID,
+				DeliveryID: row.DeliveryID.String,
+				Event:      row.Event.String,
+				Payload:    row.Payload,
+				Attempts:   row.Attempts,
+				LastError:  row.LastError.String,
+				CreatedAt:  row.CreatedAt,
+			})
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(letters)
+	}
+}
+
+func toWebhook(row repository.Webhook) Webhook {
+	events := []string{}
+	json.Unmarshal(row.Events, &events)
+
+	return Webhook{
+		ID:        row.ID,
+		URL:       row.Url.String,
+		Secret:    row.Secret.String,
+		Events:    events,
+		Active:    row.Active,
+		CreatedAt: row.CreatedAt,
+	}
+}
@@ -2,7 +2,10 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +14,26 @@ type Config struct {
 	DBURL        string
 	ClientID     string
 	ClientSecret string
+	// LiveMaxAge is how old a fix may be and still count as live
+	LiveMaxAge time.Duration
+	// TimeZone is the IANA zone hour and weekday selectors are evaluated in
+	TimeZone string
+	// RawRetention is how long every fix is kept before it is thinned
+	RawRetention time.Duration
+	// ThinInterval is how many fixes per aircraft thinning keeps, one per interval
+	ThinInterval time.Duration
+	// ThinnedRetention is how long thinned fixes are kept, after that their
+	// daily partitions expire and only the rollups remain
+	ThinnedRetention time.Duration
+	// RetentionInterval is how often retention is enforced
+	RetentionInterval time.Duration
+	// DetachPartitions keeps expired daily partitions as standalone tables
+	// instead of dropping them
+	DetachPartitions bool
+	// PostGIS answers the position and overflight lookups with PostGIS
+	// instead of plain Postgres, once scripts/enable-postgis.sh has set it up.
+	// Aggregating endpoints filter on lat/lon either way.
+	PostGIS bool
 }
 
 func Load() Config {
@@ -20,5 +43,45 @@ func Load() Config {
 		DBURL:        os.Getenv("DATABASE_URL"),
 		ClientID:     os.Getenv("OPEN_SKY_CLIENT_ID"),
 		ClientSecret: os.Getenv("OPEN_SKY_CLIENT_SECRET"),
+		LiveMaxAge:   durationEnv("LIVE_MAX_AGE", 2*time.Minute),
+		TimeZone:     timeZoneEnv("TIME_ZONE", "Europe/Helsinki"),
+
+		RawRetention:      daysEnv("RAW_RETENTION_DAYS", 30),
+		ThinInterval:      durationEnv("THIN_INTERVAL", time.Minute),
+		ThinnedRetention:  daysEnv("THINNED_RETENTION_DAYS", 180),
+		RetentionInterval: durationEnv("RETENTION_INTERVAL", time.Hour),
+		DetachPartitions:  os.Getenv("DETACH_PARTITIONS") == "true",
+		PostGIS:           os.Getenv("POSTGIS") == "true",
+	}
+}
+
+func daysEnv(key string, fallback int) time.Duration {
+	days, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || days <= 0 {
+		days = fallback
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
 	}
+
+	return fallback
+}
+
+func timeZoneEnv(key, fallback string) string {
+	zone := os.Getenv(key)
+	if zone == "" {
+		return fallback
+	}
+
+	if _, err := time.LoadLocation(zone); err != nil {
+		log.Printf("invalid %s %q, using %s", key, zone, fallback)
+		return fallback
+	}
+
+	return zone
 }
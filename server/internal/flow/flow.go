@@ -0,0 +1,38 @@
+// Package flow summarizes the headings of fixes in a cell. Headings wrap at
+// 360 degrees, so they are averaged as unit vectors: the mean of 350 and 10 is
+// 0, not 180.
+package flow
+
+import "math"
+
+// Sum returns the sums of the sines and cosines of headings in degrees, the
+// form Mean takes
+func Sum(headings []float64) (sinSum, cosSum float64) {
+	for _, h := range headings {
+		sinSum += math.Sin(h * math.Pi / 180)
+		cosSum += math.Cos(h * math.Pi / 180)
+	}
+
+	return sinSum, cosSum
+}
+
+// Mean returns the circular mean of n headings in degrees clockwise from
+// north, [0, 360), and their dispersion, the circular variance 1 - R where R
+// is the length of the mean unit vector. Dispersion is 0 when every heading
+// agrees and approaches 1 when they cancel out. ok is false when there is no
+// mean direction.
+func Mean(sinSum, cosSum float64, n int64) (heading, dispersion float64, ok bool) {
+	if n <= 0 {
+		return 0, 0, false
+	}
+
+	r := math.Hypot(sinSum, cosSum) / float64(n)
+	if r < 1e-9 {
+		return 0, 1, false
+	}
+
+	// a heading a hair below 0 rounds to 360 when shifted, Mod folds it to 0
+	heading = math.Mod(math.Atan2(sinSum, cosSum)*180/math.Pi+360, 360)
+
+	return heading, 1 - r, true
+}
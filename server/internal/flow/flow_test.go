@@ -0,0 +1,57 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/flow"
+)
+
+func TestMeanWrapsAroundNorth(t *testing.T) {
+	for _, tc := range []struct {
+		headings []float64
+		want     float64
+	}{
+		{[]float64{350, 10}, 0},
+		{[]float64{340, 350, 0}, 350},
+		{[]float64{90, 90, 90}, 90},
+		{[]float64{170, 190}, 180},
+		{[]float64{260, 280}, 270},
+	} {
+		sinSum, cosSum := flow.Sum(tc.headings)
+		heading, _, ok := flow.Mean(sinSum, cosSum, int64(len(tc.headings)))
+		if !ok {
+			t.Fatalf("%v: expected a mean", tc.headings)
+		}
+		// 0 and 360 are the same heading
+		if d := math.Mod(heading-tc.want+540, 360) - 180; math.Abs(d) > 1e-9 {
+			t.Errorf("%v: expected %v, got %v", tc.headings, tc.want, heading)
+		}
+		if heading < 0 || heading >= 360 {
+			t.Errorf("%v: heading %v out of range", tc.headings, heading)
+		}
+	}
+}
+
+func TestMeanDispersion(t *testing.T) {
+	sinSum, cosSum := flow.Sum([]float64{45, 45, 45})
+	if _, dispersion, _ := flow.Mean(sinSum, cosSum, 3); math.Abs(dispersion) > 1e-9 {
+		t.Errorf("expected no dispersion for equal headings, got %v", dispersion)
+	}
+
+	// a right angle apart, R is cos(45°)
+	sinSum, cosSum = flow.Sum([]float64{0, 90})
+	if _, dispersion, _ := flow.Mean(sinSum, cosSum, 2); math.Abs(dispersion-(1-math.Sqrt2/2)) > 1e-9 {
+		t.Errorf("unexpected dispersion %v", dispersion)
+	}
+
+	// opposite headings have no mean direction
+	sinSum, cosSum = flow.Sum([]float64{0, 180})
+	if _, dispersion, ok := flow.Mean(sinSum, cosSum, 2); ok || dispersion != 1 {
+		t.Errorf("expected no mean for opposite headings, got dispersion %v", dispersion)
+	}
+
+	if _, _, ok := flow.Mean(0, 0, 0); ok {
+		t.Error("expected no mean without headings")
+	}
+}
@@ -0,0 +1,124 @@
+// Package geo projects WGS84 coordinates onto a local metric plane and lays
+// out square grids in meters on it
+package geo
+
+import "math"
+
+// TransverseMercator projects onto the GRS80 ellipsoid, which is within a
+// fraction of a millimeter of WGS84
+type TransverseMercator struct {
+	// CentralMeridian in degrees
+	CentralMeridian float64
+	Scale           float64
+	FalseEasting    float64
+	FalseNorthing   float64
+}
+
+// TM35FIN is ETRS-TM35FIN (EPSG:3067), the Finnish national grid. It is UTM
+// zone 35N and keeps scale error under 0.1% across the whole country.
+var TM35FIN = TransverseMercator{
+	CentralMeridian: 27,
+	Scale:           0.9996,
+	FalseEasting:    500000,
+}
+
+// GRS80 ellipsoid and the Krüger series coefficients derived from its third
+// flattening n
+const (
+	semiMajorAxis = 6378137.0
+	flattening    = 1 / 298.257222101
+)
+
+var (
+	thirdFlattening = flattening / (2 - flattening)
+	// rectifyingRadius is the radius of the sphere with the ellipsoid's meridian length
+	rectifyingRadius float64
+	alpha, beta      [3]float64
+	delta            [3]float64
+)
+
+func init() {
+	n := thirdFlattening
+	n2, n3 := n*n, n*n*n
+
+	rectifyingRadius = semiMajorAxis / (1 + n) * (1 + n2/4 + n2*n2/64)
+	alpha = [3]float64{n/2 - 2*n2/3 + 5*n3/16, 13*n2/48 - 3*n3/5, 61 * n3 / 240}
+	beta = [3]float64{n/2 - 2*n2/3 + 37*n3/96, n2/48 + n3/15, 17 * n3 / 480}
+	delta = [3]float64{2*n - 2*n2/3 - 2*n3, 7*n2/3 - 8*n3/5, 56 * n3 / 15}
+}
+
+// Forward returns the easting and northing in meters of lat/lon (degrees)
+func (p TransverseMercator) Forward(lat, lon float64) (easting, northing float64) {
+	phi := lat * math.Pi / 180
+	dLambda := (lon - p.CentralMeridian) * math.Pi / 180
+
+	// conformal latitude
+	e := 2 * math.Sqrt(thirdFlattening) / (1 + thirdFlattening)
+	t := math.Sinh(math.Atanh(math.Sin(phi)) - e*math.Atanh(e*math.Sin(phi)))
+
+	xi := math.Atan2(t, math.Cos(dLambda))
+	eta := math.Atanh(math.Sin(dLambda) / math.Sqrt(1+t*t))
+
+	x, y := eta, xi
+	for j, a := range alpha {
+		k := 2 * float64(j+1)
+		x += a * math.Cos(k*xi) * math.Sinh(k*eta)
+		y += a * math.Sin(k*xi) * math.Cosh(k*eta)
+	}
+
+	scale := p.Scale * rectifyingRadius
+	return p.FalseEasting + scale*x, p.FalseNorthing + scale*y
+}
+
+// Inverse returns lat/lon in degrees of an easting and northing
+func (p TransverseMercator) Inverse(easting, northing float64) (lat, lon float64) {
+	scale := p.Scale * rectifyingRadius
+	xi := (northing - p.FalseNorthing) / scale
+	eta := (easting - p.FalseEasting) / scale
+
+	xiP, etaP := xi, eta
+	for j, b := range beta {
+		k := 2 * float64(j+1)
+		xiP -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+
+	chi := math.Asin(math.Sin(xiP) / math.Cosh(etaP))
+	phi := chi
+	for j, d := range delta {
+		phi += d * math.Sin(2*float64(j+1)*chi)
+	}
+
+	lambda := math.Atan2(math.Sinh(etaP), math.Cos(xiP))
+	return phi * 180 / math.Pi, p.CentralMeridian + lambda*180/math.Pi
+}
+
+// Grid is a square grid of Size meter cells on a projection, with cell 0, 0
+// at the projection's false origin
+type Grid struct {
+	Projection TransverseMercator
+	Size       float64
+}
+
+// CellAt returns the column and row of the cell containing lat/lon
+func (g Grid) CellAt(lat, lon float64) (col, row int) {
+	easting, northing := g.Projection.Forward(lat, lon)
+	return int(math.Floor(easting / g.Size)), int(math.Floor(northing / g.Size))
+}
+
+// Center returns the lat/lon of the center of a cell
+func (g Grid) Center(col, row int) (lat, lon float64) {
+	return g.Projection.Inverse((float64(col)+0.5)*g.Size, (float64(row)+0.5)*g.Size)
+}
+
+// Corners returns the cell's corners as [lon, lat] pairs, counter-clockwise
+// from the south west
+func (g Grid) Corners(col, row int) [4][2]float64 {
+	var corners [4][2]float64
+	for i, offset := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		lat, lon := g.Projection.Inverse(float64(col+offset[0])*g.Size, float64(row+offset[1])*g.Size)
+		corners[i] = [2]float64{lon, lat}
+	}
+
+	return corners
+}
@@ -0,0 +1,60 @@
+package geo_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geo"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+)
+
+func TestTM35FINForward(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lon          float64
+		easting, northing float64
+	}{
+		// on the central meridian the northing is the scaled meridian arc
+		{60, 27, 500000, 6651411.19},
+		{0, 27, 500000, 0},
+	} {
+		easting, northing := geo.TM35FIN.Forward(tc.lat, tc.lon)
+		if math.Abs(easting-tc.easting) > 0.01 || math.Abs(northing-tc.northing) > 0.01 {
+			t.Errorf("%v, %v: expected %v, %v, got %v, %v", tc.lat, tc.lon, tc.easting, tc.northing, easting, northing)
+		}
+	}
+}
+
+func TestTM35FINRoundTrip(t *testing.T) {
+	for lat := 59.0; lat <= 70.5; lat += 0.5 {
+		for lon := 19.0; lon <= 32; lon += 0.5 {
+			easting, northing := geo.TM35FIN.Forward(lat, lon)
+			// the series are good to a millimeter, about 1e-8 degrees
+			gotLat, gotLon := geo.TM35FIN.Inverse(easting, northing)
+			if math.Abs(gotLat-lat) > 1e-7 || math.Abs(gotLon-lon) > 1e-7 {
+				t.Fatalf("%v, %v round trips to %v, %v", lat, lon, gotLat, gotLon)
+			}
+		}
+	}
+}
+
+func TestGridCellsAreSquareInMeters(t *testing.T) {
+	grid := geo.Grid{Projection: geo.TM35FIN, Size: 500}
+
+	col, row := grid.CellAt(60.3172, 24.9633)
+	corners := grid.Corners(col, row)
+
+	width := opensky.Haversine(corners[0][1], corners[0][0], corners[1][1], corners[1][0]) * 1000
+	height := opensky.Haversine(corners[0][1], corners[0][0], corners[3][1], corners[3][0]) * 1000
+	// the scale factor at Helsinki is close to 1, the sphere adds a bit more
+	if math.Abs(width-500) > 2 || math.Abs(height-500) > 2 || math.Abs(width-height) > 1 {
+		t.Errorf("expected a 500 m square, got %.1f x %.1f", width, height)
+	}
+
+	lat, lon := grid.Center(col, row)
+	if c, r := grid.CellAt(lat, lon); c != col || r != row {
+		t.Errorf("center falls in %d, %d, expected %d, %d", c, r, col, row)
+	}
+	if lat < corners[0][1] || lat > corners[3][1] {
+		t.Errorf("center %v, %v outside %v", lat, lon, corners)
+	}
+}
@@ -0,0 +1,172 @@
+// Package geofence detects aircraft entering and leaving polygon geofences
+package geofence
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
+)
+
+const (
+	EventEnter = "enter"
+	EventExit  = "exit"
+)
+
+// staleAfter drops previous fixes that are too old to compare against
+const staleAfter = 10 * time.Minute
+
+type Querier interface {
+	ListGeofences(ctx context.Context) ([]repository.Geofence, error)
+	InsertGeofenceEvent(ctx context.Context, arg repository.InsertGeofenceEventParams) error
+}
+
+type publisher interface {
+	Publish(ctx context.Context, event string, data any)
+}
+
+// Crossing is the data published when an aircraft crosses a fence
+type Crossing struct {
+	GeofenceID int32     `json:"geofence_id"`
+	Event      string    `json:"event"`
+	Icao24     string    `json:"icao24"`
+	Callsign   string    `json:"callsign"`
+	Time       time.Time `json:"time"`
+	Altitude   *float64  `json:"altitude"`
+}
+
+type fix struct {
+	lat  float64
+	lon  float64
+	time time.Time
+}
+
+type fence struct {
+	id      int32
+	polygon geojson.Polygon
+}
+
+// Detector compares each aircraft's current fix with its previous one and
+// stores an event whenever it crosses a fence boundary. Crossings are also
+// published when a Publisher is set.
+type Detector struct {
+	Queries   Querier
+	Publisher publisher
+
+	mu   sync.Mutex
+	last map[string]fix
+}
+
+func NewDetector(queries Querier) *Detector {
+	return &Detector{
+		Queries: queries,
+		last:    map[string]fix{},
+	}
+}
+
+// AfterPoll implements opensky.PollHook
+func (d *Detector) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	fences, err := d.loadFences(ctx)
+	if err != nil {
+		log.Printf("geofence load failed: %v", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, p := range positions {
+		if !p.Icao24.Valid || !p.Latitude.Valid || !p.Longitude.Valid {
+			continue
+		}
+
+		current := fix{
+			lat:  p.Latitude.Float64,
+			lon:  p.Longitude.Float64,
+			time: time.Unix(int64(p.ToTimestamp), 0).UTC(),
+		}
+
+		previous, ok := d.last[p.Icao24.String]
+		d.last[p.Icao24.String] = current
+		if !ok || current.time.Sub(previous.time) > staleAfter {
+			continue
+		}
+
+		for _, f := range fences {
+			wasInside := opensky.PointInPolygon(previous.lat, previous.lon, f.polygon)
+			isInside := opensky.PointInPolygon(current.lat, current.lon, f.polygon)
+			if wasInside == isInside {
+				continue
+			}
+
+			event := EventEnter
+			if wasInside {
+				event = EventExit
+			}
+
+			err := d.Queries.InsertGeofenceEvent(ctx, repository.InsertGeofenceEventParams{
+				GeofenceID:   f.id,
+				Icao24:       p.Icao24,
+				Callsign:     p.Callsign,
+				Event:        sql.NullString{String: event, Valid: true},
+				TimePosition: current.time,
+				BaroAltitude: p.BaroAltitude,
+			})
+			if err != nil {
+				log.Printf("geofence event insert failed: %v", err)
+				continue
+			}
+
+			if d.Publisher != nil {
+				crossing := Crossing{
+					GeofenceID: f.id,
+					Event:      event,
+					Icao24:     p.Icao24.String,
+					Callsign:   p.Callsign.String,
+					Time:       current.time,
+				}
+				if p.BaroAltitude.Valid {
+					crossing.Altitude = &p.BaroAltitude.Float64
+				}
+				d.Publisher.Publish(ctx, webhook.EventGeofenceCrossed, crossing)
+			}
+		}
+	}
+
+	d.prune()
+}
+
+func (d *Detector) loadFences(ctx context.Context) ([]fence, error) {
+	rows, err := d.Queries.ListGeofences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fences := make([]fence, 0, len(rows))
+	for _, row := range rows {
+		polygon, err := geojson.ParsePolygon(row.Geometry)
+		if err != nil {
+			log.Printf("skipping geofence %d: %v", row.ID, err)
+			continue
+		}
+		fences = append(fences, fence{id: row.ID, polygon: polygon})
+	}
+
+	return fences, nil
+}
+
+// prune forgets aircraft that have not been seen for a while
+func (d *Detector) prune() {
+	cutoff := time.Now().Add(-staleAfter)
+	for icao24, f := range d.last {
+		if f.time.Before(cutoff) {
+			delete(d.last, icao24)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package geofence_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geofence"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockQueries struct {
+	events []repository.InsertGeofenceEventParams
+}
+
+func (m *mockQueries) ListGeofences(ctx context.Context) ([]repository.Geofence, error) {
+	return []repository.Geofence{
+		{
+			ID:       1,
+			Geometry: json.RawMessage(`{"type":"Polygon","coordinates":[[[24.9,60.3],[25.0,60.3],[25.0,60.4],[24.9,60.4],[24.9,60.3]]]}`),
+		},
+	}, nil
+}
+
+func (m *mockQueries) InsertGeofenceEvent(ctx context.Context, arg repository.InsertGeofenceEventParams) error {
+	m.events = append(m.events, arg)
+	return nil
+}
+
+func position(lat, lon float64, t time.Time) repository.InsertPositionParams {
+	return repository.InsertPositionParams{
+		Icao24:      sql.NullString{String: "abc123", Valid: true},
+		Callsign:    sql.NullString{String: "FIN1", Valid: true},
+		ToTimestamp: float64(t.Unix()),
+		Latitude:    sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude:   sql.NullFloat64{Float64: lon, Valid: true},
+	}
+}
+
+func TestDetectorEnterAndExit(t *testing.T) {
+	mock := &mockQueries{}
+	detector := geofence.NewDetector(mock)
+	ctx := context.Background()
+	now := time.Now()
+
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position(60.25, 24.95, now)})
+	if len(mock.events) != 0 {
+		t.Fatalf("expected no events on first sighting, got %d", len(mock.events))
+	}
+
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position(60.35, 24.95, now.Add(45*time.Second))})
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position(60.36, 24.95, now.Add(90*time.Second))})
+	detector.AfterPoll(ctx, []repository.InsertPositionParams{position(60.45, 24.95, now.Add(135*time.Second))})
+
+	if len(mock.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(mock.events))
+	}
+
+	if mock.events[0].Event.String != geofence.EventEnter || mock.events[1].Event.String != geofence.EventExit {
+		t.Errorf("unexpected events: %+v", mock.events)
+	}
+
+	if mock.events[0].GeofenceID != 1 || mock.events[0].Callsign.String != "FIN1" {
+		t.Errorf("unexpected event fields: %+v", mock.events[0])
+	}
+}
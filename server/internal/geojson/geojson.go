@@ -0,0 +1,91 @@
+// Package geojson parses and builds the GeoJSON used by the API
+package geojson
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Polygon holds rings of [lon, lat] pairs, the first ring being the exterior
+type Polygon [][][2]float64
+
+// ParsePolygon decodes a GeoJSON Polygon geometry and validates its rings
+func ParsePolygon(data []byte) (Polygon, error) {
+	var geometry struct {
+		Type        string  `json:"type"`
+		Coordinates Polygon `json:"coordinates"`
+	}
+	if err := json.Unmarshal(data, &geometry); err != nil {
+		return nil, errors.New("invalid GeoJSON")
+	}
+
+	if geometry.Type != "Polygon" {
+		return nil, errors.New("geometry must be a Polygon")
+	}
+	if len(geometry.Coordinates) == 0 {
+		return nil, errors.New("polygon has no rings")
+	}
+
+	for _, ring := range geometry.Coordinates {
+		if len(ring) < 4 {
+			return nil, errors.New("polygon rings need at least four positions")
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return nil, errors.New("polygon rings must be closed")
+		}
+		for _, p := range ring {
+			if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+				return nil, errors.New("polygon has invalid coordinates")
+			}
+		}
+	}
+
+	return geometry.Coordinates, nil
+}
+
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+func NewFeature(geometry Geometry, properties map[string]any) Feature {
+	if properties == nil {
+		properties = map[string]any{}
+	}
+
+	return Feature{Type: "Feature", Geometry: geometry, Properties: properties}
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// CellPolygon is the Polygon geometry of a lat/lon grid cell given its south
+// west corner and size in degrees
+func CellPolygon(lat, lon, size float64) Geometry {
+	return Geometry{
+		Type: "Polygon",
+		Coordinates: Polygon{{
+			{lon, lat},
+			{lon + size, lat},
+			{lon + size, lat + size},
+			{lon, lat + size},
+			{lon, lat},
+		}},
+	}
+}
@@ -0,0 +1,1005 @@
+// Package h3 indexes points into Uber's H3 hexagonal grid and returns the
+// centers and boundaries of cells. It is a port of the parts of the reference
+// C library the heatmap needs, so cell IDs match those of other H3 tools.
+package h3
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MaxResolution is the finest H3 resolution
+const MaxResolution = 15
+
+const (
+	numFaces     = 20
+	numBaseCells = 122
+	numHexVerts  = 6
+	numPentVerts = 5
+
+	// resolution 0 unit length in the gnomonic projection of a face
+	res0UGnomonic = 0.38196601125010500003
+	// rotation between Class II and Class III resolution axes, asin(sqrt(3/28))
+	ap7RotRads = 0.333473172251832115336090755351601070065900389
+	sqrt3_2    = 0.8660254037844386467637231707529361834714
+	sqrt7      = 2.6457513110645905905016157536392604257102
+	epsilon    = 0.0000000000000001
+	// float32 epsilon, used when comparing edge crossings to vertices
+	fltEpsilon = 1.1920929e-7
+
+	modeCell = 1
+
+	modeOffset     = 59
+	resOffset      = 52
+	baseCellOffset = 45
+	digitBits      = 3
+	digitMask      = 7
+	// an index with every digit unused
+	initIndex = uint64(0x00001fffffffffff)
+)
+
+// digits of an index, named by the unit vector they step along
+const (
+	centerDigit  = 0
+	kAxesDigit   = 1
+	jAxesDigit   = 2
+	jkAxesDigit  = 3
+	iAxesDigit   = 4
+	ikAxesDigit  = 5
+	ijAxesDigit  = 6
+	invalidDigit = 7
+)
+
+// quadrants of a face, as indexes into faceNeighbors
+const (
+	ijQuadrant = 1
+	kiQuadrant = 2
+	jkQuadrant = 3
+)
+
+type overage int
+
+const (
+	noOverage overage = iota
+	faceEdge
+	newFace
+)
+
+// Cell is a 64-bit H3 cell index
+type Cell uint64
+
+// LatLng is a point in degrees
+type LatLng struct {
+	Lat, Lng float64
+}
+
+type geoPoint struct {
+	lat, lng float64
+}
+
+type vec2 struct {
+	x, y float64
+}
+
+type vec3 struct {
+	x, y, z float64
+}
+
+type coordIJK struct {
+	i, j, k int
+}
+
+type faceIJK struct {
+	face  int
+	coord coordIJK
+}
+
+type faceOrient struct {
+	face      int
+	translate coordIJK
+	ccwRot60  int
+}
+
+type baseCellRotation struct {
+	baseCell int
+	ccwRot60 int
+}
+
+type baseCell struct {
+	face     int
+	ijk      coordIJK
+	pentagon bool
+	cwOffset [2]int
+}
+
+var unitVecs = [7]coordIJK{
+	{0, 0, 0}, {0, 0, 1}, {0, 1, 0}, {0, 1, 1}, {1, 0, 0}, {1, 0, 1}, {1, 1, 0},
+}
+
+var (
+	faceCenterPoint   [numFaces]vec3
+	adjacentFaceDir   [numFaces][numFaces]int
+	maxDimByCIIRes    [MaxResolution + 2]int
+	unitScaleByCIIRes [MaxResolution + 2]int
+)
+
+func init() {
+	for f, g := range faceCenterGeo {
+		faceCenterPoint[f] = toVec3(g)
+
+		for other := range adjacentFaceDir[f] {
+			adjacentFaceDir[f][other] = -1
+		}
+		for dir, n := range faceNeighbors[f] {
+			adjacentFaceDir[f][n.face] = dir
+		}
+	}
+
+	scale := 1
+	for res := 0; res < len(maxDimByCIIRes); res += 2 {
+		unitScaleByCIIRes[res] = scale
+		maxDimByCIIRes[res] = 2 * scale
+		scale *= 7
+	}
+}
+
+// FromLatLng returns the cell containing lat/lng (degrees) at res
+func FromLatLng(lat, lng float64, res int) (Cell, error) {
+	if res < 0 || res > MaxResolution {
+		return 0, errors.New("invalid resolution")
+	}
+	if math.IsNaN(lat) || math.IsInf(lat, 0) || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		return 0, errors.New("invalid coordinates")
+	}
+
+	g := geoPoint{degsToRads(lat), degsToRads(lng)}
+	return faceIJKToCell(geoToFaceIJK(g, res), res), nil
+}
+
+// Parse reads a cell from its hexadecimal form
+func Parse(s string) (Cell, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || !Cell(v).IsValid() {
+		return 0, errors.New("invalid H3 cell")
+	}
+	return Cell(v), nil
+}
+
+// String returns the cell in the usual hexadecimal form
+func (c Cell) String() string {
+	return fmt.Sprintf("%x", uint64(c))
+}
+
+func (c Cell) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c Cell) Resolution() int {
+	return int(uint64(c)>>resOffset) & 0xf
+}
+
+func (c Cell) baseCell() int {
+	return int(uint64(c)>>baseCellOffset) & 0x7f
+}
+
+func (c Cell) mode() int {
+	return int(uint64(c)>>modeOffset) & 0xf
+}
+
+func (c Cell) digit(res int) int {
+	return int(uint64(c)>>((MaxResolution-res)*digitBits)) & digitMask
+}
+
+func (c *Cell) setDigit(res, digit int) {
+	shift := (MaxResolution - res) * digitBits
+	*c = Cell(uint64(*c)&^(uint64(digitMask)<<shift) | uint64(digit)<<shift)
+}
+
+// IsValid reports whether c is a well-formed cell index
+func (c Cell) IsValid() bool {
+	if uint64(c)>>63 != 0 || c.mode() != modeCell || c.baseCell() >= numBaseCells {
+		return false
+	}
+
+	res := c.Resolution()
+	for r := 1; r <= MaxResolution; r++ {
+		d := c.digit(r)
+		if r <= res && d == invalidDigit || r > res && d != invalidDigit {
+			return false
+		}
+	}
+	// pentagons have no k-axis subsequence
+	if baseCells[c.baseCell()].pentagon && c.leadingNonZeroDigit() == kAxesDigit {
+		return false
+	}
+
+	return true
+}
+
+// IsPentagon reports whether c is one of the twelve pentagons at each
+// resolution
+func (c Cell) IsPentagon() bool {
+	return baseCells[c.baseCell()].pentagon && c.leadingNonZeroDigit() == centerDigit
+}
+
+// LatLng returns the center of the cell
+func (c Cell) LatLng() LatLng {
+	fijk := c.toFaceIJK()
+	return faceIJKToGeo(fijk, c.Resolution()).degrees()
+}
+
+// Boundary returns the vertices of the cell counter-clockwise. Cells whose
+// edges cross an icosahedron edge have extra vertices where they do.
+func (c Cell) Boundary() []LatLng {
+	fijk := c.toFaceIJK()
+	if c.IsPentagon() {
+		return pentBoundary(fijk, c.Resolution())
+	}
+	return hexBoundary(fijk, c.Resolution())
+}
+
+func (c Cell) leadingNonZeroDigit() int {
+	for r := 1; r <= c.Resolution(); r++ {
+		if d := c.digit(r); d != centerDigit {
+			return d
+		}
+	}
+	return centerDigit
+}
+
+func (c Cell) rotate60ccw() Cell {
+	for r := 1; r <= c.Resolution(); r++ {
+		c.setDigit(r, rotate60ccw(c.digit(r)))
+	}
+	return c
+}
+
+func (c Cell) rotate60cw() Cell {
+	for r := 1; r <= c.Resolution(); r++ {
+		c.setDigit(r, rotate60cw(c.digit(r)))
+	}
+	return c
+}
+
+// rotatePent60ccw rotates a pentagon's digits, skipping the deleted k-axis
+// subsequence
+func (c Cell) rotatePent60ccw() Cell {
+	foundFirstNonZero := false
+	for r := 1; r <= c.Resolution(); r++ {
+		c.setDigit(r, rotate60ccw(c.digit(r)))
+
+		if !foundFirstNonZero && c.digit(r) != centerDigit {
+			foundFirstNonZero = true
+			if c.leadingNonZeroDigit() == kAxesDigit {
+				c = c.rotate60ccw()
+			}
+		}
+	}
+	return c
+}
+
+func rotate60ccw(digit int) int {
+	switch digit {
+	case kAxesDigit:
+		return ikAxesDigit
+	case ikAxesDigit:
+		return iAxesDigit
+	case iAxesDigit:
+		return ijAxesDigit
+	case ijAxesDigit:
+		return jAxesDigit
+	case jAxesDigit:
+		return jkAxesDigit
+	case jkAxesDigit:
+		return kAxesDigit
+	default:
+		return digit
+	}
+}
+
+func rotate60cw(digit int) int {
+	switch digit {
+	case kAxesDigit:
+		return jkAxesDigit
+	case jkAxesDigit:
+		return jAxesDigit
+	case jAxesDigit:
+		return ijAxesDigit
+	case ijAxesDigit:
+		return iAxesDigit
+	case iAxesDigit:
+		return ikAxesDigit
+	case ikAxesDigit:
+		return kAxesDigit
+	default:
+		return digit
+	}
+}
+
+func isClassIII(res int) bool {
+	return res%2 == 1
+}
+
+// faceIJKToCell builds the index of the cell at fijk, walking up the
+// aperture 7 hierarchy to the base cell and then rotating the digits into
+// the base cell's home orientation
+func faceIJKToCell(fijk faceIJK, res int) Cell {
+	c := Cell(initIndex | uint64(modeCell)<<modeOffset | uint64(res)<<resOffset)
+
+	if res == 0 {
+		if fijk.coord.i > 2 || fijk.coord.j > 2 || fijk.coord.k > 2 {
+			return 0
+		}
+		bc := faceIJKBaseCells[fijk.face][fijk.coord.i][fijk.coord.j][fijk.coord.k].baseCell
+		return Cell(uint64(c) | uint64(bc)<<baseCellOffset)
+	}
+
+	ijk := &fijk.coord
+	for r := res - 1; r >= 0; r-- {
+		last := *ijk
+		var lastCenter coordIJK
+		if isClassIII(r + 1) {
+			ijk.upAp7()
+			lastCenter = *ijk
+			lastCenter.downAp7()
+		} else {
+			ijk.upAp7r()
+			lastCenter = *ijk
+			lastCenter.downAp7r()
+		}
+
+		diff := last.sub(lastCenter)
+		diff.normalize()
+		c.setDigit(r+1, diff.unitDigit())
+	}
+
+	if ijk.i > 2 || ijk.j > 2 || ijk.k > 2 {
+		return 0
+	}
+
+	rotation := faceIJKBaseCells[fijk.face][ijk.i][ijk.j][ijk.k]
+	c = Cell(uint64(c) | uint64(rotation.baseCell)<<baseCellOffset)
+
+	bc := baseCells[rotation.baseCell]
+	if !bc.pentagon {
+		for range rotation.ccwRot60 {
+			c = c.rotate60ccw()
+		}
+		return c
+	}
+
+	// force rotation out of the missing k-axis subsequence
+	if c.leadingNonZeroDigit() == kAxesDigit {
+		if bc.cwOffset[0] == fijk.face || bc.cwOffset[1] == fijk.face {
+			c = c.rotate60cw()
+		} else {
+			c = c.rotate60ccw()
+		}
+	}
+	for range rotation.ccwRot60 {
+		c = c.rotatePent60ccw()
+	}
+
+	return c
+}
+
+// toFaceIJK places the cell on the face of its base cell, or on a
+// neighbouring face if it spills over an icosahedron edge
+func (c Cell) toFaceIJK() faceIJK {
+	bc := c.baseCell()
+	res := c.Resolution()
+
+	// adjust for the pentagonal missing sequence
+	if baseCells[bc].pentagon && c.leadingNonZeroDigit() == ikAxesDigit {
+		c = c.rotate60cw()
+	}
+
+	fijk := faceIJK{face: baseCells[bc].face, coord: baseCells[bc].ijk}
+	possibleOverage := baseCells[bc].pentagon || (res != 0 && fijk.coord != coordIJK{})
+	for r := 1; r <= res; r++ {
+		if isClassIII(r) {
+			fijk.coord.downAp7()
+		} else {
+			fijk.coord.downAp7r()
+		}
+		fijk.coord.neighbor(c.digit(r))
+	}
+	if !possibleOverage {
+		return fijk
+	}
+
+	orig := fijk.coord
+	adjRes := res
+	// Class III cells are handled on the next finer Class II grid
+	if isClassIII(res) {
+		fijk.coord.downAp7r()
+		adjRes++
+	}
+
+	pentLeading4 := baseCells[bc].pentagon && c.leadingNonZeroDigit() == iAxesDigit
+	if fijk.adjustOverageClassII(adjRes, pentLeading4, false) != noOverage {
+		// pentagons can spill over a second edge
+		if baseCells[bc].pentagon {
+			for fijk.adjustOverageClassII(adjRes, false, false) != noOverage {
+			}
+		}
+		if adjRes != res {
+			fijk.coord.upAp7r()
+		}
+	} else if adjRes != res {
+		fijk.coord = orig
+	}
+
+	return fijk
+}
+
+// adjustOverageClassII moves coordinates beyond the edge of their face onto
+// the neighbouring face. substrate coordinates are on the aperture 3 grid
+// used for vertices.
+func (fijk *faceIJK) adjustOverageClassII(res int, pentLeading4, substrate bool) overage {
+	result := noOverage
+	ijk := &fijk.coord
+
+	maxDim := maxDimByCIIRes[res]
+	if substrate {
+		maxDim *= 3
+	}
+
+	sum := ijk.i + ijk.j + ijk.k
+	if substrate && sum == maxDim {
+		return faceEdge
+	}
+	if sum <= maxDim {
+		return result
+	}
+
+	result = newFace
+	var orient faceOrient
+	switch {
+	case ijk.k > 0 && ijk.j > 0:
+		orient = faceNeighbors[fijk.face][jkQuadrant]
+	case ijk.k > 0:
+		orient = faceNeighbors[fijk.face][kiQuadrant]
+		// adjust for the pentagonal missing sequence
+		if pentLeading4 {
+			origin := coordIJK{maxDim, 0, 0}
+			tmp := ijk.sub(origin)
+			tmp.rotate60cw()
+			*ijk = tmp.add(origin)
+		}
+	default:
+		orient = faceNeighbors[fijk.face][ijQuadrant]
+	}
+
+	fijk.face = orient.face
+	for range orient.ccwRot60 {
+		ijk.rotate60ccw()
+	}
+
+	unitScale := unitScaleByCIIRes[res]
+	if substrate {
+		unitScale *= 3
+	}
+	*ijk = ijk.add(orient.translate.scale(unitScale))
+	ijk.normalize()
+
+	// overage points on pentagon boundaries can end up on edges
+	if substrate && ijk.i+ijk.j+ijk.k == maxDim {
+		result = faceEdge
+	}
+
+	return result
+}
+
+var (
+	hexVertsCII = [numHexVerts]coordIJK{
+		{2, 1, 0}, {1, 2, 0}, {0, 2, 1}, {0, 1, 2}, {1, 0, 2}, {2, 0, 1},
+	}
+	hexVertsCIII = [numHexVerts]coordIJK{
+		{5, 4, 0}, {1, 5, 0}, {0, 5, 4}, {0, 1, 5}, {4, 0, 5}, {5, 0, 1},
+	}
+	pentVertsCII = [numPentVerts]coordIJK{
+		{2, 1, 0}, {1, 2, 0}, {0, 2, 1}, {0, 1, 2}, {1, 0, 2},
+	}
+	pentVertsCIII = [numPentVerts]coordIJK{
+		{5, 4, 0}, {1, 5, 0}, {0, 5, 4}, {0, 1, 5}, {4, 0, 5},
+	}
+)
+
+// cellVerts returns the vertices of the cell centered on fijk on the
+// aperture 3 substrate grid, and the Class II resolution they are on
+func cellVerts(fijk faceIJK, res int, vertsCII, vertsCIII []coordIJK) ([]faceIJK, int) {
+	verts := vertsCII
+	center := fijk.coord
+	center.downAp3()
+	center.downAp3r()
+	if isClassIII(res) {
+		verts = vertsCIII
+		center.downAp7r()
+		res++
+	}
+
+	out := make([]faceIJK, len(verts))
+	for v, vert := range verts {
+		out[v] = faceIJK{face: fijk.face, coord: center.add(vert)}
+		out[v].coord.normalize()
+	}
+
+	return out, res
+}
+
+// icosaEdge returns the ends of the edge of the current face in the
+// direction of the adjacent face, on the substrate grid
+func icosaEdge(res, dir int) (vec2, vec2) {
+	maxDim := float64(maxDimByCIIRes[res])
+	v0 := vec2{3.0 * maxDim, 0.0}
+	v1 := vec2{-1.5 * maxDim, 3.0 * sqrt3_2 * maxDim}
+	v2 := vec2{-1.5 * maxDim, -3.0 * sqrt3_2 * maxDim}
+
+	switch dir {
+	case ijQuadrant:
+		return v0, v1
+	case jkQuadrant:
+		return v1, v2
+	default:
+		return v2, v0
+	}
+}
+
+func hexBoundary(center faceIJK, res int) []LatLng {
+	verts, adjRes := cellVerts(center, res, hexVertsCII[:], hexVertsCIII[:])
+
+	var boundary []LatLng
+	lastFace := -1
+	lastOverage := noOverage
+	// one more iteration checks the last edge for a crossing
+	for vert := 0; vert < numHexVerts+1; vert++ {
+		v := vert % numHexVerts
+		fijk := verts[v]
+		ov := fijk.adjustOverageClassII(adjRes, false, true)
+
+		// Class III edges that cross an icosahedron edge get a vertex at the
+		// crossing, so each half can be projected on its own face. Class II
+		// edges have their vertices on the face edge instead.
+		if isClassIII(res) && vert > 0 && fijk.face != lastFace && lastOverage != faceEdge {
+			lastV := (v + 5) % numHexVerts
+			orig0 := verts[lastV].coord.toHex2d()
+			orig1 := verts[v].coord.toHex2d()
+
+			face2 := lastFace
+			if lastFace == center.face {
+				face2 = fijk.face
+			}
+			edge0, edge1 := icosaEdge(adjRes, adjacentFaceDir[center.face][face2])
+
+			inter := intersect(orig0, orig1, edge0, edge1)
+			// an intersection at a vertex leaves each edge on a single face
+			if !inter.almostEquals(orig0) && !inter.almostEquals(orig1) {
+				boundary = append(boundary, hex2dToGeo(inter, center.face, adjRes, true).degrees())
+			}
+		}
+
+		if vert < numHexVerts {
+			boundary = append(boundary, hex2dToGeo(fijk.coord.toHex2d(), fijk.face, adjRes, true).degrees())
+		}
+
+		lastFace = fijk.face
+		lastOverage = ov
+	}
+
+	return boundary
+}
+
+func pentBoundary(center faceIJK, res int) []LatLng {
+	verts, adjRes := cellVerts(center, res, pentVertsCII[:], pentVertsCIII[:])
+
+	var boundary []LatLng
+	var last faceIJK
+	for vert := 0; vert < numPentVerts+1; vert++ {
+		v := vert % numPentVerts
+		fijk := verts[v]
+		for fijk.adjustOverageClassII(adjRes, false, true) == newFace {
+		}
+
+		// all Class III pentagon edges cross an icosahedron edge
+		if isClassIII(res) && vert > 0 {
+			orig0 := last.coord.toHex2d()
+
+			// bring the current vertex onto the last vertex's face
+			tmp := fijk
+			orient := faceNeighbors[tmp.face][adjacentFaceDir[tmp.face][last.face]]
+			tmp.face = orient.face
+			for range orient.ccwRot60 {
+				tmp.coord.rotate60ccw()
+			}
+			tmp.coord = tmp.coord.add(orient.translate.scale(unitScaleByCIIRes[adjRes] * 3))
+			tmp.coord.normalize()
+			orig1 := tmp.coord.toHex2d()
+
+			edge0, edge1 := icosaEdge(adjRes, adjacentFaceDir[tmp.face][fijk.face])
+			inter := intersect(orig0, orig1, edge0, edge1)
+			boundary = append(boundary, hex2dToGeo(inter, tmp.face, adjRes, true).degrees())
+		}
+
+		if vert < numPentVerts {
+			boundary = append(boundary, hex2dToGeo(fijk.coord.toHex2d(), fijk.face, adjRes, true).degrees())
+		}
+
+		last = fijk
+	}
+
+	return boundary
+}
+
+// geoToFaceIJK projects g onto the closest face and finds the containing
+// cell's coordinates there
+func geoToFaceIJK(g geoPoint, res int) faceIJK {
+	face, v := geoToHex2d(g, res)
+	return faceIJK{face: face, coord: hex2dToCoordIJK(v)}
+}
+
+func geoToHex2d(g geoPoint, res int) (int, vec2) {
+	p := toVec3(g)
+	face, sqd := 0, 5.0
+	for f := range faceCenterPoint {
+		if d := p.squareDistance(faceCenterPoint[f]); d < sqd {
+			face, sqd = f, d
+		}
+	}
+
+	// cos(r) = 1 - 2 * sin^2(r/2) = 1 - 2 * (sqd / 4) = 1 - sqd / 2
+	r := math.Acos(1 - sqd/2)
+	if r < epsilon {
+		return face, vec2{}
+	}
+
+	theta := posAngle(faceAxisAzimuth[face] - posAngle(azimuth(faceCenterGeo[face], g)))
+	if isClassIII(res) {
+		theta = posAngle(theta - ap7RotRads)
+	}
+
+	// gnomonic scaling, then scaled to the resolution
+	r = math.Tan(r) / res0UGnomonic
+	for range res {
+		r *= sqrt7
+	}
+
+	return face, vec2{r * math.Cos(theta), r * math.Sin(theta)}
+}
+
+func faceIJKToGeo(fijk faceIJK, res int) geoPoint {
+	return hex2dToGeo(fijk.coord.toHex2d(), fijk.face, res, false)
+}
+
+// hex2dToGeo is the inverse gnomonic projection of v on face. substrate
+// points are on the aperture 3 vertex grid of a Class II resolution.
+func hex2dToGeo(v vec2, face, res int, substrate bool) geoPoint {
+	r := math.Hypot(v.x, v.y)
+	if r < epsilon {
+		return faceCenterGeo[face]
+	}
+
+	theta := math.Atan2(v.y, v.x)
+	for range res {
+		r /= sqrt7
+	}
+	if substrate {
+		r /= 3.0
+		if isClassIII(res) {
+			r /= sqrt7
+		}
+	}
+	r = math.Atan(r * res0UGnomonic)
+
+	if !substrate && isClassIII(res) {
+		theta = posAngle(theta + ap7RotRads)
+	}
+	theta = posAngle(faceAxisAzimuth[face] - theta)
+
+	return azimuthDistance(faceCenterGeo[face], theta, r)
+}
+
+func hex2dToCoordIJK(v vec2) coordIJK {
+	var h coordIJK
+
+	a1 := math.Abs(v.x)
+	a2 := math.Abs(v.y)
+
+	// first do a reverse conversion
+	x2 := a2 / sqrt3_2
+	x1 := a1 + x2/2.0
+
+	// check if we have the center of a hex
+	m1 := int(x1)
+	m2 := int(x2)
+
+	// otherwise round correctly
+	r1 := x1 - float64(m1)
+	r2 := x2 - float64(m2)
+
+	if r1 < 0.5 {
+		if r1 < 1.0/3.0 {
+			h.i = m1
+			if r2 < (1.0+r1)/2.0 {
+				h.j = m2
+			} else {
+				h.j = m2 + 1
+			}
+		} else {
+			if r2 < 1.0-r1 {
+				h.j = m2
+			} else {
+				h.j = m2 + 1
+			}
+			if 1.0-r1 <= r2 && r2 < 2.0*r1 {
+				h.i = m1 + 1
+			} else {
+				h.i = m1
+			}
+		}
+	} else {
+		if r1 < 2.0/3.0 {
+			if r2 < 1.0-r1 {
+				h.j = m2
+			} else {
+				h.j = m2 + 1
+			}
+			if 2.0*r1-1.0 < r2 && r2 < 1.0-r1 {
+				h.i = m1
+			} else {
+				h.i = m1 + 1
+			}
+		} else {
+			h.i = m1 + 1
+			if r2 < r1/2.0 {
+				h.j = m2
+			} else {
+				h.j = m2 + 1
+			}
+		}
+	}
+
+	// fold across the axes if necessary
+	if v.x < 0.0 {
+		if h.j%2 == 0 {
+			axisI := h.j / 2
+			diff := h.i - axisI
+			h.i -= 2 * diff
+		} else {
+			axisI := (h.j + 1) / 2
+			diff := h.i - axisI
+			h.i -= 2*diff + 1
+		}
+	}
+	if v.y < 0.0 {
+		h.i -= (2*h.j + 1) / 2
+		h.j = -h.j
+	}
+
+	h.normalize()
+	return h
+}
+
+func (h coordIJK) toHex2d() vec2 {
+	i := h.i - h.k
+	j := h.j - h.k
+	return vec2{float64(i) - 0.5*float64(j), float64(j) * sqrt3_2}
+}
+
+func (h coordIJK) add(o coordIJK) coordIJK {
+	return coordIJK{h.i + o.i, h.j + o.j, h.k + o.k}
+}
+
+func (h coordIJK) sub(o coordIJK) coordIJK {
+	return coordIJK{h.i - o.i, h.j - o.j, h.k - o.k}
+}
+
+func (h coordIJK) scale(factor int) coordIJK {
+	return coordIJK{h.i * factor, h.j * factor, h.k * factor}
+}
+
+// normalize makes the coordinates non-negative with at least one zero
+func (h *coordIJK) normalize() {
+	if h.i < 0 {
+		h.j -= h.i
+		h.k -= h.i
+		h.i = 0
+	}
+	if h.j < 0 {
+		h.i -= h.j
+		h.k -= h.j
+		h.j = 0
+	}
+	if h.k < 0 {
+		h.i -= h.k
+		h.j -= h.k
+		h.k = 0
+	}
+
+	low := min(h.i, h.j, h.k)
+	if low > 0 {
+		h.i -= low
+		h.j -= low
+		h.k -= low
+	}
+}
+
+func (h coordIJK) unitDigit() int {
+	h.normalize()
+	for digit, v := range unitVecs {
+		if h == v {
+			return digit
+		}
+	}
+	return invalidDigit
+}
+
+func (h *coordIJK) neighbor(digit int) {
+	if digit > centerDigit && digit < invalidDigit {
+		*h = h.add(unitVecs[digit])
+		h.normalize()
+	}
+}
+
+// combine scaled unit vectors of the target grid
+func (h *coordIJK) combine(iVec, jVec, kVec coordIJK) {
+	*h = iVec.scale(h.i).add(jVec.scale(h.j)).add(kVec.scale(h.k))
+	h.normalize()
+}
+
+func (h *coordIJK) rotate60ccw() {
+	h.combine(coordIJK{1, 1, 0}, coordIJK{0, 1, 1}, coordIJK{1, 0, 1})
+}
+
+func (h *coordIJK) rotate60cw() {
+	h.combine(coordIJK{1, 0, 1}, coordIJK{1, 1, 0}, coordIJK{0, 1, 1})
+}
+
+// upAp7 moves to the parent cell of a counter-clockwise aperture 7 grid
+func (h *coordIJK) upAp7() {
+	i := h.i - h.k
+	j := h.j - h.k
+	h.i = int(math.Round(float64(3*i-j) / 7.0))
+	h.j = int(math.Round(float64(i+2*j) / 7.0))
+	h.k = 0
+	h.normalize()
+}
+
+// upAp7r moves to the parent cell of a clockwise aperture 7 grid
+func (h *coordIJK) upAp7r() {
+	i := h.i - h.k
+	j := h.j - h.k
+	h.i = int(math.Round(float64(2*i+j) / 7.0))
+	h.j = int(math.Round(float64(3*j-i) / 7.0))
+	h.k = 0
+	h.normalize()
+}
+
+// downAp7 moves to the center child of a counter-clockwise aperture 7 grid
+func (h *coordIJK) downAp7() {
+	h.combine(coordIJK{3, 0, 1}, coordIJK{1, 3, 0}, coordIJK{0, 1, 3})
+}
+
+// downAp7r moves to the center child of a clockwise aperture 7 grid
+func (h *coordIJK) downAp7r() {
+	h.combine(coordIJK{3, 1, 0}, coordIJK{0, 3, 1}, coordIJK{1, 0, 3})
+}
+
+func (h *coordIJK) downAp3() {
+	h.combine(coordIJK{2, 0, 1}, coordIJK{1, 2, 0}, coordIJK{0, 1, 2})
+}
+
+func (h *coordIJK) downAp3r() {
+	h.combine(coordIJK{2, 1, 0}, coordIJK{0, 2, 1}, coordIJK{1, 0, 2})
+}
+
+// intersect returns where the line p0-p1 crosses the line p2-p3
+func intersect(p0, p1, p2, p3 vec2) vec2 {
+	s1 := vec2{p1.x - p0.x, p1.y - p0.y}
+	s2 := vec2{p3.x - p2.x, p3.y - p2.y}
+
+	t := (s2.x*(p0.y-p2.y) - s2.y*(p0.x-p2.x)) / (-s2.x*s1.y + s1.x*s2.y)
+	return vec2{p0.x + t*s1.x, p0.y + t*s1.y}
+}
+
+func (v vec2) almostEquals(o vec2) bool {
+	return math.Abs(v.x-o.x) < fltEpsilon && math.Abs(v.y-o.y) < fltEpsilon
+}
+
+func toVec3(g geoPoint) vec3 {
+	r := math.Cos(g.lat)
+	return vec3{math.Cos(g.lng) * r, math.Sin(g.lng) * r, math.Sin(g.lat)}
+}
+
+func (v vec3) squareDistance(o vec3) float64 {
+	dx, dy, dz := v.x-o.x, v.y-o.y, v.z-o.z
+	return dx*dx + dy*dy + dz*dz
+}
+
+// azimuth returns the azimuth in radians from p1 to p2
+func azimuth(p1, p2 geoPoint) float64 {
+	return math.Atan2(
+		math.Cos(p2.lat)*math.Sin(p2.lng-p1.lng),
+		math.Cos(p1.lat)*math.Sin(p2.lat)-math.Sin(p1.lat)*math.Cos(p2.lat)*math.Cos(p2.lng-p1.lng),
+	)
+}
+
+// azimuthDistance returns the point distance radians from p1 along az
+func azimuthDistance(p1 geoPoint, az, distance float64) geoPoint {
+	if distance < epsilon {
+		return p1
+	}
+
+	var p2 geoPoint
+	az = posAngle(az)
+
+	// due north or south
+	if az < epsilon || math.Abs(az-math.Pi) < epsilon {
+		if az < epsilon {
+			p2.lat = p1.lat + distance
+		} else {
+			p2.lat = p1.lat - distance
+		}
+
+		if math.Abs(p2.lat-math.Pi/2) < epsilon {
+			return geoPoint{math.Pi / 2, 0}
+		}
+		if math.Abs(p2.lat+math.Pi/2) < epsilon {
+			return geoPoint{-math.Pi / 2, 0}
+		}
+		p2.lng = constrainLng(p1.lng)
+		return p2
+	}
+
+	sinLat := math.Sin(p1.lat)*math.Cos(distance) + math.Cos(p1.lat)*math.Sin(distance)*math.Cos(az)
+	p2.lat = math.Asin(clamp(sinLat))
+
+	if math.Abs(p2.lat-math.Pi/2) < epsilon {
+		return geoPoint{math.Pi / 2, 0}
+	}
+	if math.Abs(p2.lat+math.Pi/2) < epsilon {
+		return geoPoint{-math.Pi / 2, 0}
+	}
+
+	sinLng := math.Sin(az) * math.Sin(distance) / math.Cos(p2.lat)
+	cosLng := (math.Cos(distance) - math.Sin(p1.lat)*math.Sin(p2.lat)) / math.Cos(p1.lat) / math.Cos(p2.lat)
+	p2.lng = constrainLng(p1.lng + math.Atan2(clamp(sinLng), clamp(cosLng)))
+
+	return p2
+}
+
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
+
+// posAngle normalizes radians to [0, 2pi)
+func posAngle(rads float64) float64 {
+	tmp := rads
+	if rads < 0 {
+		tmp = rads + 2*math.Pi
+	}
+	if rads >= 2*math.Pi {
+		tmp -= 2 * math.Pi
+	}
+	return tmp
+}
+
+func constrainLng(lng float64) float64 {
+	for lng > math.Pi {
+		lng -= 2 * math.Pi
+	}
+	for lng < -math.Pi {
+		lng += 2 * math.Pi
+	}
+	return lng
+}
+
+func degsToRads(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func (g geoPoint) degrees() LatLng {
+	return LatLng{Lat: g.lat * 180 / math.Pi, Lng: g.lng * 180 / math.Pi}
+}
@@ -0,0 +1,110 @@
+package h3_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/h3"
+)
+
+// cells from the reference library
+func TestFromLatLng(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lng float64
+		res      int
+		want     string
+	}{
+		{37.3615593, -122.0553238, 5, "85283473fffffff"},
+		{37.7752702151959, -122.418307270836, 9, "8928308280fffff"},
+		{40.689167, -74.044444, 10, "8a2a1072b59ffff"},
+		{60.3172, 24.9633, 7, "8708996dbffffff"},
+		{64.7, 10.5362, 0, "8009fffffffffff"},
+	} {
+		cell, err := h3.FromLatLng(tc.lat, tc.lng, tc.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cell.String() != tc.want {
+			t.Errorf("%v, %v at %d: expected %s, got %s", tc.lat, tc.lng, tc.res, tc.want, cell)
+		}
+	}
+}
+
+func TestFromLatLngRejectsBadInput(t *testing.T) {
+	if _, err := h3.FromLatLng(60, 25, 16); err == nil {
+		t.Error("expected an error for resolution 16")
+	}
+	if _, err := h3.FromLatLng(math.NaN(), 25, 5); err == nil {
+		t.Error("expected an error for NaN")
+	}
+}
+
+func TestBoundary(t *testing.T) {
+	cell, _ := h3.Parse("85283473fffffff")
+
+	boundary := cell.Boundary()
+	if len(boundary) != 6 {
+		t.Fatalf("expected 6 vertices, got %d", len(boundary))
+	}
+	if math.Abs(boundary[0].Lat-37.271355866731895) > 1e-9 || math.Abs(boundary[0].Lng+121.91508032705622) > 1e-9 {
+		t.Errorf("unexpected first vertex: %+v", boundary[0])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for range 10000 {
+		lat := r.Float64()*180 - 90
+		lng := r.Float64()*360 - 180
+		res := r.Intn(h3.MaxResolution + 1)
+
+		cell, _ := h3.FromLatLng(lat, lng, res)
+		if !cell.IsValid() || cell.Resolution() != res {
+			t.Fatalf("%v, %v at %d: invalid cell %s", lat, lng, res, cell)
+		}
+
+		// the center of a cell indexes back into it
+		center := cell.LatLng()
+		again, _ := h3.FromLatLng(center.Lat, center.Lng, res)
+		if again != cell {
+			t.Fatalf("%v, %v at %d: %s centers in %s", lat, lng, res, cell, again)
+		}
+	}
+}
+
+func TestPentagons(t *testing.T) {
+	for res := 0; res <= h3.MaxResolution; res++ {
+		cell, _ := h3.FromLatLng(64.7, 10.5362, res)
+		if !cell.IsPentagon() {
+			t.Fatalf("expected a pentagon at %d, got %s", res, cell)
+		}
+
+		// Class III pentagons have a vertex where each edge crosses the icosahedron
+		want := 5
+		if res%2 == 1 {
+			want = 10
+		}
+		if n := len(cell.Boundary()); n != want {
+			t.Errorf("res %d: expected %d vertices, got %d", res, want, n)
+		}
+	}
+
+	hex, _ := h3.FromLatLng(60.3172, 24.9633, 7)
+	if hex.IsPentagon() {
+		t.Errorf("%s is not a pentagon", hex)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cell, err := h3.Parse("8708996dbffffff")
+	if err != nil || cell.Resolution() != 7 {
+		t.Fatalf("unexpected cell %s: %v", cell, err)
+	}
+
+	for _, s := range []string{"", "zz", "8708996dbfffff0", "0"} {
+		if _, err := h3.Parse(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
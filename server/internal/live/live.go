@@ -0,0 +1,105 @@
+// Package live keeps the latest fix of every aircraft seen by ingestion
+package live
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// Retention bounds how long an aircraft is kept after its last fix, and so
+// the oldest max age a snapshot can honour
+const Retention = 30 * time.Minute
+
+type Aircraft struct {
+	Icao24       string    `json:"icao24"`
+	Callsign     string    `json:"callsign"`
+	Lat          float64   `json:"lat"`
+	Lon          float64   `json:"lon"`
+	Altitude     *float64  `json:"altitude"`
+	Heading      *float64  `json:"heading"`
+	Velocity     *float64  `json:"velocity"`
+	VerticalRate *float64  `json:"vertical_rate"`
+	Time         time.Time `json:"time"`
+	AgeSeconds   float64   `json:"age_seconds"`
+}
+
+// Index is updated after each poll and answers "what is in the air now"
+// without touching the database
+type Index struct {
+	mu       sync.RWMutex
+	aircraft map[string]Aircraft
+}
+
+func NewIndex() *Index {
+	return &Index{aircraft: map[string]Aircraft{}}
+}
+
+// AfterPoll implements opensky.PollHook
+func (i *Index) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, p := range positions {
+		if !p.Icao24.Valid || !p.Latitude.Valid || !p.Longitude.Valid {
+			continue
+		}
+
+		a := Aircraft{
+			Icao24:       p.Icao24.String,
+			Callsign:     p.Callsign.String,
+			Lat:          p.Latitude.Float64,
+			Lon:          p.Longitude.Float64,
+			Altitude:     nullable(p.BaroAltitude.Float64, p.BaroAltitude.Valid),
+			Heading:      nullable(p.Heading.Float64, p.Heading.Valid),
+			Velocity:     nullable(p.Velocity.Float64, p.Velocity.Valid),
+			VerticalRate: nullable(p.VerticalRate.Float64, p.VerticalRate.Valid),
+			Time:         time.Unix(int64(p.ToTimestamp), 0).UTC(),
+		}
+
+		// fixes can arrive out of order, keep the newest one
+		if prev, ok := i.aircraft[a.Icao24]; ok && prev.Time.After(a.Time) {
+			continue
+		}
+		i.aircraft[a.Icao24] = a
+	}
+
+	cutoff := time.Now().Add(-Retention)
+	for icao24, a := range i.aircraft {
+		if a.Time.Before(cutoff) {
+			delete(i.aircraft, icao24)
+		}
+	}
+}
+
+// Snapshot returns aircraft whose latest fix is at most maxAge old
+func (i *Index) Snapshot(now time.Time, maxAge time.Duration) []Aircraft {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	aircraft := make([]Aircraft, 0, len(i.aircraft))
+	for _, a := range i.aircraft {
+		age := now.Sub(a.Time)
+		if age > maxAge {
+			continue
+		}
+
+		a.AgeSeconds = age.Seconds()
+		aircraft = append(aircraft, a)
+	}
+
+	sort.Slice(aircraft, func(x, y int) bool { return aircraft[x].Icao24 < aircraft[y].Icao24 })
+
+	return aircraft
+}
+
+func nullable(v float64, valid bool) *float64 {
+	if !valid {
+		return nil
+	}
+
+	return &v
+}
@@ -0,0 +1,49 @@
+package live_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/live"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+func position(icao24 string, lat float64, t time.Time) repository.InsertPositionParams {
+	return repository.InsertPositionParams{
+		Icao24:       sql.NullString{String: icao24, Valid: true},
+		ToTimestamp:  float64(t.Unix()),
+		Latitude:     sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude:    sql.NullFloat64{Float64: 24.95, Valid: true},
+		BaroAltitude: sql.NullFloat64{Float64: 1200, Valid: true},
+	}
+}
+
+func TestSnapshotKeepsLatestFreshFix(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	index := live.NewIndex()
+
+	index.AfterPoll(context.Background(), []repository.InsertPositionParams{
+		position("abc123", 60.30, now.Add(-40*time.Second)),
+		position("def456", 60.10, now.Add(-5*time.Minute)),
+	})
+	index.AfterPoll(context.Background(), []repository.InsertPositionParams{
+		position("abc123", 60.31, now.Add(-5*time.Second)),
+		// older fix arriving late must not replace the newer one
+		position("abc123", 60.29, now.Add(-50*time.Second)),
+	})
+
+	aircraft := index.Snapshot(now, time.Minute)
+	if len(aircraft) != 1 {
+		t.Fatalf("expected 1 fresh aircraft, got %d", len(aircraft))
+	}
+
+	a := aircraft[0]
+	if a.Icao24 != "abc123" || a.Lat != 60.31 || a.AgeSeconds != 5 {
+		t.Errorf("unexpected aircraft: %+v", a)
+	}
+	if a.Altitude == nil || *a.Altitude != 1200 || a.Heading != nil {
+		t.Errorf("unexpected optional fields: %+v", a)
+	}
+}
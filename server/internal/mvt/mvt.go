@@ -0,0 +1,449 @@
+// Package mvt encodes and decodes Mapbox Vector Tiles (spec version 2.1)
+package mvt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultExtent is the tile coordinate range used when a layer sets none
+const DefaultExtent = 4096
+
+// geometry types from the spec's GeomType enum
+const (
+	typePolygon = 3
+)
+
+// geometry commands
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Feature is a polygon in tile coordinates. The first ring is the exterior
+// and must wind clockwise on screen (positive area with y pointing down);
+// rings are closed implicitly so the first point is not repeated.
+type Feature struct {
+	ID         uint64
+	Rings      [][][2]int
+	Properties map[string]any
+}
+
+type Layer struct {
+	Name     string
+	Extent   uint32
+	Features []Feature
+}
+
+// Encode serialises layers into a tile. Property values may be strings,
+// bools, integers or floats; other types are encoded with fmt.Sprint.
+func Encode(layers ...Layer) []byte {
+	var tile []byte
+	for _, l := range layers {
+		tile = appendBytes(tile, 3, encodeLayer(l))
+	}
+
+	return tile
+}
+
+func encodeLayer(l Layer) []byte {
+	extent := l.Extent
+	if extent == 0 {
+		extent = DefaultExtent
+	}
+
+	var keys []string
+	keyIndex := map[string]uint32{}
+	var values [][]byte
+	valueIndex := map[string]uint32{}
+
+	var features [][]byte
+	for _, f := range l.Features {
+		// sorted so the same features always produce the same bytes
+		names := make([]string, 0, len(f.Properties))
+		for k := range f.Properties {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		var tags []uint32
+		for _, k := range names {
+			v := f.Properties[k]
+			ki, ok := keyIndex[k]
+			if !ok {
+				ki = uint32(len(keys))
+				keyIndex[k] = ki
+				keys = append(keys, k)
+			}
+
+			encoded := encodeValue(v)
+			vi, ok := valueIndex[string(encoded)]
+			if !ok {
+				vi = uint32(len(values))
+				valueIndex[string(encoded)] = vi
+				values = append(values, encoded)
+			}
+
+			tags = append(tags, ki, vi)
+		}
+
+		var feature []byte
+		if f.ID != 0 {
+			feature = appendVarintField(feature, 1, f.ID)
+		}
+		if len(tags) > 0 {
+			feature = appendBytes(feature, 2, packed(tags))
+		}
+		feature = appendVarintField(feature, 3, typePolygon)
+		feature = appendBytes(feature, 4, packed(polygonCommands(f.Rings)))
+		features = append(features, feature)
+	}
+
+	var layer []byte
+	layer = appendVarintField(layer, 15, 2)
+	layer = appendBytes(layer, 1, []byte(l.Name))
+	for _, f := range features {
+		layer = appendBytes(layer, 2, f)
+	}
+	for _, k := range keys {
+		layer = appendBytes(layer, 3, []byte(k))
+	}
+	for _, v := range values {
+		layer = appendBytes(layer, 4, v)
+	}
+	layer = appendVarintField(layer, 5, uint64(extent))
+
+	return layer
+}
+
+func encodeValue(v any) []byte {
+	var value []byte
+	switch v := v.(type) {
+	case string:
+		value = appendBytes(value, 1, []byte(v))
+	case float32:
+		value = appendTag(value, 2, wireFixed32)
+		value = binary.LittleEndian.AppendUint32(value, math.Float32bits(v))
+	case float64:
+		value = appendTag(value, 3, wireFixed64)
+		value = binary.LittleEndian.AppendUint64(value, math.Float64bits(v))
+	case int:
+		value = appendVarintField(value, 6, zigzag64(int64(v)))
+	case int32:
+		value = appendVarintField(value, 6, zigzag64(int64(v)))
+	case int64:
+		value = appendVarintField(value, 6, zigzag64(v))
+	case uint32:
+		value = appendVarintField(value, 5, uint64(v))
+	case uint64:
+		value = appendVarintField(value, 5, v)
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		value = appendVarintField(value, 7, b)
+	default:
+		value = appendBytes(value, 1, []byte(fmt.Sprint(v)))
+	}
+
+	return value
+}
+
+// polygonCommands encodes rings as MoveTo, LineTo and ClosePath commands
+// with zigzag encoded deltas from the previous cursor position
+func polygonCommands(rings [][][2]int) []uint32 {
+	var cmds []uint32
+	var cx, cy int
+
+	for _, ring := range rings {
+		if len(ring) < 3 {
+			continue
+		}
+
+		cmds = append(cmds, command(cmdMoveTo, 1), zigzag32(ring[0][0]-cx), zigzag32(ring[0][1]-cy))
+		cx, cy = ring[0][0], ring[0][1]
+
+		cmds = append(cmds, command(cmdLineTo, len(ring)-1))
+		for _, p := range ring[1:] {
+			cmds = append(cmds, zigzag32(p[0]-cx), zigzag32(p[1]-cy))
+			cx, cy = p[0], p[1]
+		}
+
+		cmds = append(cmds, command(cmdClosePath, 1))
+	}
+
+	return cmds
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag32(n int) uint32 {
+	return uint32((int32(n) << 1) ^ (int32(n) >> 31))
+}
+
+func zigzag64(n int64) uint64 {
+	return uint64((n << 1) ^ (n >> 63))
+}
+
+func unzigzag(n uint64) int64 {
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+func packed(values []uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+
+	return b
+}
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// Decode parses a tile produced by Encode or any other MVT encoder. Only
+// polygon features are returned; integer values decode as int64 and floats
+// as float64.
+func Decode(data []byte) ([]Layer, error) {
+	var layers []Layer
+	err := readFields(data, func(field int, v uint64, b []byte) error {
+		if field != 3 {
+			return nil
+		}
+		layer, err := decodeLayer(b)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+		return nil
+	})
+
+	return layers, err
+}
+
+func decodeLayer(data []byte) (Layer, error) {
+	layer := Layer{Extent: DefaultExtent}
+	var rawFeatures [][]byte
+	var keys []string
+	var values []any
+
+	err := readFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			layer.Name = string(b)
+		case 2:
+			rawFeatures = append(rawFeatures, b)
+		case 3:
+			keys = append(keys, string(b))
+		case 4:
+			value, err := decodeValue(b)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		case 5:
+			layer.Extent = uint32(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return Layer{}, err
+	}
+
+	for _, raw := range rawFeatures {
+		var f Feature
+		var geomType uint64
+		var tags, geometry []uint32
+
+		err := readFields(raw, func(field int, v uint64, b []byte) error {
+			var err error
+			switch field {
+			case 1:
+				f.ID = v
+			case 2:
+				tags, err = unpack(b)
+			case 3:
+				geomType = v
+			case 4:
+				geometry, err = unpack(b)
+			}
+			return err
+		})
+		if err != nil {
+			return Layer{}, err
+		}
+		if geomType != typePolygon {
+			continue
+		}
+
+		if len(tags)%2 != 0 {
+			return Layer{}, errors.New("odd number of feature tags")
+		}
+		f.Properties = map[string]any{}
+		for i := 0; i < len(tags); i += 2 {
+			if int(tags[i]) >= len(keys) || int(tags[i+1]) >= len(values) {
+				return Layer{}, errors.New("feature tag out of range")
+			}
+			f.Properties[keys[tags[i]]] = values[tags[i+1]]
+		}
+
+		if f.Rings, err = decodePolygon(geometry); err != nil {
+			return Layer{}, err
+		}
+		layer.Features = append(layer.Features, f)
+	}
+
+	return layer, nil
+}
+
+func decodeValue(data []byte) (any, error) {
+	var value any
+	err := readFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			value = string(b)
+		case 2:
+			value = float64(math.Float32frombits(uint32(v)))
+		case 3:
+			value = math.Float64frombits(v)
+		case 4:
+			value = int64(v)
+		case 5:
+			value = v
+		case 6:
+			value = unzigzag(v)
+		case 7:
+			value = v != 0
+		}
+		return nil
+	})
+
+	return value, err
+}
+
+func decodePolygon(cmds []uint32) ([][][2]int, error) {
+	var rings [][][2]int
+	var ring [][2]int
+	var cx, cy int
+
+	for i := 0; i < len(cmds); {
+		id, count := int(cmds[i]&0x7), int(cmds[i]>>3)
+		i++
+
+		switch id {
+		case cmdMoveTo, cmdLineTo:
+			if i+2*count > len(cmds) {
+				return nil, errors.New("truncated geometry")
+			}
+			for range count {
+				cx += int(unzigzag(uint64(cmds[i])))
+				cy += int(unzigzag(uint64(cmds[i+1])))
+				i += 2
+				if id == cmdMoveTo {
+					ring = nil
+				}
+				ring = append(ring, [2]int{cx, cy})
+			}
+		case cmdClosePath:
+			rings = append(rings, ring)
+			ring = nil
+		default:
+			return nil, fmt.Errorf("unknown geometry command %d", id)
+		}
+	}
+
+	return rings, nil
+}
+
+func unpack(data []byte) ([]uint32, error) {
+	var values []uint32
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid packed varint")
+		}
+		values = append(values, uint32(v))
+		data = data[n:]
+	}
+
+	return values, nil
+}
+
+// readFields walks a protobuf message, passing varint and fixed values as v
+// and length delimited values as b
+func readFields(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		data = data[n:]
+
+		field, wire := int(key>>3), int(key&0x7)
+		var v uint64
+		var b []byte
+
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			v = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			v = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errors.New("truncated field")
+			}
+			b = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+
+		if err := fn(field, v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
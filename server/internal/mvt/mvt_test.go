@@ -0,0 +1,63 @@
+package mvt_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/mvt"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	layer := mvt.Layer{
+		Name: "heatmap",
+		Features: []mvt.Feature{
+			{
+				ID:         1,
+				Rings:      [][][2]int{{{0, 0}, {100, 0}, {100, 50}, {0, 50}}},
+				Properties: map[string]any{"count": int64(12), "bin": int64(80)},
+			},
+			{
+				ID:         2,
+				Rings:      [][][2]int{{{-10, 4000}, {4106, 4000}, {4106, 4106}, {-10, 4106}}},
+				Properties: map[string]any{"count": int64(-3), "value": 1.5, "name": "x", "hot": true},
+			},
+		},
+	}
+
+	layers, err := mvt.Decode(mvt.Encode(layer))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(layers) != 1 {
+		t.Fatalf("expected 1 layer, got %d", len(layers))
+	}
+
+	got := layers[0]
+	if got.Name != "heatmap" || got.Extent != mvt.DefaultExtent {
+		t.Errorf("unexpected layer header: %q %d", got.Name, got.Extent)
+	}
+	if !reflect.DeepEqual(got.Features, layer.Features) {
+		t.Errorf("features differ:\n got %+v\nwant %+v", got.Features, layer.Features)
+	}
+}
+
+func TestEncodeGeometryCommands(t *testing.T) {
+	// the polygon example from the spec, section 4.3.5.1 (ids and tags omitted)
+	tile := mvt.Encode(mvt.Layer{
+		Name:     "l",
+		Features: []mvt.Feature{{Rings: [][][2]int{{{3, 6}, {8, 12}, {20, 34}}}}},
+	})
+
+	geometry := []byte{9, 6, 12, 18, 10, 12, 24, 44, 15}
+	if !bytes.Contains(tile, append([]byte{0x22, byte(len(geometry))}, geometry...)) {
+		t.Errorf("expected spec geometry encoding in %v", tile)
+	}
+}
+
+func TestDecodeRejectsTruncatedTile(t *testing.T) {
+	tile := mvt.Encode(mvt.Layer{Name: "heatmap", Features: []mvt.Feature{{ID: 1, Rings: [][][2]int{{{0, 0}, {1, 0}, {1, 1}}}}}})
+	if _, err := mvt.Decode(tile[:len(tile)-3]); err == nil {
+		t.Error("expected an error for a truncated tile")
+	}
+}
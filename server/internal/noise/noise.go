@@ -0,0 +1,111 @@
+// Package noise estimates ground level aircraft noise exposure from position fixes
+package noise
+
+import (
+	"math"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+)
+
+// Fix is a single airborne position used as a noise source
+type Fix struct {
+	Lat      float64
+	Lon      float64
+	Altitude float64 // meters
+	Category int32   // OpenSky aircraft category, 0 when unknown
+}
+
+// Cell is the estimated exposure at the centre of a grid cell
+type Cell struct {
+	Lat   float64
+	Lon   float64
+	Level float64 // dB
+}
+
+const (
+	// referenceDistance is the slant distance the source levels are given at
+	referenceDistance = 300.0 // meters
+	// defaultSourceLevel is used when no category weighting is applied
+	defaultSourceLevel = 85.0 // dB
+	// maxHorizontalKm limits how far from a fix its contribution is computed
+	maxHorizontalKm = 5.0
+	// minSlantDistance avoids infinite levels for fixes right above a cell
+	minSlantDistance = 30.0 // meters
+)
+
+// sourceLevels are rough levels at the reference distance per OpenSky category
+var sourceLevels = map[int32]float64{
+	2:  70.0, // light
+	3:  75.0, // small
+	4:  85.0, // large
+	5:  87.0, // high vortex large
+	6:  90.0, // heavy
+	7:  92.0, // high performance
+	8:  82.0, // rotorcraft
+	9:  40.0, // glider
+	10: 45.0, // lighter than air
+	12: 60.0, // ultralight
+	14: 65.0, // UAV
+}
+
+// SourceLevel returns the level at the reference distance for a category.
+// Unknown categories fall back to the unweighted default.
+func SourceLevel(category int32, weighted bool) float64 {
+	if !weighted {
+		return defaultSourceLevel
+	}
+	if level, ok := sourceLevels[category]; ok {
+		return level
+	}
+
+	return defaultSourceLevel
+}
+
+// LevelAt estimates the level a fix produces at a ground point using
+// spherical spreading over the slant distance
+func LevelAt(f Fix, lat, lon float64, weighted bool) float64 {
+	horizontal := opensky.Haversine(f.Lat, f.Lon, lat, lon) * 1000
+	slant := math.Max(math.Hypot(horizontal, math.Max(f.Altitude, 0)), minSlantDistance)
+
+	return SourceLevel(f.Category, weighted) - 20*math.Log10(slant/referenceDistance)
+}
+
+// Grid sums the exposure of all fixes into cells of 1/binSize degrees.
+// Levels are summed energetically, so two equal sources give +3 dB.
+func Grid(fixes []Fix, binSize float64, weighted bool) []Cell {
+	type key struct{ lat, lon int64 }
+	energy := map[key]float64{}
+
+	for _, f := range fixes {
+		latSpan := maxHorizontalKm / 111.0
+		lonSpan := maxHorizontalKm / (111.0 * math.Cos(f.Lat*math.Pi/180))
+
+		latMin := int64(math.Floor((f.Lat - latSpan) * binSize))
+		latMax := int64(math.Floor((f.Lat + latSpan) * binSize))
+		lonMin := int64(math.Floor((f.Lon - lonSpan) * binSize))
+		lonMax := int64(math.Floor((f.Lon + lonSpan) * binSize))
+
+		for i := latMin; i <= latMax; i++ {
+			for j := lonMin; j <= lonMax; j++ {
+				lat := (float64(i) + 0.5) / binSize
+				lon := (float64(j) + 0.5) / binSize
+				if opensky.Haversine(f.Lat, f.Lon, lat, lon) > maxHorizontalKm {
+					continue
+				}
+
+				energy[key{i, j}] += math.Pow(10, LevelAt(f, lat, lon, weighted)/10)
+			}
+		}
+	}
+
+	cells := make([]Cell, 0, len(energy))
+	for k, e := range energy {
+		cells = append(cells, Cell{
+			Lat:   (float64(k.lat) + 0.5) / binSize,
+			Lon:   (float64(k.lon) + 0.5) / binSize,
+			Level: 10 * math.Log10(e),
+		})
+	}
+
+	return cells
+}
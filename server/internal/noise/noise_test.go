@@ -0,0 +1,55 @@
+package noise_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/noise"
+)
+
+func TestLevelAtDecreasesWithDistance(t *testing.T) {
+	fix := noise.Fix{Lat: 60.3, Lon: 24.9, Altitude: 300}
+
+	below := noise.LevelAt(fix, 60.3, 24.9, false)
+	if math.Abs(below-85) > 0.01 {
+		t.Errorf("expected 85 dB right below the fix, got %.2f", below)
+	}
+
+	away := noise.LevelAt(fix, 60.33, 24.9, false)
+	if away >= below {
+		t.Errorf("expected level to drop with distance, got %.2f >= %.2f", away, below)
+	}
+}
+
+func TestSourceLevelWeighting(t *testing.T) {
+	if noise.SourceLevel(6, true) <= noise.SourceLevel(2, true) {
+		t.Error("expected heavy aircraft to be louder than light aircraft")
+	}
+
+	if noise.SourceLevel(6, false) != noise.SourceLevel(2, false) {
+		t.Error("expected equal levels without weighting")
+	}
+}
+
+func TestGridSumsEnergy(t *testing.T) {
+	fix := noise.Fix{Lat: 60.30625, Lon: 24.90625, Altitude: 500}
+
+	single := findCell(t, noise.Grid([]noise.Fix{fix}, 80, false), fix.Lat, fix.Lon)
+	double := findCell(t, noise.Grid([]noise.Fix{fix, fix}, 80, false), fix.Lat, fix.Lon)
+
+	if diff := double.Level - single.Level; math.Abs(diff-10*math.Log10(2)) > 0.01 {
+		t.Errorf("expected two equal sources to add ~3 dB, got %.2f", diff)
+	}
+}
+
+func findCell(t *testing.T, cells []noise.Cell, lat, lon float64) noise.Cell {
+	t.Helper()
+	for _, c := range cells {
+		if math.Abs(c.Lat-lat) < 1e-9 && math.Abs(c.Lon-lon) < 1e-9 {
+			return c
+		}
+	}
+	t.Fatalf("no cell at %f,%f", lat, lon)
+
+	return noise.Cell{}
+}
@@ -22,6 +22,14 @@ func toNullFloat64(v any) sql.NullFloat64 {
 	}
 }
 
+func toNullInt32(v any) sql.NullInt32 {
+	f, ok := v.(float64)
+	return sql.NullInt32{
+		Int32: int32(f),
+		Valid: ok,
+	}
+}
+
 func toNullBool(v any) sql.NullBool {
 	b, ok := v.(bool)
 	return sql.NullBool{
@@ -108,3 +116,34 @@ func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 	return R * c
 }
+
+// PointInPolygon reports whether lat/lon lies inside a polygon given as rings
+// of [lon, lat] pairs. The first ring is the exterior, the rest are holes.
+func PointInPolygon(lat, lon float64, rings [][][2]float64) bool {
+	if len(rings) == 0 || !pointInRing(lat, lon, rings[0]) {
+		return false
+	}
+
+	for _, hole := range rings[1:] {
+		if pointInRing(lat, lon, hole) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pointInRing is a ray casting test treating coordinates as planar
+func pointInRing(lat, lon float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
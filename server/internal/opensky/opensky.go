@@ -26,12 +26,18 @@ type positionInserter interface {
 	InsertPosition(ctx context.Context, params repository.InsertPositionParams) error
 }
 
+// PollHook is notified with the positions stored by each poll
+type PollHook interface {
+	AfterPoll(ctx context.Context, positions []repository.InsertPositionParams)
+}
+
 type Fetcher struct {
 	Client       *http.Client
 	TokenFetcher func(cfg config.Config) (string, error)
 	Inserter     positionInserter
 	Config       config.Config
 	APIURL       string
+	Hooks        []PollHook
 
 	nextAllowed time.Time
 }
@@ -85,6 +91,7 @@ func (f *Fetcher) storeStates(ctx context.Context, states [][]any) error {
 	const maxDistanceFromEFHK = 50.0 // in km
 	const maxAltitude = 10000.0      // meters
 	duplicateErrors := 0
+	stored := make([]repository.InsertPositionParams, 0, len(states))
 
 	for _, s := range states {
 		if len(s) < 12 {
@@ -126,6 +133,16 @@ func (f *Fetcher) storeStates(ctx context.Context, states [][]any) error {
 			VerticalRate:  toNullFloat64(s[11]),
 		}
 
+		if len(s) > 15 {
+			params.Squawk = toNullString(s[14])
+			params.Spi = toNullBool(s[15])
+		}
+
+		// Category is only present when the request asks for extended state vectors
+		if len(s) > 17 {
+			params.Category = toNullInt32(s[17])
+		}
+
 		err := f.Inserter.InsertPosition(ctx, params)
 		if err != nil {
 			if isDuplicateError(err) {
@@ -133,12 +150,19 @@ func (f *Fetcher) storeStates(ctx context.Context, states [][]any) error {
 			} else {
 				log.Printf("insert failed: %v", err)
 			}
+			continue
 		}
+
+		stored = append(stored, params)
 	}
 
 	log.Print("Done inserting")
 	log.Printf("duplicates %d", duplicateErrors)
 
+	for _, hook := range f.Hooks {
+		hook.AfterPoll(ctx, stored)
+	}
+
 	return nil
 }
 
@@ -79,3 +79,67 @@ func TestFetchAndInsert(t *testing.T) {
 		t.Errorf("expected OnGround=false, got: %v", insert.OnGround)
 	}
 }
+
+type recordingHook struct {
+	positions []repository.InsertPositionParams
+}
+
+func (h *recordingHook) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	h.positions = append(h.positions, positions...)
+}
+
+func TestFetchAndStoreCallsHooks(t *testing.T) {
+	mockResponse := map[string]any{
+		"states": [][]any{
+			{"abc123", "TEST123", "Finland", 1624281000.0, nil, 24.75, 60.25, 3000.0, false, 250.0, 180.0, 5.0},
+			// on ground, not stored
+			{"def456", "TEST456", "Finland", 1624281000.0, nil, 24.95, 60.31, 0.0, true, 0.0, 0.0, 0.0},
+		},
+	}
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mockResponse)
+	}))
+	defer apiServer.Close()
+
+	hook := &recordingHook{}
+	f := opensky.Fetcher{
+		Client:       apiServer.Client(),
+		TokenFetcher: func(cfg config.Config) (string, error) { return "mock-token", nil },
+		Inserter:     &mockDB{},
+		APIURL:       apiServer.URL,
+		Hooks:        []opensky.PollHook{hook},
+	}
+
+	if err := f.FetchAndStore(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(hook.positions) != 1 || hook.positions[0].Icao24.String != "abc123" {
+		t.Errorf("expected hook to receive the stored position, got %+v", hook.positions)
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	square := [][][2]float64{
+		{{24.0, 60.0}, {26.0, 60.0}, {26.0, 61.0}, {24.0, 61.0}, {24.0, 60.0}},
+		{{24.8, 60.4}, {25.2, 60.4}, {25.2, 60.6}, {24.8, 60.6}, {24.8, 60.4}}, // hole
+	}
+
+	cases := []struct {
+		lat, lon float64
+		want     bool
+	}{
+		{60.2, 24.5, true},
+		{60.5, 25.0, false}, // in the hole
+		{61.5, 25.0, false},
+		{60.5, 23.0, false},
+	}
+
+	for _, c := range cases {
+		if got := opensky.PointInPolygon(c.lat, c.lon, square); got != c.want {
+			t.Errorf("PointInPolygon(%v, %v) = %v, want %v", c.lat, c.lon, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package overflight groups position fixes near a point into overflights
+package overflight
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/noise"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+)
+
+// passGap splits fixes of the same aircraft into separate overflights
+const passGap = 10 * time.Minute
+
+// loudestLimit is how many overflights are returned as the loudest ones
+const loudestLimit = 10
+
+type Fix struct {
+	Icao24   string
+	Callsign string
+	Time     time.Time
+	Lat      float64
+	Lon      float64
+	Altitude float64
+	Category int32
+}
+
+// Pass is a single overflight of an aircraft within the radius
+type Pass struct {
+	Icao24          string    `json:"icao24"`
+	Callsign        string    `json:"callsign"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	ClosestTime     time.Time `json:"closest_time"`
+	ClosestDistance float64   `json:"closest_distance_m"`
+	ClosestAltitude float64   `json:"closest_altitude_m"`
+	Level           float64   `json:"level_db"`
+}
+
+type Bucket struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
+
+type Stats struct {
+	Total   int      `json:"total"`
+	PerHour []Bucket `json:"per_hour"`
+	PerDay  []Bucket `json:"per_day"`
+	Closest *Pass    `json:"closest"`
+	Loudest []Pass   `json:"loudest"`
+}
+
+// Analyze computes overflight statistics for the point from fixes within radiusM
+func Analyze(lat, lon, radiusM float64, fixes []Fix) Stats {
+	sorted := make([]Fix, len(fixes))
+	copy(sorted, fixes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Icao24 != sorted[j].Icao24 {
+			return sorted[i].Icao24 < sorted[j].Icao24
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var passes []Pass
+	var current *Pass
+
+	for _, f := range sorted {
+		distance := opensky.Haversine(lat, lon, f.Lat, f.Lon) * 1000
+		if distance > radiusM {
+			continue
+		}
+
+		level := noise.LevelAt(noise.Fix{Lat: f.Lat, Lon: f.Lon, Altitude: f.Altitude, Category: f.Category}, lat, lon, true)
+
+		if current == nil || current.Icao24 != f.Icao24 || f.Time.Sub(current.End) > passGap {
+			if current != nil {
+				passes = append(passes, *current)
+			}
+			current = &Pass{
+				Icao24:          f.Icao24,
+				Callsign:        f.Callsign,
+				Start:           f.Time,
+				End:             f.Time,
+				ClosestTime:     f.Time,
+				ClosestDistance: distance,
+				ClosestAltitude: f.Altitude,
+				Level:           level,
+			}
+			continue
+		}
+
+		current.End = f.Time
+		if current.Callsign == "" {
+			current.Callsign = f.Callsign
+		}
+		if distance < current.ClosestDistance {
+			current.ClosestTime = f.Time
+			current.ClosestDistance = distance
+			current.ClosestAltitude = f.Altitude
+		}
+		if level > current.Level {
+			current.Level = level
+		}
+	}
+	if current != nil {
+		passes = append(passes, *current)
+	}
+
+	stats := Stats{
+		Total:   len(passes),
+		PerHour: countBy(passes, func(t time.Time) time.Time { return t.Truncate(time.Hour) }),
+		PerDay: countBy(passes, func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}),
+		Loudest: []Pass{},
+	}
+
+	for i := range passes {
+		if stats.Closest == nil || passes[i].ClosestDistance < stats.Closest.ClosestDistance {
+			stats.Closest = &passes[i]
+		}
+	}
+
+	loudest := make([]Pass, len(passes))
+	copy(loudest, passes)
+	sort.SliceStable(loudest, func(i, j int) bool { return loudest[i].Level > loudest[j].Level })
+	if len(loudest) > loudestLimit {
+		loudest = loudest[:loudestLimit]
+	}
+	stats.Loudest = append(stats.Loudest, loudest...)
+
+	return stats
+}
+
+// countBy buckets passes by their closest approach time
+func countBy(passes []Pass, bucket func(time.Time) time.Time) []Bucket {
+	counts := map[time.Time]int{}
+	for _, p := range passes {
+		counts[bucket(p.ClosestTime)]++
+	}
+
+	buckets := make([]Bucket, 0, len(counts))
+	for t, c := range counts {
+		buckets = append(buckets, Bucket{Time: t, Count: c})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Time.Before(buckets[j].Time) })
+
+	return buckets
+}
@@ -0,0 +1,43 @@
+package overflight_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/overflight"
+)
+
+func TestAnalyzeGroupsPasses(t *testing.T) {
+	start := time.Date(2025, 7, 24, 8, 0, 0, 0, time.UTC)
+	fixes := []overflight.Fix{
+		{Icao24: "abc123", Callsign: "FIN1", Time: start, Lat: 60.30, Lon: 24.95, Altitude: 900},
+		{Icao24: "abc123", Callsign: "FIN1", Time: start.Add(time.Minute), Lat: 60.31, Lon: 24.95, Altitude: 800},
+		// same aircraft, back an hour later
+		{Icao24: "abc123", Callsign: "FIN1", Time: start.Add(time.Hour), Lat: 60.305, Lon: 24.95, Altitude: 1500},
+		{Icao24: "def456", Callsign: "SAS2", Time: start.Add(2 * time.Minute), Lat: 60.32, Lon: 24.95, Altitude: 600},
+		// outside the radius
+		{Icao24: "ghi789", Callsign: "NAX3", Time: start, Lat: 60.60, Lon: 24.95, Altitude: 600},
+	}
+
+	stats := overflight.Analyze(60.31, 24.95, 2000, fixes)
+
+	if stats.Total != 3 {
+		t.Fatalf("expected 3 overflights, got %d", stats.Total)
+	}
+
+	if len(stats.PerHour) != 2 || stats.PerHour[0].Count != 2 || stats.PerHour[1].Count != 1 {
+		t.Errorf("unexpected hourly counts: %+v", stats.PerHour)
+	}
+
+	if len(stats.PerDay) != 1 || stats.PerDay[0].Count != 3 {
+		t.Errorf("unexpected daily counts: %+v", stats.PerDay)
+	}
+
+	if stats.Closest == nil || stats.Closest.Icao24 != "abc123" || stats.Closest.ClosestAltitude != 800 {
+		t.Errorf("unexpected closest approach: %+v", stats.Closest)
+	}
+
+	if len(stats.Loudest) != 3 || stats.Loudest[0].Level < stats.Loudest[2].Level {
+		t.Errorf("expected loudest overflights in descending order: %+v", stats.Loudest)
+	}
+}
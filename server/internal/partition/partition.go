@@ -0,0 +1,208 @@
+// Package partition keeps the daily partitions of aircraft_positions: it
+// creates upcoming days ahead of ingestion and removes days that have expired
+package partition
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+const (
+	table  = "aircraft_positions"
+	prefix = table + "_p"
+	// defaultPartition holds fixes for days without a partition of their own
+	defaultPartition = table + "_default"
+	// nameLayout is the date suffix of a partition name, as in the migration
+	nameLayout = "20060102"
+	day        = 24 * time.Hour
+)
+
+// DB runs the partition DDL. It takes identifiers, so it can't go through
+// sqlc queries.
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type Querier interface {
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	ListPositionPartitions(ctx context.Context) ([]sql.NullString, error)
+	CountPositionsInRange(ctx context.Context, arg repository.CountPositionsInRangeParams) (int64, error)
+}
+
+// metrics are published under "partition" on /debug/vars
+var metrics = expvar.NewMap("partition")
+
+// Manager creates a partition for each of the next Ahead days and removes
+// the ones that ended more than Retention ago. Expired partitions are only
+// removed once the rollups cover them, and with Detach they are kept as
+// standalone tables instead of being dropped. This is where raw fixes expire,
+// retention only thins them.
+type Manager struct {
+	DB        DB
+	Queries   Querier
+	Ahead     int
+	Retention time.Duration
+	Detach    bool
+	Now       func() time.Time
+}
+
+func NewManager(db DB, queries Querier, retention time.Duration) *Manager {
+	return &Manager{
+		DB:        db,
+		Queries:   queries,
+		Ahead:     7,
+		Retention: retention,
+		Now:       time.Now,
+	}
+}
+
+// Start maintains the partitions every interval until ctx is cancelled
+func (m *Manager) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Run(ctx); err != nil {
+					log.Printf("partition maintenance failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run creates the upcoming partitions and removes expired ones. A day that
+// can't be created is retried on the next run and doesn't hold up expiry.
+func (m *Manager) Run(ctx context.Context) error {
+	metrics.Add("runs", 1)
+	today := m.Now().UTC().Truncate(day)
+
+	existing, err := m.partitions(ctx)
+	if err != nil {
+		metrics.Add("errors", 1)
+		return err
+	}
+
+	var errs []error
+	for i := 0; i <= m.Ahead; i++ {
+		start := today.Add(time.Duration(i) * day)
+		if _, ok := existing[start]; ok {
+			continue
+		}
+
+		name := prefix + start.Format(nameLayout)
+		if err := m.create(ctx, name, start); err != nil {
+			metrics.Add("errors", 1)
+			errs = append(errs, fmt.Errorf("create %s: %w", name, err))
+			continue
+		}
+		metrics.Add("created_partitions", 1)
+		log.Printf("created partition %s", name)
+	}
+
+	if err := m.expire(ctx, existing); err != nil {
+		metrics.Add("errors", 1)
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// create adds the partition for the day from start. Fixes that arrived
+// before it sit in the default partition, where they would make attaching
+// the day fail, so they are moved into the new table first. lib/pq runs the
+// statements as one implicit transaction.
+func (m *Manager) create(ctx context.Context, name string, start time.Time) error {
+	from, to := start.Format(time.DateOnly), start.Add(day).Format(time.DateOnly)
+
+	_, err := m.DB.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE %[1]s (LIKE %[2]s INCLUDING DEFAULTS); "+
+			"WITH moved AS (DELETE FROM %[3]s WHERE time_position >= '%[4]s' AND time_position < '%[5]s' RETURNING *) "+
+			"INSERT INTO %[1]s SELECT * FROM moved; "+
+			"ALTER TABLE %[2]s ATTACH PARTITION %[1]s FOR VALUES FROM ('%[4]s') TO ('%[5]s')",
+		name, table, defaultPartition, from, to,
+	))
+
+	return err
+}
+
+// expire drops or detaches the partitions that ended before both the
+// retention cutoff and the rollup watermark
+func (m *Manager) expire(ctx context.Context, existing map[time.Time]string) error {
+	rolledUntil, err := m.Queries.GetRollupWatermark(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	expireBefore := m.Now().UTC().Add(-m.Retention)
+	if rolledUntil.Before(expireBefore) {
+		expireBefore = rolledUntil
+	}
+
+	starts := slices.SortedFunc(maps.Keys(existing), time.Time.Compare)
+	for _, start := range starts {
+		if start.Add(day).After(expireBefore) {
+			break
+		}
+		name := existing[start]
+
+		rows, err := m.Queries.CountPositionsInRange(ctx, repository.CountPositionsInRangeParams{FromTime: start, ToTime: start.Add(day)})
+		if err != nil {
+			return fmt.Errorf("count %s: %w", name, err)
+		}
+
+		stmt := fmt.Sprintf("DROP TABLE %s", name)
+		if m.Detach {
+			stmt = fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", table, name)
+		}
+		if _, err := m.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("expire %s: %w", name, err)
+		}
+		metrics.Add("expired_partitions", 1)
+		metrics.Add("expired_rows", rows)
+		log.Printf("expired partition %s with %d fixes", name, rows)
+	}
+
+	return nil
+}
+
+// partitions returns the attached daily partitions by their first day
+func (m *Manager) partitions(ctx context.Context) (map[time.Time]string, error) {
+	names, err := m.Queries.ListPositionPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := map[time.Time]string{}
+	for _, name := range names {
+		// the default partition and anything not named by day is left alone
+		suffix, ok := strings.CutPrefix(name.String, prefix)
+		if !ok {
+			continue
+		}
+		start, err := time.Parse(nameLayout, suffix)
+		if err != nil {
+			continue
+		}
+		partitions[start] = name.String
+	}
+
+	return partitions, nil
+}
@@ -0,0 +1,200 @@
+package partition_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/partition"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type mockDB struct {
+	statements []string
+	// fail makes statements containing it fail
+	fail string
+}
+
+func (m *mockDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if m.fail != "" && strings.Contains(query, m.fail) {
+		return nil, errors.New("exec failed")
+	}
+	m.statements = append(m.statements, query)
+	return nil, nil
+}
+
+type mockQueries struct {
+	rolledUntil time.Time
+	partitions  []string
+	counted     []repository.CountPositionsInRangeParams
+}
+
+func (m *mockQueries) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	if m.rolledUntil.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.rolledUntil, nil
+}
+
+// CountPositionsInRange counts 10 fixes in every day
+func (m *mockQueries) CountPositionsInRange(ctx context.Context, arg repository.CountPositionsInRangeParams) (int64, error) {
+	m.counted = append(m.counted, arg)
+	return 10, nil
+}
+
+func (m *mockQueries) ListPositionPartitions(ctx context.Context) ([]sql.NullString, error) {
+	names := make([]sql.NullString, 0, len(m.partitions))
+	for _, p := range m.partitions {
+		names = append(names, sql.NullString{String: p, Valid: true})
+	}
+	return names, nil
+}
+
+var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestRunCreatesUpcomingPartitions(t *testing.T) {
+	db := &mockDB{}
+	m := partition.NewManager(db, &mockQueries{partitions: []string{
+		"aircraft_positions_default",
+		"aircraft_positions_p20261019",
+		"aircraft_positions_p20261020",
+	}}, 30*24*time.Hour)
+	m.Ahead = 3
+	m.Now = func() time.Time { return now }
+
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// fixes that arrived before their day's partition move out of the default one
+	want := []string{
+		"CREATE TABLE aircraft_positions_p20261021 (LIKE aircraft_positions INCLUDING DEFAULTS); " +
+			"WITH moved AS (DELETE FROM aircraft_positions_default WHERE time_position >= '2026-10-21' AND time_position < '2026-10-22' RETURNING *) " +
+			"INSERT INTO aircraft_positions_p20261021 SELECT * FROM moved; " +
+			"ALTER TABLE aircraft_positions ATTACH PARTITION aircraft_positions_p20261021 FOR VALUES FROM ('2026-10-21') TO ('2026-10-22')",
+		"CREATE TABLE aircraft_positions_p20261022 (LIKE aircraft_positions INCLUDING DEFAULTS); " +
+			"WITH moved AS (DELETE FROM aircraft_positions_default WHERE time_position >= '2026-10-22' AND time_position < '2026-10-23' RETURNING *) " +
+			"INSERT INTO aircraft_positions_p20261022 SELECT * FROM moved; " +
+			"ALTER TABLE aircraft_positions ATTACH PARTITION aircraft_positions_p20261022 FOR VALUES FROM ('2026-10-22') TO ('2026-10-23')",
+	}
+	if len(db.statements) != len(want) {
+		t.Fatalf("unexpected statements %q", db.statements)
+	}
+	for i := range want {
+		if db.statements[i] != want[i] {
+			t.Errorf("expected %q, got %q", want[i], db.statements[i])
+		}
+	}
+}
+
+func TestRunExpiresPartitions(t *testing.T) {
+	queries := &mockQueries{
+		rolledUntil: now.Add(-time.Hour),
+		partitions: []string{
+			"aircraft_positions_default",
+			"aircraft_positions_p20260918",
+			"aircraft_positions_p20260919",
+			"aircraft_positions_p20260920",
+		},
+	}
+	for i := 0; i <= 7; i++ {
+		queries.partitions = append(queries.partitions, "aircraft_positions_p"+now.AddDate(0, 0, i).Format("20060102"))
+	}
+
+	db := &mockDB{}
+	m := partition.NewManager(db, queries, 30*24*time.Hour)
+	m.Now = func() time.Time { return now }
+
+	partitions, rows := counter("expired_partitions"), counter("expired_rows")
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cutoff is at noon on 19 Sep, so that day still has fixes to keep
+	if len(db.statements) != 1 || db.statements[0] != "DROP TABLE aircraft_positions_p20260918" {
+		t.Errorf("unexpected statements %q", db.statements)
+	}
+	if len(queries.counted) != 1 || !queries.counted[0].FromTime.Equal(time.Date(2026, 9, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the expired day's fixes to be counted, got %+v", queries.counted)
+	}
+	if counter("expired_partitions")-partitions != 1 || counter("expired_rows")-rows != 10 {
+		t.Errorf("unexpected metrics %s", expvar.Get("partition"))
+	}
+
+	db = &mockDB{}
+	m.DB = db
+	m.Detach = true
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || db.statements[0] != "ALTER TABLE aircraft_positions DETACH PARTITION aircraft_positions_p20260918" {
+		t.Errorf("unexpected statements %q", db.statements)
+	}
+}
+
+func TestRunKeepsPartitionsWithoutRollups(t *testing.T) {
+	queries := &mockQueries{
+		rolledUntil: time.Date(2026, 9, 19, 0, 0, 0, 0, time.UTC),
+		partitions:  []string{"aircraft_positions_p20260901", "aircraft_positions_p20260919"},
+	}
+	for i := 0; i <= 7; i++ {
+		queries.partitions = append(queries.partitions, "aircraft_positions_p"+now.AddDate(0, 0, i).Format("20060102"))
+	}
+
+	db := &mockDB{}
+	m := partition.NewManager(db, queries, 24*time.Hour)
+	m.Now = func() time.Time { return now }
+
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || db.statements[0] != "DROP TABLE aircraft_positions_p20260901" {
+		t.Errorf("expected only partitions the rollups cover to go, got %q", db.statements)
+	}
+
+	queries.rolledUntil = time.Time{}
+	db.statements = nil
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 0 {
+		t.Errorf("expected nothing to go without rollups, got %q", db.statements)
+	}
+}
+
+func TestRunExpiresAfterAFailedCreate(t *testing.T) {
+	queries := &mockQueries{
+		rolledUntil: now.Add(-time.Hour),
+		partitions:  []string{"aircraft_positions_p20260901", "aircraft_positions_p20261019"},
+	}
+
+	db := &mockDB{fail: "aircraft_positions_p20261020"}
+	m := partition.NewManager(db, queries, 24*time.Hour)
+	m.Ahead = 2
+	m.Now = func() time.Time { return now }
+
+	failed := counter("errors")
+	err := m.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "create aircraft_positions_p20261020") {
+		t.Errorf("expected the failed day to be reported, got %v", err)
+	}
+	if counter("errors")-failed != 1 {
+		t.Errorf("unexpected metrics %s", expvar.Get("partition"))
+	}
+
+	// the next day and the expiry still ran
+	if len(db.statements) != 2 || !strings.Contains(db.statements[0], "aircraft_positions_p20261021") || db.statements[1] != "DROP TABLE aircraft_positions_p20260901" {
+		t.Errorf("unexpected statements %q", db.statements)
+	}
+}
+
+func counter(name string) int64 {
+	if v, ok := expvar.Get("partition").(*expvar.Map).Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+// Package raster renders weighted points into a heatmap image
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Point is a weighted sample in pixel coordinates
+type Point struct {
+	X, Y   float64
+	Weight float64
+}
+
+type Stop struct {
+	Pos   float64
+	Color color.NRGBA
+}
+
+// Ramp maps an intensity in [0, 1] to a colour through sorted stops
+type Ramp []Stop
+
+// Ramps are the named colour ramps accepted by ParseRamp
+var Ramps = map[string]Ramp{
+	// the leaflet.heat default gradient so images match the web map
+	"heat": {
+		{0, color.NRGBA{0, 0, 255, 255}},
+		{0.4, color.NRGBA{0, 0, 255, 255}},
+		{0.6, color.NRGBA{0, 255, 255, 255}},
+		{0.7, color.NRGBA{0, 255, 0, 255}},
+		{0.8, color.NRGBA{255, 255, 0, 255}},
+		{1, color.NRGBA{255, 0, 0, 255}},
+	},
+	"viridis": {
+		{0, color.NRGBA{0x44, 0x01, 0x54, 255}},
+		{0.25, color.NRGBA{0x3b, 0x52, 0x8b, 255}},
+		{0.5, color.NRGBA{0x21, 0x91, 0x8c, 255}},
+		{0.75, color.NRGBA{0x5e, 0xc9, 0x62, 255}},
+		{1, color.NRGBA{0xfd, 0xe7, 0x25, 255}},
+	},
+	"magma": {
+		{0, color.NRGBA{0x00, 0x00, 0x04, 255}},
+		{0.25, color.NRGBA{0x51, 0x12, 0x7c, 255}},
+		{0.5, color.NRGBA{0xb7, 0x37, 0x79, 255}},
+		{0.75, color.NRGBA{0xfc, 0x89, 0x61, 255}},
+		{1, color.NRGBA{0xfc, 0xfd, 0xbf, 255}},
+	},
+	"gray": {
+		{0, color.NRGBA{0, 0, 0, 255}},
+		{1, color.NRGBA{255, 255, 255, 255}},
+	},
+}
+
+// ParseRamp accepts a ramp name or a list of stops such as
+// "0:0000ff,0.5:00ff00,1:ff0000"
+func ParseRamp(s string) (Ramp, error) {
+	if ramp, ok := Ramps[s]; ok {
+		return ramp, nil
+	}
+
+	var ramp Ramp
+	for _, part := range strings.Split(s, ",") {
+		pos, hex, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("unknown ramp %q", s)
+		}
+
+		p, err := strconv.ParseFloat(pos, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, errors.New("ramp positions must be between 0 and 1")
+		}
+
+		hex = strings.TrimPrefix(hex, "#")
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, errors.New("ramp colours must be 6 digit hex")
+		}
+
+		ramp = append(ramp, Stop{Pos: p, Color: color.NRGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}})
+	}
+
+	if len(ramp) < 2 {
+		return nil, errors.New("ramp needs at least two stops")
+	}
+	sort.Slice(ramp, func(i, j int) bool { return ramp[i].Pos < ramp[j].Pos })
+
+	return ramp, nil
+}
+
+// At interpolates the ramp colour for t
+func (r Ramp) At(t float64) color.NRGBA {
+	if t <= r[0].Pos {
+		return r[0].Color
+	}
+
+	for i := 1; i < len(r); i++ {
+		if t <= r[i].Pos {
+			a, b := r[i-1], r[i]
+			f := (t - a.Pos) / (b.Pos - a.Pos)
+			lerp := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + f*(float64(y)-float64(x)))) }
+			return color.NRGBA{lerp(a.Color.R, b.Color.R), lerp(a.Color.G, b.Color.G), lerp(a.Color.B, b.Color.B), 255}
+		}
+	}
+
+	return r[len(r)-1].Color
+}
+
+type Options struct {
+	Width, Height int
+	// Radius is the kernel's standard deviation in pixels
+	Radius float64
+	// Max is the density shown at full intensity. When zero the image's own
+	// peak is used, which makes adjacent tiles scale differently.
+	Max  float64
+	Ramp Ramp
+}
+
+// Render estimates density with a Gaussian kernel and colours it with the
+// ramp. Opacity grows with intensity so empty areas stay transparent.
+func Render(points []Point, opts Options) *image.NRGBA {
+	w, h := opts.Width, opts.Height
+	density := make([]float64, w*h)
+
+	sigma := opts.Radius
+	reach := int(math.Ceil(3 * sigma))
+	twoSigmaSq := 2 * sigma * sigma
+
+	for _, p := range points {
+		minX, maxX := max(0, int(p.X)-reach), min(w-1, int(p.X)+reach)
+		minY, maxY := max(0, int(p.Y)-reach), min(h-1, int(p.Y)+reach)
+
+		for y := minY; y <= maxY; y++ {
+			dy := float64(y) + 0.5 - p.Y
+			for x := minX; x <= maxX; x++ {
+				dx := float64(x) + 0.5 - p.X
+				density[y*w+x] += p.Weight * math.Exp(-(dx*dx+dy*dy)/twoSigmaSq)
+			}
+		}
+	}
+
+	peak := opts.Max
+	if peak <= 0 {
+		for _, d := range density {
+			peak = math.Max(peak, d)
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if peak == 0 {
+		return img
+	}
+
+	for i, d := range density {
+		t := math.Min(1, d/peak)
+		if t < 1.0/255 {
+			continue
+		}
+
+		c := opts.Ramp.At(t)
+		c.A = uint8(math.Round(255 * math.Min(1, 2*t)))
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = c.R, c.G, c.B, c.A
+	}
+
+	return img
+}
@@ -0,0 +1,59 @@
+package raster_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/raster"
+)
+
+func TestParseRamp(t *testing.T) {
+	ramp, err := raster.ParseRamp("1:ff0000,0:0000ff")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ramp.At(0.5); got != (color.NRGBA{128, 0, 128, 255}) {
+		t.Errorf("unexpected midpoint colour: %v", got)
+	}
+
+	if _, err := raster.ParseRamp("viridis"); err != nil {
+		t.Errorf("expected named ramp, got %v", err)
+	}
+
+	for _, s := range []string{"rainbow", "0:ff0000", "0:ff00,1:00ff00", "2:ff0000,1:00ff00"} {
+		if _, err := raster.ParseRamp(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	img := raster.Render([]raster.Point{{X: 32, Y: 32, Weight: 5}}, raster.Options{
+		Width:  64,
+		Height: 64,
+		Radius: 4,
+		Ramp:   raster.Ramps["heat"],
+	})
+
+	if c := img.NRGBAAt(32, 32); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the peak to be opaque red, got %v", c)
+	}
+	if c := img.NRGBAAt(0, 0); c.A != 0 {
+		t.Errorf("expected a transparent corner, got %v", c)
+	}
+	if c := img.NRGBAAt(38, 32); c.A == 0 || c.A == 255 {
+		t.Errorf("expected partial opacity on the slope, got %v", c)
+	}
+
+	// a fixed scale keeps a weak point from reaching full intensity
+	img = raster.Render([]raster.Point{{X: 32, Y: 32, Weight: 1}}, raster.Options{
+		Width:  64,
+		Height: 64,
+		Radius: 4,
+		Max:    10,
+		Ramp:   raster.Ramps["heat"],
+	})
+	if c := img.NRGBAAt(32, 32); c.A == 255 {
+		t.Errorf("expected the weak peak to be translucent, got %v", c)
+	}
+}
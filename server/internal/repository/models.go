@@ -6,6 +6,8 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
 type AircraftPosition struct {
@@ -13,7 +15,7 @@ type AircraftPosition struct {
 	Icao24        sql.NullString
 	Callsign      sql.NullString
 	OriginCountry sql.NullString
-	TimePosition  sql.NullTime
+	TimePosition  time.Time
 	Longitude     sql.NullFloat64
 	Latitude      sql.NullFloat64
 	BaroAltitude  sql.NullFloat64
@@ -21,4 +23,93 @@ type AircraftPosition struct {
 	Velocity      sql.NullFloat64
 	Heading       sql.NullFloat64
 	VerticalRate  sql.NullFloat64
+	Category      sql.NullInt32
+	Squawk        sql.NullString
+	Spi           sql.NullBool
+}
+
+type Alert struct {
+	ID           int32
+	Type         sql.NullString
+	Icao24       sql.NullString
+	Callsign     sql.NullString
+	Squawk       sql.NullString
+	TimePosition time.Time
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+	Track        json.RawMessage
+	CreatedAt    time.Time
+}
+
+type Geofence struct {
+	ID        int32
+	Name      sql.NullString
+	Geometry  json.RawMessage
+	CreatedAt time.Time
+}
+
+type GeofenceEvent struct {
+	ID           int32
+	GeofenceID   int32
+	Icao24       sql.NullString
+	Callsign     sql.NullString
+	Event        sql.NullString
+	TimePosition time.Time
+	BaroAltitude sql.NullFloat64
+}
+
+type HeatmapRollup struct {
+	BinSize     int32
+	Granularity sql.NullString
+	Bucket      time.Time
+	LatBin      sql.NullFloat64
+	LonBin      sql.NullFloat64
+	Count       int64
+}
+
+type MonitoringPoint struct {
+	ID        int32
+	Name      sql.NullString
+	Latitude  sql.NullFloat64
+	Longitude sql.NullFloat64
+	RadiusM   sql.NullFloat64
+	CreatedAt time.Time
+}
+
+type RollupState struct {
+	Name        sql.NullString
+	RolledUntil time.Time
+}
+
+type Webhook struct {
+	ID        int32
+	Url       sql.NullString
+	Secret    sql.NullString
+	Events    json.RawMessage
+	Active    bool
+	CreatedAt time.Time
+}
+
+type WebhookDeadLetter struct {
+	ID         int32
+	WebhookID  int32
+	DeliveryID sql.NullString
+	Event      sql.NullString
+	Payload    json.RawMessage
+	Attempts   int32
+	LastError  sql.NullString
+	CreatedAt  time.Time
+}
+
+type WebhookDelivery struct {
+	ID         int32
+	WebhookID  int32
+	DeliveryID sql.NullString
+	Event      sql.NullString
+	Attempt    int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	DurationMs int32
+	CreatedAt  time.Time
 }
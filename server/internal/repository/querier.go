@@ -6,12 +6,57 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	CountPositionsInRange(ctx context.Context, arg CountPositionsInRangeParams) (int64, error)
+	CreateGeofence(ctx context.Context, arg CreateGeofenceParams) (Geofence, error)
+	CreateMonitoringPoint(ctx context.Context, arg CreateMonitoringPointParams) (MonitoringPoint, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	DeleteGeofence(ctx context.Context, id int32) (int64, error)
+	DeleteMonitoringPoint(ctx context.Context, id int32) (int64, error)
+	DeleteWebhook(ctx context.Context, id int32) (int64, error)
 	GetAircraftData(ctx context.Context, id int32) (AircraftPosition, error)
+	GetFlowBins(ctx context.Context, arg GetFlowBinsParams) ([]GetFlowBinsRow, error)
+	GetGeofence(ctx context.Context, id int32) (Geofence, error)
 	GetHeatmapDataDynamic(ctx context.Context, arg GetHeatmapDataDynamicParams) ([]GetHeatmapDataDynamicRow, error)
+	GetHeatmapValueBins(ctx context.Context, arg GetHeatmapValueBinsParams) ([]GetHeatmapValueBinsRow, error)
+	GetMetricCells(ctx context.Context, arg GetMetricCellsParams) ([]GetMetricCellsRow, error)
+	GetMonitoringPoint(ctx context.Context, id int32) (MonitoringPoint, error)
+	GetNoiseFixes(ctx context.Context, arg GetNoiseFixesParams) ([]GetNoiseFixesRow, error)
+	GetOldestPositionDay(ctx context.Context) (time.Time, error)
+	GetPositionsInBox(ctx context.Context, arg GetPositionsInBoxParams) ([]GetPositionsInBoxRow, error)
+	GetRecentTrack(ctx context.Context, arg GetRecentTrackParams) ([]GetRecentTrackRow, error)
+	GetRollupBins(ctx context.Context, arg GetRollupBinsParams) ([]GetRollupBinsRow, error)
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	GetThinnedUntil(ctx context.Context) (time.Time, error)
+	GetTileBins(ctx context.Context, arg GetTileBinsParams) ([]GetTileBinsRow, error)
+	GetTimeseries(ctx context.Context, arg GetTimeseriesParams) ([]GetTimeseriesRow, error)
+	GetTrack(ctx context.Context, arg GetTrackParams) ([]GetTrackRow, error)
+	GetWeeklyMatrix(ctx context.Context, arg GetWeeklyMatrixParams) ([]GetWeeklyMatrixRow, error)
+	InsertAlert(ctx context.Context, arg InsertAlertParams) (int32, error)
+	InsertGeofenceEvent(ctx context.Context, arg InsertGeofenceEventParams) error
 	InsertPosition(ctx context.Context, arg InsertPositionParams) error
+	InsertWebhookDeadLetter(ctx context.Context, arg InsertWebhookDeadLetterParams) error
+	InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error
+	ListActiveWebhooks(ctx context.Context) ([]Webhook, error)
+	ListAlerts(ctx context.Context, arg ListAlertsParams) ([]Alert, error)
+	ListGeofenceEvents(ctx context.Context, arg ListGeofenceEventsParams) ([]GeofenceEvent, error)
+	ListGeofences(ctx context.Context) ([]Geofence, error)
+	ListMonitoringPoints(ctx context.Context) ([]MonitoringPoint, error)
+	ListPositionPartitions(ctx context.Context) ([]sql.NullString, error)
+	ListWebhookDeadLetters(ctx context.Context, webhookID int32) ([]WebhookDeadLetter, error)
+	ListWebhookDeliveries(ctx context.Context, webhookID int32) ([]WebhookDelivery, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	RollupDays(ctx context.Context, arg RollupDaysParams) error
+	RollupHours(ctx context.Context, arg RollupHoursParams) error
+	SetRollupWatermark(ctx context.Context, rolledUntil time.Time) error
+	SetThinnedUntil(ctx context.Context, rolledUntil time.Time) error
+	ThinPositions(ctx context.Context, arg ThinPositionsParams) (int64, error)
+	UpdateAlertTrack(ctx context.Context, arg UpdateAlertTrackParams) error
+	UpdateMonitoringPoint(ctx context.Context, arg UpdateMonitoringPointParams) (MonitoringPoint, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -8,10 +8,149 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+const countPositionsInRange = `-- name: CountPositionsInRange :one
+SELECT COUNT(*) FROM aircraft_positions
+WHERE time_position >= $1 AND time_position < $2
+`
+
+type CountPositionsInRangeParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+}
+
+func (q *Queries) CountPositionsInRange(ctx context.Context, arg CountPositionsInRangeParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPositionsInRange, arg.FromTime, arg.ToTime)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createGeofence = `-- name: CreateGeofence :one
+INSERT INTO geofences (name, geometry)
+VALUES ($1, $2)
+RETURNING id, name, geometry, created_at
+`
+
+type CreateGeofenceParams struct {
+	Name     sql.NullString
+	Geometry json.RawMessage
+}
+
+func (q *Queries) CreateGeofence(ctx context.Context, arg CreateGeofenceParams) (Geofence, error) {
+	row := q.db.QueryRowContext(ctx, createGeofence, arg.Name, arg.Geometry)
+	var i Geofence
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Geometry,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createMonitoringPoint = `-- name: CreateMonitoringPoint :one
+INSERT INTO monitoring_points (name, latitude, longitude, radius_m)
+VALUES ($1, $2, $3, $4)
+RETURNING id, name, latitude, longitude, radius_m, created_at
+`
+
+type CreateMonitoringPointParams struct {
+	Name      sql.NullString
+	Latitude  sql.NullFloat64
+	Longitude sql.NullFloat64
+	RadiusM   sql.NullFloat64
+}
+
+func (q *Queries) CreateMonitoringPoint(ctx context.Context, arg CreateMonitoringPointParams) (MonitoringPoint, error) {
+	row := q.db.QueryRowContext(ctx, createMonitoringPoint,
+		arg.Name,
+		arg.Latitude,
+		arg.Longitude,
+		arg.RadiusM,
+	)
+	var i MonitoringPoint
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Latitude,
+		&i.Longitude,
+		&i.RadiusM,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, events)
+VALUES ($1, $2, $3)
+RETURNING id, url, secret, events, active, created_at
+`
+
+type CreateWebhookParams struct {
+	Url    sql.NullString
+	Secret sql.NullString
+	Events json.RawMessage
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook, arg.Url, arg.Secret, arg.Events)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteGeofence = `-- name: DeleteGeofence :execrows
+DELETE FROM geofences WHERE id = $1
+`
+
+func (q *Queries) DeleteGeofence(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGeofence, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteMonitoringPoint = `-- name: DeleteMonitoringPoint :execrows
+DELETE FROM monitoring_points WHERE id = $1
+`
+
+func (q *Queries) DeleteMonitoringPoint(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMonitoringPoint, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAircraftData = `-- name: GetAircraftData :one
-SELECT id, icao24, callsign, origin_country, time_position, longitude, latitude, baro_altitude, on_ground, velocity, heading, vertical_rate FROM aircraft_positions WHERE id = $1
+-- the id alone can't prune partitions, each one's primary key is probed
+SELECT id, icao24, callsign, origin_country, time_position, longitude, latitude, baro_altitude, on_ground, velocity, heading, vertical_rate, category, squawk, spi FROM aircraft_positions WHERE id = $1
 `
 
 func (q *Queries) GetAircraftData(ctx context.Context, id int32) (AircraftPosition, error) {
@@ -30,36 +169,200 @@ func (q *Queries) GetAircraftData(ctx context.Context, id int32) (AircraftPositi
 		&i.Velocity,
 		&i.Heading,
 		&i.VerticalRate,
+		&i.Category,
+		&i.Squawk,
+		&i.Spi,
+	)
+	return i, err
+}
+
+const getFlowBins = `-- name: GetFlowBins :many
+SELECT
+  (floor(latitude * $1) / $1)::float8 AS lat_bin,
+  (floor(longitude * $1) / $1)::float8 AS lon_bin,
+  COUNT(*) AS count,
+  COUNT(heading) AS headings,
+  -- headings are summed as unit vectors, the mean is taken from those
+  sum(sin(radians(heading)))::float8 AS sin_sum,
+  sum(cos(radians(heading)))::float8 AS cos_sum,
+  avg(velocity)::float8 AS avg_velocity
+FROM filtered_positions(
+  $2, $3,
+  $4, $5, $6, $7,
+  $8, $9, $10, $11,
+  $12, $13, $14, $15,
+  NULL, NULL, NULL
+)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin
+`
+
+type GetFlowBinsParams struct {
+	BinSize        sql.NullFloat64
+	FromTime       time.Time
+	ToTime         time.Time
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+}
+
+type GetFlowBinsRow struct {
+	LatBin      sql.NullFloat64
+	LonBin      sql.NullFloat64
+	Count       int64
+	Headings    int64
+	SinSum      sql.NullFloat64
+	CosSum      sql.NullFloat64
+	AvgVelocity sql.NullFloat64
+}
+
+func (q *Queries) GetFlowBins(ctx context.Context, arg GetFlowBinsParams) ([]GetFlowBinsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFlowBins,
+		arg.BinSize,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
+		arg.OriginCountry,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFlowBinsRow
+	for rows.Next() {
+		var i GetFlowBinsRow
+		if err := rows.Scan(
+			&i.LatBin,
+			&i.LonBin,
+			&i.Count,
+			&i.Headings,
+			&i.SinSum,
+			&i.CosSum,
+			&i.AvgVelocity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGeofence = `-- name: GetGeofence :one
+SELECT id, name, geometry, created_at FROM geofences WHERE id = $1
+`
+
+func (q *Queries) GetGeofence(ctx context.Context, id int32) (Geofence, error) {
+	row := q.db.QueryRowContext(ctx, getGeofence, id)
+	var i Geofence
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Geometry,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
 const getHeatmapDataDynamic = `-- name: GetHeatmapDataDynamic :many
+-- filtered_positions applies the time window, bbox, filters and selectors
+-- the heatmap, tile, flow and timeseries queries share
 SELECT
-  id,
   (floor(latitude * $1) / $1)::float8 AS lat_bin,
   (floor(longitude * $1) / $1)::float8 AS lon_bin,
-  COUNT(*) AS count
-FROM aircraft_positions
-WHERE 
-  ($2::text IS NULL OR time_position > now() - ($2 || ' minutes')::interval)
-GROUP BY id, lat_bin, lon_bin
+  COUNT(*) AS count,
+  COUNT(DISTINCT icao24) AS aircraft,
+  -- bounded drill-down samples, newest fixes first
+  (array_agg(id ORDER BY time_position DESC))[1:$2::int]::int[] AS sample_ids,
+  (array_agg(DISTINCT icao24 ORDER BY icao24))[1:$2::int]::text[] AS sample_icao24s
+FROM filtered_positions(
+  $3, $4,
+  $5, $6, $7, $8,
+  $9, $10, $11, $12,
+  $13, $14, $15, $16,
+  $17, $18, $19
+)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin
 `
 
 type GetHeatmapDataDynamicParams struct {
-	BinSize  sql.NullFloat64
-	Interval sql.NullString
+	BinSize        sql.NullFloat64
+	SampleSize     int32
+	FromTime       sql.NullTime
+	ToTime         sql.NullTime
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+	Hours          sql.NullString
+	TimeZone       sql.NullString
+	Weekdays       sql.NullString
 }
 
 type GetHeatmapDataDynamicRow struct {
-	ID     int32
-	LatBin sql.NullFloat64
-	LonBin sql.NullFloat64
-	Count  int64
+	LatBin        sql.NullFloat64
+	LonBin        sql.NullFloat64
+	Count         int64
+	Aircraft      int64
+	SampleIds     []int32
+	SampleIcao24s []string
 }
 
 func (q *Queries) GetHeatmapDataDynamic(ctx context.Context, arg GetHeatmapDataDynamicParams) ([]GetHeatmapDataDynamicRow, error) {
-	rows, err := q.db.QueryContext(ctx, getHeatmapDataDynamic, arg.BinSize, arg.Interval)
+	rows, err := q.db.QueryContext(ctx, getHeatmapDataDynamic,
+		arg.BinSize,
+		arg.SampleSize,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
+		arg.OriginCountry,
+		arg.Hours,
+		arg.TimeZone,
+		arg.Weekdays,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -68,10 +371,12 @@ func (q *Queries) GetHeatmapDataDynamic(ctx context.Context, arg GetHeatmapDataD
 	for rows.Next() {
 		var i GetHeatmapDataDynamicRow
 		if err := rows.Scan(
-			&i.ID,
 			&i.LatBin,
 			&i.LonBin,
 			&i.Count,
+			&i.Aircraft,
+			pq.Array(&i.SampleIds),
+			pq.Array(&i.SampleIcao24s),
 		); err != nil {
 			return nil, err
 		}
@@ -86,45 +391,1462 @@ func (q *Queries) GetHeatmapDataDynamic(ctx context.Context, arg GetHeatmapDataD
 	return items, nil
 }
 
-const insertPosition = `-- name: InsertPosition :exec
-INSERT INTO aircraft_positions (
-    icao24, callsign, origin_country, time_position,
-    longitude, latitude, baro_altitude, on_ground,
-    velocity, heading, vertical_rate
-) VALUES (
-    $1, $2, $3, to_timestamp($4),
-    $5, $6, $7, $8,
-    $9, $10, $11
+const getHeatmapValueBins = `-- name: GetHeatmapValueBins :many
+-- counts and one metric per bin, without the drill-down samples. The other
+-- metrics' aggregates see no rows, so only the requested one does any work.
+SELECT
+  (floor(latitude * $1) / $1)::float8 AS lat_bin,
+  (floor(longitude * $1) / $1)::float8 AS lon_bin,
+  COUNT(*) AS count,
+  (CASE $2::text
+    WHEN 'aircraft' THEN COUNT(DISTINCT icao24) FILTER (WHERE $2::text = 'aircraft')
+    WHEN 'avg_altitude' THEN avg(baro_altitude) FILTER (WHERE $2::text = 'avg_altitude')
+    WHEN 'min_altitude' THEN min(baro_altitude) FILTER (WHERE $2::text = 'min_altitude')
+    WHEN 'max_altitude' THEN max(baro_altitude) FILTER (WHERE $2::text = 'max_altitude')
+    WHEN 'avg_velocity' THEN avg(velocity) FILTER (WHERE $2::text = 'avg_velocity')
+    WHEN 'median_vertical_rate' THEN percentile_cont(0.5) WITHIN GROUP (ORDER BY vertical_rate) FILTER (WHERE $2::text = 'median_vertical_rate')
+  END)::float8 AS value
+FROM filtered_positions(
+  $3, $4,
+  $5, $6, $7, $8,
+  $9, $10, $11, $12,
+  $13, $14, $15, $16,
+  $17, $18, $19
 )
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin
 `
 
-type InsertPositionParams struct {
-	Icao24        sql.NullString
-	Callsign      sql.NullString
-	OriginCountry sql.NullString
-	ToTimestamp   float64
-	Longitude     sql.NullFloat64
-	Latitude      sql.NullFloat64
-	BaroAltitude  sql.NullFloat64
-	OnGround      sql.NullBool
-	Velocity      sql.NullFloat64
-	Heading       sql.NullFloat64
-	VerticalRate  sql.NullFloat64
+type GetHeatmapValueBinsParams struct {
+	BinSize        sql.NullFloat64
+	Metric         string
+	FromTime       sql.NullTime
+	ToTime         sql.NullTime
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+	Hours          sql.NullString
+	TimeZone       sql.NullString
+	Weekdays       sql.NullString
 }
 
-func (q *Queries) InsertPosition(ctx context.Context, arg InsertPositionParams) error {
-	_, err := q.db.ExecContext(ctx, insertPosition,
-		arg.Icao24,
-		arg.Callsign,
+type GetHeatmapValueBinsRow struct {
+	LatBin sql.NullFloat64
+	LonBin sql.NullFloat64
+	Count  int64
+	Value  sql.NullFloat64
+}
+
+func (q *Queries) GetHeatmapValueBins(ctx context.Context, arg GetHeatmapValueBinsParams) ([]GetHeatmapValueBinsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getHeatmapValueBins,
+		arg.BinSize,
+		arg.Metric,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
 		arg.OriginCountry,
-		arg.ToTimestamp,
-		arg.Longitude,
-		arg.Latitude,
-		arg.BaroAltitude,
-		arg.OnGround,
-		arg.Velocity,
-		arg.Heading,
-		arg.VerticalRate,
+		arg.Hours,
+		arg.TimeZone,
+		arg.Weekdays,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetHeatmapValueBinsRow
+	for rows.Next() {
+		var i GetHeatmapValueBinsRow
+		if err := rows.Scan(
+			&i.LatBin,
+			&i.LonBin,
+			&i.Count,
+			&i.Value,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMetricCells = `-- name: GetMetricCells :many
+-- each fix projected to ETRS-TM35FIN and counted in the cell_size meter cell
+-- holding it, cells numbered from the false origin like geo.Grid
+SELECT
+  floor(p.easting / $1::float8)::int AS cell_col,
+  floor(p.northing / $1::float8)::int AS cell_row,
+  COUNT(*) AS count
+FROM filtered_positions(
+  $2, $3,
+  $4, $5, $6, $7,
+  $8, $9, $10, $11,
+  $12, $13, $14, $15,
+  $16, $17, $18
+) f
+CROSS JOIN LATERAL tm35fin(f.latitude, f.longitude) p
+WHERE f.latitude IS NOT NULL AND f.longitude IS NOT NULL
+GROUP BY cell_col, cell_row
+`
+
+type GetMetricCellsParams struct {
+	CellSize       float64
+	FromTime       sql.NullTime
+	ToTime         sql.NullTime
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+	Hours          sql.NullString
+	TimeZone       sql.NullString
+	Weekdays       sql.NullString
+}
+
+type GetMetricCellsRow struct {
+	CellCol int32
+	CellRow int32
+	Count   int64
+}
+
+func (q *Queries) GetMetricCells(ctx context.Context, arg GetMetricCellsParams) ([]GetMetricCellsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMetricCells,
+		arg.CellSize,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
+		arg.OriginCountry,
+		arg.Hours,
+		arg.TimeZone,
+		arg.Weekdays,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMetricCellsRow
+	for rows.Next() {
+		var i GetMetricCellsRow
+		if err := rows.Scan(
+			&i.CellCol,
+			&i.CellRow,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMonitoringPoint = `-- name: GetMonitoringPoint :one
+SELECT id, name, latitude, longitude, radius_m, created_at FROM monitoring_points WHERE id = $1
+`
+
+func (q *Queries) GetMonitoringPoint(ctx context.Context, id int32) (MonitoringPoint, error) {
+	row := q.db.QueryRowContext(ctx, getMonitoringPoint, id)
+	var i MonitoringPoint
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Latitude,
+		&i.Longitude,
+		&i.RadiusM,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getNoiseFixes = `-- name: GetNoiseFixes :many
+SELECT latitude, longitude, baro_altitude, category
+FROM aircraft_positions
+WHERE
+  latitude IS NOT NULL AND longitude IS NOT NULL AND baro_altitude IS NOT NULL
+  AND ($1::timestamp IS NULL OR time_position >= $1)
+  AND ($2::timestamp IS NULL OR time_position < $2)
+`
+
+type GetNoiseFixesParams struct {
+	FromTime sql.NullTime
+	ToTime   sql.NullTime
+}
+
+type GetNoiseFixesRow struct {
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+	Category     sql.NullInt32
+}
+
+func (q *Queries) GetNoiseFixes(ctx context.Context, arg GetNoiseFixesParams) ([]GetNoiseFixesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNoiseFixes, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNoiseFixesRow
+	for rows.Next() {
+		var i GetNoiseFixesRow
+		if err := rows.Scan(
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOldestPositionDay = `-- name: GetOldestPositionDay :one
+SELECT date_trunc('day', time_position)::timestamp AS oldest
+FROM aircraft_positions
+ORDER BY time_position
+LIMIT 1
+`
+
+func (q *Queries) GetOldestPositionDay(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getOldestPositionDay)
+	var oldest time.Time
+	err := row.Scan(&oldest)
+	return oldest, err
+}
+
+const getPositionsInBox = `-- name: GetPositionsInBox :many
+SELECT id, icao24, callsign, time_position, latitude, longitude, baro_altitude, category
+FROM aircraft_positions
+WHERE
+  latitude BETWEEN $1 AND $2
+  AND longitude BETWEEN $3 AND $4
+  AND ($5::timestamp IS NULL OR time_position >= $5)
+  AND ($6::timestamp IS NULL OR time_position < $6)
+ORDER BY icao24, time_position
+`
+
+type GetPositionsInBoxParams struct {
+	LatMin   sql.NullFloat64
+	LatMax   sql.NullFloat64
+	LonMin   sql.NullFloat64
+	LonMax   sql.NullFloat64
+	FromTime sql.NullTime
+	ToTime   sql.NullTime
+}
+
+type GetPositionsInBoxRow struct {
+	ID           int32
+	Icao24       sql.NullString
+	Callsign     sql.NullString
+	TimePosition time.Time
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+	Category     sql.NullInt32
+}
+
+func (q *Queries) GetPositionsInBox(ctx context.Context, arg GetPositionsInBoxParams) ([]GetPositionsInBoxRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPositionsInBox,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.FromTime,
+		arg.ToTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPositionsInBoxRow
+	for rows.Next() {
+		var i GetPositionsInBoxRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Icao24,
+			&i.Callsign,
+			&i.TimePosition,
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentTrack = `-- name: GetRecentTrack :many
+SELECT time_position, latitude, longitude, baro_altitude
+FROM aircraft_positions
+WHERE icao24 = $1 AND time_position >= $2
+ORDER BY time_position
+`
+
+type GetRecentTrackParams struct {
+	Icao24 sql.NullString
+	Since  time.Time
+}
+
+type GetRecentTrackRow struct {
+	TimePosition time.Time
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+}
+
+func (q *Queries) GetRecentTrack(ctx context.Context, arg GetRecentTrackParams) ([]GetRecentTrackRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentTrack, arg.Icao24, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRecentTrackRow
+	for rows.Next() {
+		var i GetRecentTrackRow
+		if err := rows.Scan(
+			&i.TimePosition,
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRollupBins = `-- name: GetRollupBins :many
+SELECT lat_bin, lon_bin, SUM(count)::bigint AS count
+FROM heatmap_rollups
+WHERE
+  bin_size = $1
+  AND (
+    (granularity = 'day' AND bucket >= $2 AND bucket < $3)
+    -- hours at either end that don't fill a whole day
+    OR (
+      granularity = 'hour' AND bucket >= $4 AND bucket < $5
+      AND NOT (bucket >= $2 AND bucket < $3)
+    )
+  )
+  -- the box is snapped to whole bins, the same rule as for raw fixes
+  AND ($6::float8 IS NULL OR (lat_bin >= $6 AND lat_bin < $7))
+  AND (
+    $8::float8 IS NULL
+    OR ($8::float8 <= $9::float8 AND lon_bin >= $8 AND lon_bin < $9)
+    -- the box crosses the antimeridian
+    OR ($8::float8 > $9::float8 AND (lon_bin >= $8 OR lon_bin < $9))
+  )
+GROUP BY lat_bin, lon_bin
+`
+
+type GetRollupBinsParams struct {
+	BinSize  int32
+	DayFrom  time.Time
+	DayTo    time.Time
+	FromTime time.Time
+	ToTime   time.Time
+	LatMin   sql.NullFloat64
+	LatMax   sql.NullFloat64
+	LonMin   sql.NullFloat64
+	LonMax   sql.NullFloat64
+}
+
+type GetRollupBinsRow struct {
+	LatBin sql.NullFloat64
+	LonBin sql.NullFloat64
+	Count  int64
+}
+
+func (q *Queries) GetRollupBins(ctx context.Context, arg GetRollupBinsParams) ([]GetRollupBinsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRollupBins,
+		arg.BinSize,
+		arg.DayFrom,
+		arg.DayTo,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRollupBinsRow
+	for rows.Next() {
+		var i GetRollupBinsRow
+		if err := rows.Scan(
+			&i.LatBin,
+			&i.LonBin,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRollupWatermark = `-- name: GetRollupWatermark :one
+SELECT rolled_until FROM rollup_state WHERE name = 'heatmap'
+`
+
+func (q *Queries) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getRollupWatermark)
+	var rolled_until time.Time
+	err := row.Scan(&rolled_until)
+	return rolled_until, err
+}
+
+const getThinnedUntil = `-- name: GetThinnedUntil :one
+SELECT rolled_until FROM rollup_state WHERE name = 'thinned'
+`
+
+func (q *Queries) GetThinnedUntil(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getThinnedUntil)
+	var rolled_until time.Time
+	err := row.Scan(&rolled_until)
+	return rolled_until, err
+}
+
+const getTileBins = `-- name: GetTileBins :many
+SELECT
+  (floor(latitude * $1) / $1)::float8 AS lat_bin,
+  (floor(longitude * $1) / $1)::float8 AS lon_bin,
+  COUNT(*) AS count
+FROM filtered_positions(
+  $2, $3,
+  $4, $5, $6, $7,
+  $8, $9, $10, $11,
+  $12, $13, $14, $15,
+  $16, $17, $18
+)
+GROUP BY lat_bin, lon_bin
+`
+
+type GetTileBinsParams struct {
+	BinSize        sql.NullFloat64
+	FromTime       sql.NullTime
+	ToTime         sql.NullTime
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+	Hours          sql.NullString
+	TimeZone       sql.NullString
+	Weekdays       sql.NullString
+}
+
+type GetTileBinsRow struct {
+	LatBin sql.NullFloat64
+	LonBin sql.NullFloat64
+	Count  int64
+}
+
+func (q *Queries) GetTileBins(ctx context.Context, arg GetTileBinsParams) ([]GetTileBinsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTileBins,
+		arg.BinSize,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
+		arg.OriginCountry,
+		arg.Hours,
+		arg.TimeZone,
+		arg.Weekdays,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTileBinsRow
+	for rows.Next() {
+		var i GetTileBinsRow
+		if err := rows.Scan(
+			&i.LatBin,
+			&i.LonBin,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimeseries = `-- name: GetTimeseries :many
+SELECT
+  date_bin($1::int * interval '1 second', time_position, TIMESTAMP '2000-01-01')::timestamp AS bucket,
+  COUNT(*) AS fixes,
+  COUNT(DISTINCT icao24) AS aircraft,
+  COUNT(DISTINCT callsign) AS callsigns
+FROM filtered_positions(
+  $2, $3,
+  $4, $5, $6, $7,
+  $8, $9, $10, $11,
+  $12, $13, $14, $15,
+  NULL, NULL, NULL
+)
+GROUP BY bucket
+ORDER BY bucket
+`
+
+type GetTimeseriesParams struct {
+	BucketSeconds  int32
+	FromTime       time.Time
+	ToTime         time.Time
+	LatMin         sql.NullFloat64
+	LatMax         sql.NullFloat64
+	LonMin         sql.NullFloat64
+	LonMax         sql.NullFloat64
+	AltMin         sql.NullFloat64
+	AltMax         sql.NullFloat64
+	VelocityMin    sql.NullFloat64
+	VelocityMax    sql.NullFloat64
+	VerticalSign   sql.NullInt32
+	CallsignPrefix sql.NullString
+	Icao24List     sql.NullString
+	OriginCountry  sql.NullString
+}
+
+type GetTimeseriesRow struct {
+	Bucket    time.Time
+	Fixes     int64
+	Aircraft  int64
+	Callsigns int64
+}
+
+func (q *Queries) GetTimeseries(ctx context.Context, arg GetTimeseriesParams) ([]GetTimeseriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimeseries,
+		arg.BucketSeconds,
+		arg.FromTime,
+		arg.ToTime,
+		arg.LatMin,
+		arg.LatMax,
+		arg.LonMin,
+		arg.LonMax,
+		arg.AltMin,
+		arg.AltMax,
+		arg.VelocityMin,
+		arg.VelocityMax,
+		arg.VerticalSign,
+		arg.CallsignPrefix,
+		arg.Icao24List,
+		arg.OriginCountry,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimeseriesRow
+	for rows.Next() {
+		var i GetTimeseriesRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Fixes,
+			&i.Aircraft,
+			&i.Callsigns,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrack = `-- name: GetTrack :many
+SELECT time_position, latitude, longitude, baro_altitude, velocity
+FROM aircraft_positions
+WHERE icao24 = $1
+  AND time_position >= $2
+  AND time_position < $3
+  AND latitude IS NOT NULL
+  AND longitude IS NOT NULL
+ORDER BY time_position
+`
+
+type GetTrackParams struct {
+	Icao24   sql.NullString
+	FromTime time.Time
+	ToTime   time.Time
+}
+
+type GetTrackRow struct {
+	TimePosition time.Time
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+	Velocity     sql.NullFloat64
+}
+
+func (q *Queries) GetTrack(ctx context.Context, arg GetTrackParams) ([]GetTrackRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTrack, arg.Icao24, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTrackRow
+	for rows.Next() {
+		var i GetTrackRow
+		if err := rows.Scan(
+			&i.TimePosition,
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+			&i.Velocity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWeeklyMatrix = `-- name: GetWeeklyMatrix :many
+SELECT
+  extract(isodow FROM timezone($1::text, time_position AT TIME ZONE 'UTC'))::int AS weekday,
+  extract(hour FROM timezone($1::text, time_position AT TIME ZONE 'UTC'))::int AS hour,
+  COUNT(*) AS count
+FROM aircraft_positions
+WHERE
+  ($2::timestamp IS NULL OR time_position >= $2)
+  AND ($3::timestamp IS NULL OR time_position < $3)
+GROUP BY weekday, hour
+`
+
+type GetWeeklyMatrixParams struct {
+	TimeZone sql.NullString
+	FromTime sql.NullTime
+	ToTime   sql.NullTime
+}
+
+type GetWeeklyMatrixRow struct {
+	Weekday int32
+	Hour    int32
+	Count   int64
+}
+
+func (q *Queries) GetWeeklyMatrix(ctx context.Context, arg GetWeeklyMatrixParams) ([]GetWeeklyMatrixRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWeeklyMatrix, arg.TimeZone, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWeeklyMatrixRow
+	for rows.Next() {
+		var i GetWeeklyMatrixRow
+		if err := rows.Scan(
+			&i.Weekday,
+			&i.Hour,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertAlert = `-- name: InsertAlert :one
+INSERT INTO alerts (
+    type, icao24, callsign, squawk, time_position,
+    latitude, longitude, baro_altitude, track
+) VALUES (
+    $1, $2, $3, $4, $5,
+    $6, $7, $8, $9
+)
+RETURNING id
+`
+
+type InsertAlertParams struct {
+	Type         sql.NullString
+	Icao24       sql.NullString
+	Callsign     sql.NullString
+	Squawk       sql.NullString
+	TimePosition time.Time
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	BaroAltitude sql.NullFloat64
+	Track        json.RawMessage
+}
+
+func (q *Queries) InsertAlert(ctx context.Context, arg InsertAlertParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, insertAlert,
+		arg.Type,
+		arg.Icao24,
+		arg.Callsign,
+		arg.Squawk,
+		arg.TimePosition,
+		arg.Latitude,
+		arg.Longitude,
+		arg.BaroAltitude,
+		arg.Track,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertGeofenceEvent = `-- name: InsertGeofenceEvent :exec
+INSERT INTO geofence_events (
+    geofence_id, icao24, callsign, event, time_position, baro_altitude
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type InsertGeofenceEventParams struct {
+	GeofenceID   int32
+	Icao24       sql.NullString
+	Callsign     sql.NullString
+	Event        sql.NullString
+	TimePosition time.Time
+	BaroAltitude sql.NullFloat64
+}
+
+func (q *Queries) InsertGeofenceEvent(ctx context.Context, arg InsertGeofenceEventParams) error {
+	_, err := q.db.ExecContext(ctx, insertGeofenceEvent,
+		arg.GeofenceID,
+		arg.Icao24,
+		arg.Callsign,
+		arg.Event,
+		arg.TimePosition,
+		arg.BaroAltitude,
+	)
+	return err
+}
+
+const insertPosition = `-- name: InsertPosition :exec
+INSERT INTO aircraft_positions (
+    icao24, callsign, origin_country, time_position,
+    longitude, latitude, baro_altitude, on_ground,
+    velocity, heading, vertical_rate, category,
+    squawk, spi
+) VALUES (
+    $1, $2, $3, to_timestamp($4),
+    $5, $6, $7, $8,
+    $9, $10, $11, $12,
+    $13, $14
+)
+`
+
+type InsertPositionParams struct {
+	Icao24        sql.NullString
+	Callsign      sql.NullString
+	OriginCountry sql.NullString
+	ToTimestamp   float64
+	Longitude     sql.NullFloat64
+	Latitude      sql.NullFloat64
+	BaroAltitude  sql.NullFloat64
+	OnGround      sql.NullBool
+	Velocity      sql.NullFloat64
+	Heading       sql.NullFloat64
+	VerticalRate  sql.NullFloat64
+	Category      sql.NullInt32
+	Squawk        sql.NullString
+	Spi           sql.NullBool
+}
+
+func (q *Queries) InsertPosition(ctx context.Context, arg InsertPositionParams) error {
+	_, err := q.db.ExecContext(ctx, insertPosition,
+		arg.Icao24,
+		arg.Callsign,
+		arg.OriginCountry,
+		arg.ToTimestamp,
+		arg.Longitude,
+		arg.Latitude,
+		arg.BaroAltitude,
+		arg.OnGround,
+		arg.Velocity,
+		arg.Heading,
+		arg.VerticalRate,
+		arg.Category,
+		arg.Squawk,
+		arg.Spi,
+	)
+	return err
+}
+
+const insertWebhookDeadLetter = `-- name: InsertWebhookDeadLetter :exec
+INSERT INTO webhook_dead_letters (
+    webhook_id, delivery_id, event, payload, attempts, last_error
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type InsertWebhookDeadLetterParams struct {
+	WebhookID  int32
+	DeliveryID sql.NullString
+	Event      sql.NullString
+	Payload    json.RawMessage
+	Attempts   int32
+	LastError  sql.NullString
+}
+
+func (q *Queries) InsertWebhookDeadLetter(ctx context.Context, arg InsertWebhookDeadLetterParams) error {
+	_, err := q.db.ExecContext(ctx, insertWebhookDeadLetter,
+		arg.WebhookID,
+		arg.DeliveryID,
+		arg.Event,
+		arg.Payload,
+		arg.Attempts,
+		arg.LastError,
+	)
+	return err
+}
+
+const insertWebhookDelivery = `-- name: InsertWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+    webhook_id, delivery_id, event, attempt, status_code, error, duration_ms
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+`
+
+type InsertWebhookDeliveryParams struct {
+	WebhookID  int32
+	DeliveryID sql.NullString
+	Event      sql.NullString
+	Attempt    int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	DurationMs int32
+}
+
+func (q *Queries) InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, insertWebhookDelivery,
+		arg.WebhookID,
+		arg.DeliveryID,
+		arg.Event,
+		arg.Attempt,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const listActiveWebhooks = `-- name: ListActiveWebhooks :many
+SELECT id, url, secret, events, active, created_at FROM webhooks WHERE active ORDER BY id
+`
+
+func (q *Queries) ListActiveWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAlerts = `-- name: ListAlerts :many
+SELECT id, type, icao24, callsign, squawk, time_position, latitude, longitude, baro_altitude, track, created_at FROM alerts
+WHERE
+  ($1::text IS NULL OR type = $1)
+  AND ($2::timestamp IS NULL OR time_position >= $2)
+  AND ($3::timestamp IS NULL OR time_position < $3)
+ORDER BY time_position DESC
+LIMIT 200
+`
+
+type ListAlertsParams struct {
+	Type     sql.NullString
+	FromTime sql.NullTime
+	ToTime   sql.NullTime
+}
+
+func (q *Queries) ListAlerts(ctx context.Context, arg ListAlertsParams) ([]Alert, error) {
+	rows, err := q.db.QueryContext(ctx, listAlerts, arg.Type, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Alert
+	for rows.Next() {
+		var i Alert
+		if err := rows.Scan(
+			&i.ID,
+			&i.Type,
+			&i.Icao24,
+			&i.Callsign,
+			&i.Squawk,
+			&i.TimePosition,
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+			&i.Track,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGeofenceEvents = `-- name: ListGeofenceEvents :many
+SELECT id, geofence_id, icao24, callsign, event, time_position, baro_altitude FROM geofence_events
+WHERE
+  geofence_id = $1
+  AND ($2::timestamp IS NULL OR time_position >= $2)
+  AND ($3::timestamp IS NULL OR time_position < $3)
+ORDER BY time_position DESC
+LIMIT $4
+`
+
+type ListGeofenceEventsParams struct {
+	GeofenceID int32
+	FromTime   sql.NullTime
+	ToTime     sql.NullTime
+	RowLimit   int32
+}
+
+func (q *Queries) ListGeofenceEvents(ctx context.Context, arg ListGeofenceEventsParams) ([]GeofenceEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listGeofenceEvents,
+		arg.GeofenceID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GeofenceEvent
+	for rows.Next() {
+		var i GeofenceEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.GeofenceID,
+			&i.Icao24,
+			&i.Callsign,
+			&i.Event,
+			&i.TimePosition,
+			&i.BaroAltitude,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGeofences = `-- name: ListGeofences :many
+SELECT id, name, geometry, created_at FROM geofences ORDER BY id
+`
+
+func (q *Queries) ListGeofences(ctx context.Context) ([]Geofence, error) {
+	rows, err := q.db.QueryContext(ctx, listGeofences)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Geofence
+	for rows.Next() {
+		var i Geofence
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Geometry,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMonitoringPoints = `-- name: ListMonitoringPoints :many
+SELECT id, name, latitude, longitude, radius_m, created_at FROM monitoring_points ORDER BY id
+`
+
+func (q *Queries) ListMonitoringPoints(ctx context.Context) ([]MonitoringPoint, error) {
+	rows, err := q.db.QueryContext(ctx, listMonitoringPoints)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MonitoringPoint
+	for rows.Next() {
+		var i MonitoringPoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Latitude,
+			&i.Longitude,
+			&i.RadiusM,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPositionPartitions = `-- name: ListPositionPartitions :many
+SELECT c.relname::text AS name
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'aircraft_positions'::regclass
+ORDER BY c.relname
+`
+
+func (q *Queries) ListPositionPartitions(ctx context.Context) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, listPositionPartitions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeadLetters = `-- name: ListWebhookDeadLetters :many
+SELECT id, webhook_id, delivery_id, event, payload, attempts, last_error, created_at FROM webhook_dead_letters
+WHERE webhook_id = $1
+ORDER BY id DESC
+`
+
+func (q *Queries) ListWebhookDeadLetters(ctx context.Context, webhookID int32) ([]WebhookDeadLetter, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeadLetters, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeadLetter
+	for rows.Next() {
+		var i WebhookDeadLetter
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.DeliveryID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastError,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, delivery_id, event, attempt, status_code, error, duration_ms, created_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT 100
+`
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, webhookID int32) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.DeliveryID,
+			&i.Event,
+			&i.Attempt,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, url, secret, events, active, created_at FROM webhooks ORDER BY id
+`
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rollupDays = `-- name: RollupDays :exec
+INSERT INTO heatmap_rollups (bin_size, granularity, bucket, lat_bin, lon_bin, count)
+SELECT bin_size, 'day', date_trunc('day', bucket) AS day, lat_bin, lon_bin, SUM(count)
+FROM heatmap_rollups
+WHERE granularity = 'hour' AND bucket >= $1 AND bucket < $2
+GROUP BY bin_size, day, lat_bin, lon_bin
+ON CONFLICT (bin_size, granularity, bucket, lat_bin, lon_bin) DO UPDATE SET count = EXCLUDED.count
+`
+
+type RollupDaysParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+}
+
+func (q *Queries) RollupDays(ctx context.Context, arg RollupDaysParams) error {
+	_, err := q.db.ExecContext(ctx, rollupDays, arg.FromTime, arg.ToTime)
+	return err
+}
+
+const rollupHours = `-- name: RollupHours :exec
+INSERT INTO heatmap_rollups (bin_size, granularity, bucket, lat_bin, lon_bin, count)
+SELECT
+  $1::int,
+  'hour',
+  date_trunc('hour', time_position) AS bucket,
+  -- same expressions as GetHeatmapDataDynamic so bins line up with raw data
+  floor(latitude * $1::float8) / $1::float8 AS lat_bin,
+  floor(longitude * $1::float8) / $1::float8 AS lon_bin,
+  COUNT(*)
+FROM aircraft_positions
+WHERE
+  latitude IS NOT NULL AND longitude IS NOT NULL
+  AND time_position >= $2 AND time_position < $3
+GROUP BY bucket, lat_bin, lon_bin
+ON CONFLICT (bin_size, granularity, bucket, lat_bin, lon_bin) DO UPDATE SET count = EXCLUDED.count
+`
+
+type RollupHoursParams struct {
+	BinSize  int32
+	FromTime time.Time
+	ToTime   time.Time
+}
+
+func (q *Queries) RollupHours(ctx context.Context, arg RollupHoursParams) error {
+	_, err := q.db.ExecContext(ctx, rollupHours, arg.BinSize, arg.FromTime, arg.ToTime)
+	return err
+}
+
+const setRollupWatermark = `-- name: SetRollupWatermark :exec
+INSERT INTO rollup_state (name, rolled_until) VALUES ('heatmap', $1)
+ON CONFLICT (name) DO UPDATE SET rolled_until = EXCLUDED.rolled_until
+`
+
+func (q *Queries) SetRollupWatermark(ctx context.Context, rolledUntil time.Time) error {
+	_, err := q.db.ExecContext(ctx, setRollupWatermark, rolledUntil)
+	return err
+}
+
+const setThinnedUntil = `-- name: SetThinnedUntil :exec
+INSERT INTO rollup_state (name, rolled_until) VALUES ('thinned', $1)
+ON CONFLICT (name) DO UPDATE SET rolled_until = EXCLUDED.rolled_until
+`
+
+func (q *Queries) SetThinnedUntil(ctx context.Context, rolledUntil time.Time) error {
+	_, err := q.db.ExecContext(ctx, setThinnedUntil, rolledUntil)
+	return err
+}
+
+const thinPositions = `-- name: ThinPositions :execrows
+DELETE FROM aircraft_positions
+WHERE
+  -- the time range prunes the partitions the delete touches
+  time_position >= $1 AND time_position < $2
+  AND (id, time_position) IN (
+    SELECT id, time_position FROM (
+      SELECT
+        id,
+        time_position,
+        row_number() OVER (
+          PARTITION BY icao24, date_bin($3::int * interval '1 second', time_position, TIMESTAMP '2000-01-01')
+          ORDER BY time_position, id
+        ) AS n
+      FROM aircraft_positions
+      WHERE time_position >= $1 AND time_position < $2
+    ) ranked
+    -- keep the first fix of each aircraft per interval
+    WHERE n > 1
+    LIMIT $4
+  )
+`
+
+type ThinPositionsParams struct {
+	FromTime    time.Time
+	ToTime      time.Time
+	ThinSeconds int32
+	BatchSize   int32
+}
+
+func (q *Queries) ThinPositions(ctx context.Context, arg ThinPositionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, thinPositions,
+		arg.FromTime,
+		arg.ToTime,
+		arg.ThinSeconds,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateAlertTrack = `-- name: UpdateAlertTrack :exec
+UPDATE alerts SET track = $2 WHERE id = $1
+`
+
+type UpdateAlertTrackParams struct {
+	ID    int32
+	Track json.RawMessage
+}
+
+func (q *Queries) UpdateAlertTrack(ctx context.Context, arg UpdateAlertTrackParams) error {
+	_, err := q.db.ExecContext(ctx, updateAlertTrack, arg.ID, arg.Track)
+	return err
+}
+
+const updateMonitoringPoint = `-- name: UpdateMonitoringPoint :one
+UPDATE monitoring_points
+SET name = $2, latitude = $3, longitude = $4, radius_m = $5
+WHERE id = $1
+RETURNING id, name, latitude, longitude, radius_m, created_at
+`
+
+type UpdateMonitoringPointParams struct {
+	ID        int32
+	Name      sql.NullString
+	Latitude  sql.NullFloat64
+	Longitude sql.NullFloat64
+	RadiusM   sql.NullFloat64
+}
+
+func (q *Queries) UpdateMonitoringPoint(ctx context.Context, arg UpdateMonitoringPointParams) (MonitoringPoint, error) {
+	row := q.db.QueryRowContext(ctx, updateMonitoringPoint,
+		arg.ID,
+		arg.Name,
+		arg.Latitude,
+		arg.Longitude,
+		arg.RadiusM,
+	)
+	var i MonitoringPoint
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Latitude,
+		&i.Longitude,
+		&i.RadiusM,
+		&i.CreatedAt,
+	)
+	return i, err
 }
@@ -0,0 +1,470 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geo"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// testDB migrates a throwaway schema in the database at TEST_DATABASE_URL.
+// Tests that need Postgres are skipped when it isn't set.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// search_path is per connection, so keep a single one
+	db.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	for _, stmt := range []string{
+		"CREATE SCHEMA " + schema,
+		"SET search_path TO " + schema,
+		"SET TIME ZONE 'UTC'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		db.Close()
+	})
+
+	migrations, _ := filepath.Glob("../../sql/migrations/*.sql")
+	sort.Strings(migrations)
+	for _, m := range migrations {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(m), err)
+		}
+	}
+
+	return db
+}
+
+func insertFix(t *testing.T, q *repository.Queries, icao24 string, at time.Time, lat, lon float64) {
+	t.Helper()
+
+	err := q.InsertPosition(context.Background(), repository.InsertPositionParams{
+		Icao24:      sql.NullString{String: icao24, Valid: true},
+		ToTimestamp: float64(at.Unix()),
+		Latitude:    sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude:   sql.NullFloat64{Float64: lon, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetHeatmapDataDynamicBins(t *testing.T) {
+	q := repository.New(testDB(t))
+	now := time.Now().Truncate(time.Second)
+
+	// three aircraft and five fixes inside one 1/80 degree cell, one elsewhere
+	insertFix(t, q, "aaa111", now.Add(-4*time.Minute), 60.301, 24.951)
+	insertFix(t, q, "aaa111", now.Add(-3*time.Minute), 60.302, 24.952)
+	insertFix(t, q, "bbb222", now.Add(-2*time.Minute), 60.303, 24.953)
+	insertFix(t, q, "ccc333", now.Add(-1*time.Minute), 60.304, 24.954)
+	insertFix(t, q, "ccc333", now, 60.305, 24.955)
+	insertFix(t, q, "ddd444", now, 61.5, 23.5)
+
+	rows, err := q.GetHeatmapDataDynamic(context.Background(), repository.GetHeatmapDataDynamicParams{
+		BinSize:    sql.NullFloat64{Float64: 80, Valid: true},
+		SampleSize: 2,
+		TimeZone:   sql.NullString{String: "UTC", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 bins, got %d: %+v", len(rows), rows)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Count > rows[j].Count })
+
+	busy := rows[0]
+	if busy.LatBin.Float64 != 60.3 || busy.LonBin.Float64 != 24.95 {
+		t.Errorf("unexpected bin %f %f", busy.LatBin.Float64, busy.LonBin.Float64)
+	}
+	if busy.Count != 5 || busy.Aircraft != 3 {
+		t.Errorf("expected 5 fixes from 3 aircraft, got %d from %d", busy.Count, busy.Aircraft)
+	}
+	if len(busy.SampleIds) != 2 || len(busy.SampleIcao24s) != 2 {
+		t.Errorf("expected samples bounded to 2, got %v %v", busy.SampleIds, busy.SampleIcao24s)
+	}
+	if busy.SampleIcao24s[0] != "aaa111" || busy.SampleIcao24s[1] != "bbb222" {
+		t.Errorf("unexpected icao24 sample %v", busy.SampleIcao24s)
+	}
+
+	if rows[1].Count != 1 || rows[1].Aircraft != 1 || len(rows[1].SampleIds) != 1 {
+		t.Errorf("unexpected single fix bin %+v", rows[1])
+	}
+}
+
+func TestGetHeatmapValueBins(t *testing.T) {
+	q := repository.New(testDB(t))
+	now := time.Now().Truncate(time.Second)
+
+	for i, fix := range []struct{ altitude, velocity, verticalRate float64 }{
+		{1000, 100, -5},
+		{3000, 150, 0},
+		{2000, 200, 10},
+	} {
+		err := q.InsertPosition(context.Background(), repository.InsertPositionParams{
+			Icao24:       sql.NullString{String: "aaa111", Valid: true},
+			ToTimestamp:  float64(now.Add(time.Duration(-i) * time.Minute).Unix()),
+			Latitude:     sql.NullFloat64{Float64: 60.301, Valid: true},
+			Longitude:    sql.NullFloat64{Float64: 24.951, Valid: true},
+			BaroAltitude: sql.NullFloat64{Float64: fix.altitude, Valid: true},
+			Velocity:     sql.NullFloat64{Float64: fix.velocity, Valid: true},
+			VerticalRate: sql.NullFloat64{Float64: fix.verticalRate, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a fix without altitude is counted but not averaged
+	insertFix(t, q, "bbb222", now, 60.302, 24.952)
+
+	for _, tc := range []struct {
+		metric string
+		value  sql.NullFloat64
+	}{
+		{"count", sql.NullFloat64{}},
+		{"aircraft", sql.NullFloat64{Float64: 2, Valid: true}},
+		{"avg_altitude", sql.NullFloat64{Float64: 2000, Valid: true}},
+		{"min_altitude", sql.NullFloat64{Float64: 1000, Valid: true}},
+		{"max_altitude", sql.NullFloat64{Float64: 3000, Valid: true}},
+		{"avg_velocity", sql.NullFloat64{Float64: 150, Valid: true}},
+		{"median_vertical_rate", sql.NullFloat64{Float64: 0, Valid: true}},
+	} {
+		rows, err := q.GetHeatmapValueBins(context.Background(), repository.GetHeatmapValueBinsParams{
+			BinSize:  sql.NullFloat64{Float64: 80, Valid: true},
+			Metric:   tc.metric,
+			TimeZone: sql.NullString{String: "UTC", Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 || rows[0].Count != 4 || rows[0].Value != tc.value {
+			t.Errorf("%s: unexpected bins %+v", tc.metric, rows)
+		}
+	}
+}
+
+func TestGetMetricCellsProjectsEachFix(t *testing.T) {
+	q := repository.New(testDB(t))
+	now := time.Now().Truncate(time.Second)
+
+	// fixes a few meters apart straddle cell edges, the others are far apart
+	fixes := [][2]float64{
+		{60.3172, 24.9633}, {60.3173, 24.9634}, {60.3171, 24.9632},
+		{60.1699, 24.9384}, {61.4978, 23.7610}, {65.0121, 25.4651}, {69.9, 27.0},
+	}
+	grid := geo.Grid{Projection: geo.TM35FIN, Size: 100}
+	want := map[[2]int]int64{}
+	for i, fix := range fixes {
+		insertFix(t, q, "aaa111", now.Add(time.Duration(-i)*time.Minute), fix[0], fix[1])
+		col, row := grid.CellAt(fix[0], fix[1])
+		want[[2]int{col, row}]++
+	}
+
+	rows, err := q.GetMetricCells(context.Background(), repository.GetMetricCellsParams{
+		CellSize: grid.Size,
+		TimeZone: sql.NullString{String: "UTC", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[[2]int]int64{}
+	for _, row := range rows {
+		got[[2]int{int(row.CellCol), int(row.CellRow)}] = row.Count
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected the cells geo.Grid puts the fixes in, %v, got %v", want, got)
+	}
+}
+
+func TestFilteredQueriesAgreeOnTheBox(t *testing.T) {
+	ctx := context.Background()
+	q := repository.New(testDB(t))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// the fix on the upper edge belongs to the box above
+	insertFix(t, q, "aaa111", now.Add(-2*time.Minute), 60.25, 24.75)
+	insertFix(t, q, "bbb222", now.Add(-time.Minute), 60.5, 24.75)
+
+	box := func(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+	from, to := now.Add(-time.Hour), now.Add(time.Minute)
+
+	heatmap, err := q.GetHeatmapDataDynamic(ctx, repository.GetHeatmapDataDynamicParams{
+		BinSize:    box(10),
+		SampleSize: 1,
+		LatMin:     box(60),
+		LatMax:     box(60.5),
+		LonMin:     box(24.5),
+		LonMax:     box(25),
+		TimeZone:   sql.NullString{String: "UTC", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, err := q.GetTileBins(ctx, repository.GetTileBinsParams{
+		BinSize:  box(10),
+		LatMin:   box(60),
+		LatMax:   box(60.5),
+		LonMin:   box(24.5),
+		LonMax:   box(25),
+		TimeZone: sql.NullString{String: "UTC", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flows, err := q.GetFlowBins(ctx, repository.GetFlowBinsParams{
+		BinSize:  box(10),
+		FromTime: from,
+		ToTime:   to,
+		LatMin:   box(60),
+		LatMax:   box(60.5),
+		LonMin:   box(24.5),
+		LonMax:   box(25),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := q.GetTimeseries(ctx, repository.GetTimeseriesParams{
+		BucketSeconds: 3600 * 24,
+		FromTime:      from,
+		ToTime:        to,
+		LatMin:        box(60),
+		LatMax:        box(60.5),
+		LonMin:        box(24.5),
+		LonMax:        box(25),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixes int64
+	for _, bucket := range series {
+		fixes += bucket.Fixes
+	}
+	if len(heatmap) != 1 || heatmap[0].Count != 1 || len(tiles) != 1 || tiles[0].Count != 1 || len(flows) != 1 || flows[0].Count != 1 || fixes != 1 {
+		t.Errorf("expected one fix in the box everywhere, got %+v, %+v, %+v and %+v", heatmap, tiles, flows, series)
+	}
+}
+
+func TestGetFlowBinsSumsHeadings(t *testing.T) {
+	q := repository.New(testDB(t))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, heading := range []float64{350, 10} {
+		err := q.InsertPosition(context.Background(), repository.InsertPositionParams{
+			Icao24:      sql.NullString{String: "aaa111", Valid: true},
+			ToTimestamp: float64(now.Add(time.Duration(-i) * time.Minute).Unix()),
+			Latitude:    sql.NullFloat64{Float64: 60.301, Valid: true},
+			Longitude:   sql.NullFloat64{Float64: 24.951, Valid: true},
+			Heading:     sql.NullFloat64{Float64: heading, Valid: true},
+			Velocity:    sql.NullFloat64{Float64: 100, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// counted, but without a heading to sum
+	insertFix(t, q, "bbb222", now, 60.302, 24.952)
+
+	rows, err := q.GetFlowBins(context.Background(), repository.GetFlowBinsParams{
+		BinSize:  sql.NullFloat64{Float64: 80, Valid: true},
+		FromTime: now.Add(-time.Hour),
+		ToTime:   now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].Count != 3 || rows[0].Headings != 2 {
+		t.Fatalf("unexpected bins %+v", rows)
+	}
+	// the sines of 350 and 10 cancel out
+	if math.Abs(rows[0].SinSum.Float64) > 1e-9 || math.Abs(rows[0].CosSum.Float64-2*math.Cos(10*math.Pi/180)) > 1e-9 {
+		t.Errorf("unexpected sums %+v", rows[0])
+	}
+	if rows[0].AvgVelocity.Float64 != 100 {
+		t.Errorf("unexpected velocity %+v", rows[0])
+	}
+}
+
+func TestRollupsMatchRawBins(t *testing.T) {
+	ctx := context.Background()
+	q := repository.New(testDB(t))
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+
+	insertFix(t, q, "aaa111", day.Add(1*time.Hour), 60.301, 24.951)
+	insertFix(t, q, "bbb222", day.Add(1*time.Hour+time.Minute), 60.302, 24.952)
+	insertFix(t, q, "aaa111", day.Add(5*time.Hour), 60.303, 24.953)
+	insertFix(t, q, "ccc333", day.Add(26*time.Hour), 60.304, 24.954)
+
+	for _, bin := range []int32{40, 80, 160} {
+		err := q.RollupHours(ctx, repository.RollupHoursParams{
+			BinSize:  bin,
+			FromTime: day,
+			ToTime:   day.Add(48 * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.RollupDays(ctx, repository.RollupDaysParams{FromTime: day, ToTime: day.Add(48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first day from the day rollup, the hours of the second from hour rollups
+	rows, err := q.GetRollupBins(ctx, repository.GetRollupBinsParams{
+		BinSize:  80,
+		DayFrom:  day,
+		DayTo:    day.Add(24 * time.Hour),
+		FromTime: day,
+		ToTime:   day.Add(48 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := q.GetHeatmapDataDynamic(ctx, repository.GetHeatmapDataDynamicParams{
+		BinSize:    sql.NullFloat64{Float64: 80, Valid: true},
+		SampleSize: 1,
+		TimeZone:   sql.NullString{String: "UTC", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || len(raw) != 1 {
+		t.Fatalf("expected a single bin, got %+v and %+v", rows, raw)
+	}
+	if rows[0].LatBin != raw[0].LatBin || rows[0].LonBin != raw[0].LonBin || rows[0].Count != 4 || raw[0].Count != 4 {
+		t.Errorf("rollup %+v does not match raw %+v", rows[0], raw[0])
+	}
+
+	// both keep a bin on a box's lower edge and drop one on its upper edge
+	for _, tc := range []struct {
+		latMin, latMax float64
+		want           int
+	}{
+		{60.3, 60.3125, 1},
+		{60.2875, 60.3, 0},
+	} {
+		box := func(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+		rows, err := q.GetRollupBins(ctx, repository.GetRollupBinsParams{
+			BinSize:  80,
+			DayFrom:  day,
+			DayTo:    day.Add(24 * time.Hour),
+			FromTime: day,
+			ToTime:   day.Add(48 * time.Hour),
+			LatMin:   box(tc.latMin),
+			LatMax:   box(tc.latMax),
+			LonMin:   box(24.95),
+			LonMax:   box(24.9625),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := q.GetHeatmapDataDynamic(ctx, repository.GetHeatmapDataDynamicParams{
+			BinSize:    sql.NullFloat64{Float64: 80, Valid: true},
+			SampleSize: 1,
+			LatMin:     box(tc.latMin),
+			LatMax:     box(tc.latMax),
+			LonMin:     box(24.95),
+			LonMax:     box(24.9625),
+			TimeZone:   sql.NullString{String: "UTC", Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != tc.want || len(raw) != tc.want {
+			t.Errorf("box %v-%v: expected %d bins, got %+v and %+v", tc.latMin, tc.latMax, tc.want, rows, raw)
+		}
+	}
+}
+
+func TestGetTrackWindowIsHalfOpen(t *testing.T) {
+	q := repository.New(testDB(t))
+	to := time.Now().UTC().Truncate(time.Minute)
+
+	insertFix(t, q, "aaa111", to.Add(-time.Hour), 60.30, 24.90)
+	insertFix(t, q, "aaa111", to.Add(-time.Minute), 60.31, 24.91)
+	insertFix(t, q, "aaa111", to, 60.32, 24.92)
+
+	rows, err := q.GetTrack(context.Background(), repository.GetTrackParams{
+		Icao24:   sql.NullString{String: "aaa111", Valid: true},
+		FromTime: to.Add(-time.Hour),
+		ToTime:   to,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fix at to belongs to the next window, like in the other queries
+	if len(rows) != 2 || !rows[1].TimePosition.Equal(to.Add(-time.Minute)) {
+		t.Errorf("expected the fixes from from up to but not at to, got %+v", rows)
+	}
+}
+
+func TestThinPositionsKeepsOneFixPerInterval(t *testing.T) {
+	ctx := context.Background()
+	q := repository.New(testDB(t))
+	start := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	// fixes every 20 seconds for two aircraft over three minutes
+	for i := 0; i < 9; i++ {
+		at := start.Add(time.Duration(i) * 20 * time.Second)
+		insertFix(t, q, "aaa111", at, 60.3, 24.9)
+		insertFix(t, q, "bbb222", at, 60.4, 25.0)
+	}
+
+	var total int64
+	for {
+		n, err := q.ThinPositions(ctx, repository.ThinPositionsParams{
+			ThinSeconds: 60,
+			FromTime:    start,
+			ToTime:      start.Add(time.Hour),
+			BatchSize:   5,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += n
+		if n < 5 {
+			break
+		}
+	}
+
+	if total != 12 {
+		t.Errorf("expected 12 fixes thinned, got %d", total)
+	}
+}
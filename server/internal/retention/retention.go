@@ -0,0 +1,182 @@
+// Package retention thins old raw fixes once the rollups hold them. Expired
+// fixes go with their daily partitions, see package partition.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+const (
+	// BatchSize bounds the rows a single statement deletes, so each one only
+	// holds its row locks briefly
+	BatchSize = 5000
+	// slice is how much time a thinning pass covers
+	slice = time.Hour
+)
+
+// metrics are published under "retention" on /debug/vars
+var metrics = expvar.NewMap("retention")
+
+type Querier interface {
+	GetOldestPositionDay(ctx context.Context) (time.Time, error)
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	GetThinnedUntil(ctx context.Context) (time.Time, error)
+	SetThinnedUntil(ctx context.Context, rolledUntil time.Time) error
+	ThinPositions(ctx context.Context, arg repository.ThinPositionsParams) (int64, error)
+}
+
+// Policy keeps every fix for Raw, then one fix per aircraft per ThinInterval
+// until Thinned has passed, after which the partition manager drops them and
+// only the rollups remain. Nothing the rollups don't cover yet is touched.
+type Policy struct {
+	Raw          time.Duration
+	ThinInterval time.Duration
+	Thinned      time.Duration
+}
+
+type Job struct {
+	Queries Querier
+	Policy  Policy
+	Now     func() time.Time
+	// Pause is slept between batches to leave room for ingestion
+	Pause time.Duration
+}
+
+func NewJob(queries Querier, policy Policy) *Job {
+	return &Job{
+		Queries: queries,
+		Policy:  policy,
+		Now:     time.Now,
+		Pause:   100 * time.Millisecond,
+	}
+}
+
+// Start enforces the policy every interval until ctx is cancelled
+func (j *Job) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := j.Run(ctx); err != nil {
+					metrics.Add("errors", 1)
+					log.Printf("retention failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run thins the fixes that have left the raw window. Fixes past the thinned
+// window are left to the partition manager.
+func (j *Job) Run(ctx context.Context) error {
+	metrics.Add("runs", 1)
+
+	rolledUntil, err := j.Queries.GetRollupWatermark(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		// nothing is rolled up yet, so nothing may go
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := j.Now().UTC()
+	thinBefore := earliest(now.Add(-j.Policy.Raw), rolledUntil)
+	expireBefore := earliest(now.Add(-j.Policy.Thinned), rolledUntil)
+
+	thinned, err := j.thin(ctx, expireBefore, thinBefore)
+	if err != nil {
+		return err
+	}
+
+	if thinned > 0 {
+		log.Printf("retention thinned %d fixes before %s", thinned, thinBefore.Format(time.RFC3339))
+	}
+	lastRun := new(expvar.String)
+	lastRun.Set(now.Format(time.RFC3339))
+	metrics.Set("last_run", lastRun)
+
+	return nil
+}
+
+// thin walks from where the previous run stopped up to before, an hour at a
+// time, so no pass rescans fixes that were already thinned. Fixes before
+// after are about to expire with their partition and are skipped.
+func (j *Job) thin(ctx context.Context, after, before time.Time) (int64, error) {
+	from, err := j.Queries.GetThinnedUntil(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		from, err = j.Queries.GetOldestPositionDay(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if from.Before(after) {
+		from = after
+	}
+
+	var total int64
+	for from.Before(before) {
+		to := earliest(from.Add(slice), before)
+
+		n, err := j.batches(ctx, func() (int64, error) {
+			return j.Queries.ThinPositions(ctx, repository.ThinPositionsParams{
+				FromTime:    from,
+				ToTime:      to,
+				ThinSeconds: max(int32(j.Policy.ThinInterval/time.Second), 1),
+				BatchSize:   BatchSize,
+			})
+		})
+		total += n
+		metrics.Add("thinned_rows", n)
+		if err != nil {
+			return total, err
+		}
+
+		if err := j.Queries.SetThinnedUntil(ctx, to); err != nil {
+			return total, err
+		}
+		from = to
+	}
+
+	return total, nil
+}
+
+// batches repeats a batched delete until it comes back short
+func (j *Job) batches(ctx context.Context, batch func() (int64, error)) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := batch()
+		total += n
+		if err != nil || n < BatchSize {
+			return total, err
+		}
+
+		time.Sleep(j.Pause)
+	}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
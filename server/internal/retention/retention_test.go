@@ -0,0 +1,123 @@
+package retention_test
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/retention"
+)
+
+type mockQueries struct {
+	oldest       time.Time
+	rolledUntil  time.Time
+	thinnedUntil time.Time
+	thin         []repository.ThinPositionsParams
+}
+
+func (m *mockQueries) GetOldestPositionDay(ctx context.Context) (time.Time, error) {
+	if m.oldest.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.oldest, nil
+}
+
+func (m *mockQueries) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	if m.rolledUntil.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.rolledUntil, nil
+}
+
+func (m *mockQueries) GetThinnedUntil(ctx context.Context) (time.Time, error) {
+	if m.thinnedUntil.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.thinnedUntil, nil
+}
+
+func (m *mockQueries) SetThinnedUntil(ctx context.Context, rolledUntil time.Time) error {
+	m.thinnedUntil = rolledUntil
+	return nil
+}
+
+func (m *mockQueries) ThinPositions(ctx context.Context, arg repository.ThinPositionsParams) (int64, error) {
+	m.thin = append(m.thin, arg)
+	return 2, nil
+}
+
+var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func newJob(mock *mockQueries) *retention.Job {
+	job := retention.NewJob(mock, retention.Policy{
+		Raw:          48 * time.Hour,
+		ThinInterval: time.Minute,
+		Thinned:      96 * time.Hour,
+	})
+	job.Now = func() time.Time { return now }
+	job.Pause = 0
+	return job
+}
+
+func TestRunThins(t *testing.T) {
+	mock := &mockQueries{
+		oldest:      now.Add(-30 * 24 * time.Hour).Truncate(24 * time.Hour),
+		rolledUntil: now.Add(-time.Hour),
+	}
+
+	thinnedBefore := counter("thinned_rows")
+	if err := newJob(mock).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// thinning starts where partitions expire and walks an hour at a time
+	if len(mock.thin) != 48 {
+		t.Fatalf("expected 48 hourly thinning passes, got %d", len(mock.thin))
+	}
+	first := mock.thin[0]
+	if !first.FromTime.Equal(now.Add(-96*time.Hour)) || first.ThinSeconds != 60 || first.BatchSize != retention.BatchSize {
+		t.Errorf("unexpected first pass %+v", first)
+	}
+	if !mock.thinnedUntil.Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("unexpected thinned watermark %v", mock.thinnedUntil)
+	}
+
+	if counter("thinned_rows")-thinnedBefore != 96 {
+		t.Errorf("unexpected metrics %s", expvar.Get("retention"))
+	}
+}
+
+func counter(name string) int64 {
+	if v, ok := expvar.Get("retention").(*expvar.Map).Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestRunWaitsForRollups(t *testing.T) {
+	// raw fixes the rollups don't cover yet are kept whatever their age
+	rolledUntil := now.Add(-60 * time.Hour)
+	mock := &mockQueries{
+		rolledUntil:  rolledUntil,
+		thinnedUntil: rolledUntil.Add(-2 * time.Hour),
+	}
+
+	if err := newJob(mock).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mock.thin) != 2 || !mock.thin[1].ToTime.Equal(rolledUntil) {
+		t.Errorf("expected thinning to stop at the rollups, got %+v", mock.thin)
+	}
+
+	mock = &mockQueries{oldest: now.Add(-30 * 24 * time.Hour)}
+	if err := newJob(mock).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.thin) != 0 {
+		t.Errorf("expected nothing to go without rollups")
+	}
+}
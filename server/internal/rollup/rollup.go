@@ -0,0 +1,145 @@
+// Package rollup pre-aggregates heatmap bins per hour and per day so the
+// heatmap doesn't have to bin every raw fix on each request
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// BinSizes are the bins the web client asks for at its zoom levels
+var BinSizes = []int{40, 80, 160}
+
+const (
+	// Lag keeps an hour out of the rollups until most late fixes for it have
+	// arrived
+	Lag = 5 * time.Minute
+	// Reroll is how far before the watermark each run rolls up again, so that
+	// fixes arriving after their hour was rolled up are still counted
+	Reroll = 2 * time.Hour
+	// chunk bounds how much raw data a single pass bins at once
+	chunk = 24 * time.Hour
+	day   = 24 * time.Hour
+)
+
+type Querier interface {
+	GetOldestPositionDay(ctx context.Context) (time.Time, error)
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	SetRollupWatermark(ctx context.Context, rolledUntil time.Time) error
+	RollupHours(ctx context.Context, arg repository.RollupHoursParams) error
+	RollupDays(ctx context.Context, arg repository.RollupDaysParams) error
+}
+
+// Job rolls up every complete hour after the watermark, and the days those
+// hours complete. It runs after each poll but never blocks ingestion.
+type Job struct {
+	Queries Querier
+	Now     func() time.Time
+
+	trigger chan struct{}
+}
+
+func NewJob(queries Querier) *Job {
+	return &Job{
+		Queries: queries,
+		Now:     time.Now,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// Start runs the job whenever a poll has finished until ctx is cancelled
+func (j *Job) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-j.trigger:
+				if err := j.Run(ctx); err != nil {
+					log.Printf("rollup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// AfterPoll implements opensky.PollHook
+func (j *Job) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+		// a run is already pending
+	}
+}
+
+// Run catches the rollups up to the last complete hour, starting Reroll
+// before the watermark. Every step is an upsert and the watermark only moves
+// once a chunk is done, so repeated hours and failed runs are simply rolled
+// up again.
+func (j *Job) Run(ctx context.Context) error {
+	until := j.Now().UTC().Add(-Lag).Truncate(time.Hour)
+
+	from, err := j.Queries.GetRollupWatermark(ctx)
+	if err == nil {
+		from = from.Add(-Reroll)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// first run, start from the day of the oldest fix so days are whole
+		from, err = j.Queries.GetOldestPositionDay(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for from.Before(until) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		to := from.Add(chunk)
+		if to.After(until) {
+			to = until
+		}
+		if err := j.rollup(ctx, from, to); err != nil {
+			return err
+		}
+		from = to
+	}
+
+	return nil
+}
+
+func (j *Job) rollup(ctx context.Context, from, to time.Time) error {
+	for _, bin := range BinSizes {
+		err := j.Queries.RollupHours(ctx, repository.RollupHoursParams{
+			BinSize:  int32(bin),
+			FromTime: from,
+			ToTime:   to,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// days whose last hour is now rolled up
+	dayFrom, dayTo := from.Truncate(day), to.Truncate(day)
+	if dayFrom.Before(dayTo) {
+		err := j.Queries.RollupDays(ctx, repository.RollupDaysParams{
+			FromTime: dayFrom,
+			ToTime:   dayTo,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return j.Queries.SetRollupWatermark(ctx, to)
+}
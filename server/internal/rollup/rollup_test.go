@@ -0,0 +1,142 @@
+package rollup_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/rollup"
+)
+
+type mockQueries struct {
+	oldest    time.Time
+	watermark time.Time
+	hours     []repository.RollupHoursParams
+	days      []repository.RollupDaysParams
+}
+
+func (m *mockQueries) GetOldestPositionDay(ctx context.Context) (time.Time, error) {
+	if m.oldest.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.oldest, nil
+}
+
+func (m *mockQueries) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	if m.watermark.IsZero() {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return m.watermark, nil
+}
+
+func (m *mockQueries) SetRollupWatermark(ctx context.Context, rolledUntil time.Time) error {
+	m.watermark = rolledUntil
+	return nil
+}
+
+func (m *mockQueries) RollupHours(ctx context.Context, arg repository.RollupHoursParams) error {
+	m.hours = append(m.hours, arg)
+	return nil
+}
+
+func (m *mockQueries) RollupDays(ctx context.Context, arg repository.RollupDaysParams) error {
+	m.days = append(m.days, arg)
+	return nil
+}
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestRunBackfillsFromOldestDay(t *testing.T) {
+	mock := &mockQueries{oldest: at(17, 0, 0)}
+	job := rollup.NewJob(mock)
+	job.Now = func() time.Time { return at(19, 10, 3) }
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 09:00 is the last complete hour once the lag has passed
+	if !mock.watermark.Equal(at(19, 9, 0)) {
+		t.Errorf("unexpected watermark %v", mock.watermark)
+	}
+
+	// three chunks, each binned at every size
+	if len(mock.hours) != 3*len(rollup.BinSizes) {
+		t.Fatalf("expected %d hour rollups, got %d", 3*len(rollup.BinSizes), len(mock.hours))
+	}
+	last := mock.hours[len(mock.hours)-1]
+	if !last.FromTime.Equal(at(19, 0, 0)) || !last.ToTime.Equal(at(19, 9, 0)) || last.BinSize != 160 {
+		t.Errorf("unexpected last hour rollup %+v", last)
+	}
+
+	if len(mock.days) != 2 || !mock.days[0].FromTime.Equal(at(17, 0, 0)) || !mock.days[1].ToTime.Equal(at(19, 0, 0)) {
+		t.Errorf("unexpected day rollups %+v", mock.days)
+	}
+}
+
+func TestRunIsIncremental(t *testing.T) {
+	mock := &mockQueries{oldest: at(17, 0, 0), watermark: at(19, 9, 0)}
+	job := rollup.NewJob(mock)
+
+	// the current hour is still open, only the hours before the watermark
+	// are rolled up again for late fixes
+	job.Now = func() time.Time { return at(19, 10, 3) }
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.hours) != len(rollup.BinSizes) || !mock.hours[0].FromTime.Equal(at(19, 7, 0)) || !mock.hours[0].ToTime.Equal(at(19, 9, 0)) {
+		t.Fatalf("expected the last two hours to be rolled up again, got %+v", mock.hours)
+	}
+	if len(mock.days) != 0 || !mock.watermark.Equal(at(19, 9, 0)) {
+		t.Fatalf("expected the watermark to stay, got %v and days %+v", mock.watermark, mock.days)
+	}
+
+	mock.hours = nil
+	job.Now = func() time.Time { return at(20, 0, 6) }
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.hours) != len(rollup.BinSizes) || !mock.hours[0].FromTime.Equal(at(19, 7, 0)) {
+		t.Errorf("unexpected hour rollups %+v", mock.hours)
+	}
+	if len(mock.days) != 1 || !mock.days[0].FromTime.Equal(at(19, 0, 0)) || !mock.days[0].ToTime.Equal(at(20, 0, 0)) {
+		t.Errorf("expected the finished day to be rolled up, got %+v", mock.days)
+	}
+	if !mock.watermark.Equal(at(20, 0, 0)) {
+		t.Errorf("unexpected watermark %v", mock.watermark)
+	}
+}
+
+func TestRunRerollsTheDayBeforeMidnight(t *testing.T) {
+	mock := &mockQueries{oldest: at(17, 0, 0), watermark: at(20, 0, 0)}
+	job := rollup.NewJob(mock)
+	job.Now = func() time.Time { return at(20, 0, 10) }
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// late fixes from the last hours of the 19th change its day rollup too
+	if len(mock.hours) != len(rollup.BinSizes) || !mock.hours[0].FromTime.Equal(at(19, 22, 0)) {
+		t.Errorf("unexpected hour rollups %+v", mock.hours)
+	}
+	if len(mock.days) != 1 || !mock.days[0].FromTime.Equal(at(19, 0, 0)) || !mock.days[0].ToTime.Equal(at(20, 0, 0)) {
+		t.Errorf("expected the 19th to be rolled up again, got %+v", mock.days)
+	}
+}
+
+func TestRunWithoutData(t *testing.T) {
+	mock := &mockQueries{}
+	job := rollup.NewJob(mock)
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.hours) != 0 || !mock.watermark.IsZero() {
+		t.Errorf("expected no work without positions")
+	}
+}
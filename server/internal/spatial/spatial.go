@@ -0,0 +1,242 @@
+// Package spatial finds stored fixes within a radius, a polygon or a bounding
+// box. With PostGIS the database does the geometry on an indexed geography
+// column, otherwise the lat/lon B-tree narrows the candidates and the exact
+// test is done in Go.
+//
+// Only the lookups that return fixes go through here: /api/positions and
+// the overflights of a monitoring point. The heatmap, tile, raster and flow
+// endpoints aggregate in SQL through filtered_positions, whose bbox is a
+// lat/lon range on the B-tree whether or not PostGIS is set up.
+package spatial
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// Position is a stored fix, in the same shape as GetPositionsInBox returns
+type Position = repository.GetPositionsInBoxRow
+
+type Repository interface {
+	// Near returns fixes within radiusM meters of lat/lon
+	Near(ctx context.Context, lat, lon, radiusM float64, from, to sql.NullTime) ([]Position, error)
+	// InPolygon returns fixes inside the polygon and outside its holes
+	InPolygon(ctx context.Context, polygon geojson.Polygon, from, to sql.NullTime) ([]Position, error)
+	// InBBox returns fixes inside the box, which may cross the antimeridian
+	InBBox(ctx context.Context, box opensky.BoundingBox, from, to sql.NullTime) ([]Position, error)
+}
+
+// New returns the PostGIS repository when postgis is set, else the plain one
+func New(db *sql.DB, postgis bool) Repository {
+	if postgis {
+		return &PostGIS{DB: db}
+	}
+	return &Plain{Queries: repository.New(db)}
+}
+
+// Available reports whether the PostGIS extension and the geog column exist.
+// They are added once by scripts/enable-postgis.sh rather than at startup,
+// as rewriting aircraft_positions for the column takes a while.
+func Available(ctx context.Context, db *sql.DB) (bool, error) {
+	var ok bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis')
+			AND EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'aircraft_positions' AND column_name = 'geog'
+			)`).Scan(&ok)
+
+	return ok, err
+}
+
+const positionColumns = `id, icao24, callsign, time_position, latitude, longitude, baro_altitude, category`
+
+// timeFilter bounds a query to from and to, given as parameters n and n+1,
+// the same way the generated queries do so partitions are still pruned
+func timeFilter(n int) string {
+	return fmt.Sprintf(`
+		AND ($%d::timestamp IS NULL OR time_position >= $%[1]d)
+		AND ($%d::timestamp IS NULL OR time_position < $%[2]d)
+		ORDER BY icao24, time_position`, n, n+1)
+}
+
+// PostGIS queries the geog column added by scripts/enable-postgis.sh
+type PostGIS struct {
+	DB *sql.DB
+}
+
+func (p *PostGIS) Near(ctx context.Context, lat, lon, radiusM float64, from, to sql.NullTime) ([]Position, error) {
+	return p.query(ctx, `
+		SELECT `+positionColumns+`
+		FROM aircraft_positions
+		WHERE ST_DWithin(geog, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)`+timeFilter(4),
+		lon, lat, radiusM, from, to)
+}
+
+// InPolygon treats polygon edges as geodesics, which for the small areas
+// used here is indistinguishable from the planar test of the fallback
+func (p *PostGIS) InPolygon(ctx context.Context, polygon geojson.Polygon, from, to sql.NullTime) ([]Position, error) {
+	geometry, err := json.Marshal(geojson.Geometry{Type: "Polygon", Coordinates: polygon})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.query(ctx, `
+		SELECT `+positionColumns+`
+		FROM aircraft_positions
+		WHERE ST_Intersects(geog, ST_SetSRID(ST_GeomFromGeoJSON($1), 4326)::geography)`+timeFilter(2),
+		string(geometry), from, to)
+}
+
+func (p *PostGIS) InBBox(ctx context.Context, box opensky.BoundingBox, from, to sql.NullTime) ([]Position, error) {
+	var positions []Position
+	for _, b := range split(box) {
+		found, err := p.query(ctx, `
+			SELECT `+positionColumns+`
+			FROM aircraft_positions
+			WHERE ST_Intersects(geog::geometry, ST_MakeEnvelope($1, $2, $3, $4, 4326))`+timeFilter(5),
+			b.LonMin, b.LatMin, b.LonMax, b.LatMax, from, to)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, found...)
+	}
+
+	return sorted(positions), nil
+}
+
+func (p *PostGIS) query(ctx context.Context, query string, args ...any) ([]Position, error) {
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []Position
+	for rows.Next() {
+		var i Position
+		if err := rows.Scan(
+			&i.ID,
+			&i.Icao24,
+			&i.Callsign,
+			&i.TimePosition,
+			&i.Latitude,
+			&i.Longitude,
+			&i.BaroAltitude,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		positions = append(positions, i)
+	}
+
+	return positions, rows.Err()
+}
+
+type Querier interface {
+	GetPositionsInBox(ctx context.Context, arg repository.GetPositionsInBoxParams) ([]repository.GetPositionsInBoxRow, error)
+}
+
+// Plain narrows candidates with GetPositionsInBox and tests them exactly
+type Plain struct {
+	Queries Querier
+}
+
+func (p *Plain) Near(ctx context.Context, lat, lon, radiusM float64, from, to sql.NullTime) ([]Position, error) {
+	candidates, err := p.InBBox(ctx, opensky.GetBoundingBox(lat, lon, radiusM/1000), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter(candidates, func(pos Position) bool {
+		return opensky.Haversine(lat, lon, pos.Latitude.Float64, pos.Longitude.Float64)*1000 <= radiusM
+	}), nil
+}
+
+func (p *Plain) InPolygon(ctx context.Context, polygon geojson.Polygon, from, to sql.NullTime) ([]Position, error) {
+	candidates, err := p.InBBox(ctx, polygonBounds(polygon), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter(candidates, func(pos Position) bool {
+		return opensky.PointInPolygon(pos.Latitude.Float64, pos.Longitude.Float64, polygon)
+	}), nil
+}
+
+func (p *Plain) InBBox(ctx context.Context, box opensky.BoundingBox, from, to sql.NullTime) ([]Position, error) {
+	var positions []Position
+	for _, b := range split(box) {
+		found, err := p.Queries.GetPositionsInBox(ctx, repository.GetPositionsInBoxParams{
+			LatMin:   sql.NullFloat64{Float64: b.LatMin, Valid: true},
+			LatMax:   sql.NullFloat64{Float64: b.LatMax, Valid: true},
+			LonMin:   sql.NullFloat64{Float64: b.LonMin, Valid: true},
+			LonMax:   sql.NullFloat64{Float64: b.LonMax, Valid: true},
+			FromTime: from,
+			ToTime:   to,
+		})
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, found...)
+	}
+
+	return sorted(positions), nil
+}
+
+// split turns a box crossing the antimeridian into one box on either side
+func split(box opensky.BoundingBox) []opensky.BoundingBox {
+	if box.LonMin <= box.LonMax {
+		return []opensky.BoundingBox{box}
+	}
+
+	west, east := box, box
+	west.LonMax = 180
+	east.LonMin = -180
+	return []opensky.BoundingBox{west, east}
+}
+
+func polygonBounds(polygon geojson.Polygon) opensky.BoundingBox {
+	box := opensky.BoundingBox{LatMin: math.Inf(1), LonMin: math.Inf(1), LatMax: math.Inf(-1), LonMax: math.Inf(-1)}
+	for _, ring := range polygon {
+		for _, p := range ring {
+			box.LonMin = math.Min(box.LonMin, p[0])
+			box.LonMax = math.Max(box.LonMax, p[0])
+			box.LatMin = math.Min(box.LatMin, p[1])
+			box.LatMax = math.Max(box.LatMax, p[1])
+		}
+	}
+
+	return box
+}
+
+func filter(positions []Position, keep func(Position) bool) []Position {
+	kept := positions[:0]
+	for _, pos := range positions {
+		if keep(pos) {
+			kept = append(kept, pos)
+		}
+	}
+
+	return kept
+}
+
+// sorted orders fixes by aircraft and time, as each query does on its own
+func sorted(positions []Position) []Position {
+	sort.SliceStable(positions, func(i, j int) bool {
+		if positions[i].Icao24.String != positions[j].Icao24.String {
+			return positions[i].Icao24.String < positions[j].Icao24.String
+		}
+		return positions[i].TimePosition.Before(positions[j].TimePosition)
+	})
+
+	return positions
+}
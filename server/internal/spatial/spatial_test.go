@@ -0,0 +1,107 @@
+package spatial_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geojson"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/spatial"
+)
+
+type mockQueries struct {
+	fixes []spatial.Position
+	boxes []repository.GetPositionsInBoxParams
+}
+
+// GetPositionsInBox filters on the box like the query does
+func (m *mockQueries) GetPositionsInBox(ctx context.Context, arg repository.GetPositionsInBoxParams) ([]repository.GetPositionsInBoxRow, error) {
+	m.boxes = append(m.boxes, arg)
+
+	var found []spatial.Position
+	for _, f := range m.fixes {
+		if f.Latitude.Float64 >= arg.LatMin.Float64 && f.Latitude.Float64 <= arg.LatMax.Float64 &&
+			f.Longitude.Float64 >= arg.LonMin.Float64 && f.Longitude.Float64 <= arg.LonMax.Float64 {
+			found = append(found, f)
+		}
+	}
+	return found, nil
+}
+
+func fix(icao24 string, minute int, lat, lon float64) spatial.Position {
+	return spatial.Position{
+		Icao24:       sql.NullString{String: icao24, Valid: true},
+		TimePosition: time.Date(2026, 10, 19, 12, minute, 0, 0, time.UTC),
+		Latitude:     sql.NullFloat64{Float64: lat, Valid: true},
+		Longitude:    sql.NullFloat64{Float64: lon, Valid: true},
+	}
+}
+
+func TestPlainNear(t *testing.T) {
+	mock := &mockQueries{fixes: []spatial.Position{
+		fix("a", 0, 60.3172, 24.9633),
+		// inside the bounding box but about 1.3 km away along the diagonal
+		fix("b", 0, 60.3252, 24.9793),
+		fix("c", 0, 60.3200, 24.9633),
+	}}
+	repo := &spatial.Plain{Queries: mock}
+
+	found, err := repo.Near(context.Background(), 60.3172, 24.9633, 1000, sql.NullTime{}, sql.NullTime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Icao24.String != "a" || found[1].Icao24.String != "c" {
+		t.Errorf("unexpected fixes %+v", found)
+	}
+}
+
+func TestPlainInPolygon(t *testing.T) {
+	mock := &mockQueries{fixes: []spatial.Position{
+		fix("a", 0, 60.2, 24.5),
+		// inside the bounds, outside the triangle
+		fix("b", 0, 60.8, 24.2),
+		// inside the hole
+		fix("c", 0, 60.22, 24.68),
+	}}
+	repo := &spatial.Plain{Queries: mock}
+
+	polygon := geojson.Polygon{
+		{{24, 60}, {25, 60}, {25, 61}, {24, 60}},
+		{{24.6, 60.2}, {24.7, 60.2}, {24.7, 60.3}, {24.6, 60.2}},
+	}
+	found, err := repo.InPolygon(context.Background(), polygon, sql.NullTime{}, sql.NullTime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Icao24.String != "a" {
+		t.Errorf("unexpected fixes %+v", found)
+	}
+	if b := mock.boxes[0]; b.LatMin.Float64 != 60 || b.LonMax.Float64 != 25 {
+		t.Errorf("expected the polygon bounds as candidate box, got %+v", b)
+	}
+}
+
+func TestPlainInBBoxAcrossAntimeridian(t *testing.T) {
+	mock := &mockQueries{fixes: []spatial.Position{
+		fix("b", 5, 15, -175),
+		fix("a", 0, 15, 175),
+		fix("b", 1, 15, 179),
+		fix("c", 0, 15, 0),
+	}}
+	repo := &spatial.Plain{Queries: mock}
+
+	box := opensky.BoundingBox{LatMin: 10, LonMin: 170, LatMax: 20, LonMax: -170}
+	found, err := repo.InBBox(context.Background(), box, sql.NullTime{}, sql.NullTime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.boxes) != 2 {
+		t.Fatalf("expected a query on either side, got %+v", mock.boxes)
+	}
+	if len(found) != 3 || found[0].Icao24.String != "a" || found[1].TimePosition.Minute() != 1 || found[2].TimePosition.Minute() != 5 {
+		t.Errorf("expected fixes ordered by aircraft and time, got %+v", found)
+	}
+}
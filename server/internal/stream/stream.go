@@ -0,0 +1,142 @@
+// Package stream fans out each poll's positions to server-sent event clients
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+// Position is the compact form of a fix sent to stream clients
+type Position struct {
+	Icao24   string   `json:"icao24"`
+	Callsign string   `json:"callsign,omitempty"`
+	Lat      float64  `json:"lat"`
+	Lon      float64  `json:"lon"`
+	Altitude *float64 `json:"alt,omitempty"`
+	Heading  *float64 `json:"hdg,omitempty"`
+	Velocity *float64 `json:"vel,omitempty"`
+	Time     int64    `json:"t"`
+}
+
+type Event struct {
+	ID   uint64
+	Data []byte
+}
+
+// Broker keeps a short history for Last-Event-ID resume and a bounded buffer
+// per client. Clients that fall behind are disconnected instead of blocking
+// ingestion; they can resume from the history when they reconnect.
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	size    int
+	buffer  int
+	clients map[chan Event]struct{}
+}
+
+func NewBroker(historySize, clientBuffer int) *Broker {
+	return &Broker{
+		nextID:  1,
+		size:    historySize,
+		buffer:  clientBuffer,
+		clients: map[chan Event]struct{}{},
+	}
+}
+
+// AfterPoll implements opensky.PollHook
+func (b *Broker) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	compact := make([]Position, 0, len(positions))
+	for _, p := range positions {
+		if !p.Latitude.Valid || !p.Longitude.Valid {
+			continue
+		}
+
+		pos := Position{
+			Icao24:   p.Icao24.String,
+			Callsign: p.Callsign.String,
+			Lat:      p.Latitude.Float64,
+			Lon:      p.Longitude.Float64,
+			Time:     int64(p.ToTimestamp),
+		}
+		if p.BaroAltitude.Valid {
+			pos.Altitude = &p.BaroAltitude.Float64
+		}
+		if p.Heading.Valid {
+			pos.Heading = &p.Heading.Float64
+		}
+		if p.Velocity.Valid {
+			pos.Velocity = &p.Velocity.Float64
+		}
+		compact = append(compact, pos)
+	}
+
+	data, err := json.Marshal(compact)
+	if err != nil {
+		log.Printf("stream encode failed: %v", err)
+		return
+	}
+
+	b.Publish(data)
+}
+
+// Publish records data in the history and sends it to every client
+func (b *Broker) Publish(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ev := Event{ID: b.nextID, Data: data}
+	b.nextID++
+
+	b.history = append(b.history, ev)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- ev:
+		default:
+			// slow client, drop it so it resumes with Last-Event-ID
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe registers a client. Events after lastID that are still in the
+// history are returned for replay. The channel is closed when the client is
+// dropped; cancel must be called when the client goes away.
+func (b *Broker) Subscribe(lastID uint64) (replay []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastID > 0 {
+		for _, ev := range b.history {
+			if ev.ID > lastID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	ch := make(chan Event, b.buffer)
+	b.clients[ch] = struct{}{}
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.clients[ch]; ok {
+				delete(b.clients, ch)
+				close(ch)
+			}
+		})
+	}
+
+	return replay, ch, cancel
+}
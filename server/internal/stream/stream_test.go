@@ -0,0 +1,44 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/stream"
+)
+
+func TestSubscribeReplaysAfterLastID(t *testing.T) {
+	b := stream.NewBroker(2, 4)
+	b.Publish([]byte(`"a"`))
+	b.Publish([]byte(`"b"`))
+	b.Publish([]byte(`"c"`))
+
+	replay, _, cancel := b.Subscribe(1)
+	defer cancel()
+
+	// history only keeps "b" and "c", both newer than id 1
+	if len(replay) != 2 || replay[0].ID != 2 || string(replay[1].Data) != `"c"` {
+		t.Errorf("unexpected replay: %+v", replay)
+	}
+
+	if replay, _, cancel := b.Subscribe(0); len(replay) != 0 {
+		t.Errorf("expected no replay without Last-Event-ID, got %d", len(replay))
+	} else {
+		cancel()
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	b := stream.NewBroker(10, 1)
+	_, events, cancel := b.Subscribe(0)
+	defer cancel()
+
+	b.Publish([]byte(`1`))
+	b.Publish([]byte(`2`)) // buffer full, client is dropped
+
+	if ev, ok := <-events; !ok || ev.ID != 1 {
+		t.Fatalf("expected the buffered event, got %+v", ev)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed for the slow client")
+	}
+}
@@ -0,0 +1,98 @@
+// Package tile converts between slippy map tiles and WGS84 coordinates
+package tile
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxZoom is the deepest zoom level tiles are served for
+const MaxZoom = 22
+
+type Tile struct {
+	Z, X, Y int
+}
+
+// Parse reads z/x/y path segments. suffix (such as ".mvt") is optional on y.
+func Parse(z, x, y, suffix string) (Tile, error) {
+	y = strings.TrimSuffix(y, suffix)
+
+	var t Tile
+	var err error
+	if t.Z, err = strconv.Atoi(z); err != nil {
+		return Tile{}, errors.New("invalid z")
+	}
+	if t.X, err = strconv.Atoi(x); err != nil {
+		return Tile{}, errors.New("invalid x")
+	}
+	if t.Y, err = strconv.Atoi(y); err != nil {
+		return Tile{}, errors.New("invalid y")
+	}
+
+	if t.Z < 0 || t.Z > MaxZoom {
+		return Tile{}, errors.New("zoom out of range")
+	}
+	n := 1 << t.Z
+	if t.X < 0 || t.X >= n || t.Y < 0 || t.Y >= n {
+		return Tile{}, errors.New("tile out of range")
+	}
+
+	return t, nil
+}
+
+// Bounds returns the tile's extent in degrees
+func (t Tile) Bounds() (latMin, lonMin, latMax, lonMax float64) {
+	n := float64(int(1) << t.Z)
+
+	lonMin = float64(t.X)/n*360 - 180
+	lonMax = float64(t.X+1)/n*360 - 180
+	latMax = tileLat(float64(t.Y), n)
+	latMin = tileLat(float64(t.Y+1), n)
+
+	return latMin, lonMin, latMax, lonMax
+}
+
+// Project returns the position of lat/lon within the tile scaled to extent,
+// with y growing downwards. Points outside the tile fall outside [0, extent].
+func (t Tile) Project(lat, lon, extent float64) (x, y float64) {
+	n := float64(int(1) << t.Z)
+	mx, my := Mercator(lat, lon)
+
+	return (mx*n - float64(t.X)) * extent, (my*n - float64(t.Y)) * extent
+}
+
+// Mercator returns Web Mercator world coordinates in [0, 1], with y growing
+// southwards
+func Mercator(lat, lon float64) (x, y float64) {
+	// clamp to the Web Mercator limit so the poles stay finite
+	lat = math.Max(-85.0511, math.Min(85.0511, lat))
+	latRad := lat * math.Pi / 180
+
+	x = (lon + 180) / 360
+	y = (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2
+
+	return x, y
+}
+
+func tileLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+// BinForZoom picks the heatmap bin size (cells per degree) for a zoom level so
+// cells stay roughly the same size on screen
+func BinForZoom(zoom int) int {
+	switch {
+	case zoom >= 13:
+		return 160
+	case zoom >= 11:
+		return 80
+	case zoom >= 9:
+		return 40
+	case zoom >= 7:
+		return 20
+	default:
+		return 10
+	}
+}
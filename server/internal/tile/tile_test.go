@@ -0,0 +1,47 @@
+package tile_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/tile"
+)
+
+func TestParse(t *testing.T) {
+	got, err := tile.Parse("10", "583", "296.mvt", ".mvt")
+	if err != nil || got != (tile.Tile{Z: 10, X: 583, Y: 296}) {
+		t.Fatalf("unexpected tile %+v, err %v", got, err)
+	}
+
+	for _, c := range [][3]string{
+		{"a", "0", "0"},
+		{"1", "2", "0"},
+		{"1", "0", "-1"},
+		{"23", "0", "0"},
+		{"1", "0", "0.png"},
+	} {
+		if _, err := tile.Parse(c[0], c[1], c[2], ".mvt"); err == nil {
+			t.Errorf("expected error for %v", c)
+		}
+	}
+}
+
+func TestBoundsAndProject(t *testing.T) {
+	// tile covering Helsinki-Vantaa at zoom 10
+	tl := tile.Tile{Z: 10, X: 583, Y: 295}
+	latMin, lonMin, latMax, lonMax := tl.Bounds()
+
+	if latMin >= 60.3172 || latMax <= 60.3172 || lonMin >= 24.9633 || lonMax <= 24.9633 {
+		t.Fatalf("airport not inside bounds %f %f %f %f", latMin, lonMin, latMax, lonMax)
+	}
+
+	x, y := tl.Project(latMax, lonMin, 4096)
+	if math.Abs(x) > 1e-6 || math.Abs(y) > 1e-6 {
+		t.Errorf("expected north west corner at origin, got %f %f", x, y)
+	}
+
+	x, y = tl.Project(latMin, lonMax, 4096)
+	if math.Abs(x-4096) > 1e-6 || math.Abs(y-4096) > 1e-6 {
+		t.Errorf("expected south east corner at extent, got %f %f", x, y)
+	}
+}
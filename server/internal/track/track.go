@@ -0,0 +1,95 @@
+// Package track splits and simplifies an aircraft's ordered position fixes
+package track
+
+import (
+	"math"
+	"time"
+)
+
+type Fix struct {
+	Time     time.Time
+	Lat      float64
+	Lon      float64
+	Altitude *float64
+	Velocity *float64
+}
+
+// Split breaks fixes into segments wherever consecutive fixes are more than
+// maxGap apart. Fixes must be ordered by time.
+func Split(fixes []Fix, maxGap time.Duration) [][]Fix {
+	var segments [][]Fix
+	start := 0
+	for i := 1; i <= len(fixes); i++ {
+		if i == len(fixes) || fixes[i].Time.Sub(fixes[i-1].Time) > maxGap {
+			if i > start {
+				segments = append(segments, fixes[start:i])
+			}
+			start = i
+		}
+	}
+
+	return segments
+}
+
+// Simplify applies Douglas–Peucker with a tolerance in metres. The first and
+// last fix are always kept.
+func Simplify(fixes []Fix, toleranceM float64) []Fix {
+	if len(fixes) < 3 || toleranceM <= 0 {
+		return fixes
+	}
+
+	keep := make([]bool, len(fixes))
+	keep[0], keep[len(fixes)-1] = true, true
+
+	// iterative to avoid deep recursion on long tracks
+	stack := [][2]int{{0, len(fixes) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist, index := 0.0, -1
+		for i := span[0] + 1; i < span[1]; i++ {
+			if d := segmentDistance(fixes[i], fixes[span[0]], fixes[span[1]]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+
+		if index >= 0 && maxDist > toleranceM {
+			keep[index] = true
+			stack = append(stack, [2]int{span[0], index}, [2]int{index, span[1]})
+		}
+	}
+
+	simplified := make([]Fix, 0, len(fixes))
+	for i, f := range fixes {
+		if keep[i] {
+			simplified = append(simplified, f)
+		}
+	}
+
+	return simplified
+}
+
+// segmentDistance is the distance in metres from p to the segment a-b on a
+// local equirectangular projection, which is accurate enough at track scale
+func segmentDistance(p, a, b Fix) float64 {
+	const earthRadiusM = 6371000.0
+
+	cosLat := math.Cos(a.Lat * math.Pi / 180)
+	project := func(f Fix) (float64, float64) {
+		x := (f.Lon - a.Lon) * math.Pi / 180 * earthRadiusM * cosLat
+		y := (f.Lat - a.Lat) * math.Pi / 180 * earthRadiusM
+		return x, y
+	}
+
+	px, py := project(p)
+	bx, by := project(b)
+
+	lengthSq := bx*bx + by*by
+	if lengthSq == 0 {
+		return math.Hypot(px, py)
+	}
+
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/lengthSq))
+	return math.Hypot(px-t*bx, py-t*by)
+}
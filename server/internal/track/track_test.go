@@ -0,0 +1,60 @@
+package track_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/track"
+)
+
+var start = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func fix(seconds int, lat, lon float64) track.Fix {
+	return track.Fix{Time: start.Add(time.Duration(seconds) * time.Second), Lat: lat, Lon: lon}
+}
+
+func TestSplitOnGaps(t *testing.T) {
+	fixes := []track.Fix{
+		fix(0, 60.0, 25.0),
+		fix(30, 60.1, 25.0),
+		fix(60, 60.2, 25.0),
+		fix(900, 60.3, 25.0), // 14 minute gap
+		fix(930, 60.4, 25.0),
+	}
+
+	segments := track.Split(fixes, 5*time.Minute)
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(segments))
+	}
+	if len(segments[0]) != 3 || len(segments[1]) != 2 {
+		t.Errorf("unexpected segment sizes: %d, %d", len(segments[0]), len(segments[1]))
+	}
+
+	if got := track.Split(nil, time.Minute); len(got) != 0 {
+		t.Errorf("expected no segments for no fixes, got %d", len(got))
+	}
+}
+
+func TestSimplifyDropsNearlyCollinearFixes(t *testing.T) {
+	fixes := []track.Fix{
+		fix(0, 60.0, 25.0),
+		fix(10, 60.01, 25.0001), // ~5 m off the line
+		fix(20, 60.02, 25.0),
+		fix(30, 60.03, 25.02), // ~1.1 km off the line
+		fix(40, 60.04, 25.0),
+	}
+
+	simplified := track.Simplify(fixes, 100)
+	if len(simplified) != 4 {
+		t.Fatalf("expected 4 fixes after simplification, got %d", len(simplified))
+	}
+	for _, f := range simplified {
+		if f.Lat == 60.01 {
+			t.Error("expected the fix 5 m off the line to be dropped")
+		}
+	}
+
+	if got := track.Simplify(fixes, 0); len(got) != len(fixes) {
+		t.Errorf("expected no simplification without tolerance, got %d fixes", len(got))
+	}
+}
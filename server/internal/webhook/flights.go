@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+type publisher interface {
+	Publish(ctx context.Context, event string, data any)
+}
+
+// FlightSeen is the data of a flight.seen event
+type FlightSeen struct {
+	Icao24        string    `json:"icao24"`
+	Callsign      string    `json:"callsign"`
+	OriginCountry string    `json:"origin_country"`
+	Lat           float64   `json:"lat"`
+	Lon           float64   `json:"lon"`
+	Altitude      *float64  `json:"altitude"`
+	Time          time.Time `json:"time"`
+}
+
+// FlightHook publishes flight.seen when an aircraft shows up that has not
+// been seen within Forget
+type FlightHook struct {
+	Publisher publisher
+	Forget    time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewFlightHook(p publisher) *FlightHook {
+	return &FlightHook{
+		Publisher: p,
+		Forget:    30 * time.Minute,
+		seen:      map[string]time.Time{},
+	}
+}
+
+// AfterPoll implements opensky.PollHook
+func (h *FlightHook) AfterPoll(ctx context.Context, positions []repository.InsertPositionParams) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range positions {
+		if !p.Icao24.Valid {
+			continue
+		}
+
+		last, ok := h.seen[p.Icao24.String]
+		h.seen[p.Icao24.String] = now
+		if ok && now.Sub(last) <= h.Forget {
+			continue
+		}
+
+		seen := FlightSeen{
+			Icao24:        p.Icao24.String,
+			Callsign:      p.Callsign.String,
+			OriginCountry: p.OriginCountry.String,
+			Lat:           p.Latitude.Float64,
+			Lon:           p.Longitude.Float64,
+			Time:          time.Unix(int64(p.ToTimestamp), 0).UTC(),
+		}
+		if p.BaroAltitude.Valid {
+			seen.Altitude = &p.BaroAltitude.Float64
+		}
+		h.Publisher.Publish(ctx, EventFlightSeen, seen)
+	}
+
+	for icao24, last := range h.seen {
+		if now.Sub(last) > h.Forget {
+			delete(h.seen, icao24)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package webhook delivers signed event payloads to registered endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+)
+
+const (
+	EventFlightSeen       = "flight.seen"
+	EventGeofenceCrossed  = "geofence.crossed"
+	EventEmergencySquawk  = "squawk.emergency"
+	EventIngestionFailing = "ingestion.failing"
+)
+
+// Events lists every event a webhook can subscribe to
+var Events = []string{EventFlightSeen, EventGeofenceCrossed, EventEmergencySquawk, EventIngestionFailing}
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+type Querier interface {
+	ListActiveWebhooks(ctx context.Context) ([]repository.Webhook, error)
+	InsertWebhookDelivery(ctx context.Context, arg repository.InsertWebhookDeliveryParams) error
+	InsertWebhookDeadLetter(ctx context.Context, arg repository.InsertWebhookDeadLetterParams) error
+}
+
+// Payload is the JSON body sent to webhook endpoints
+type Payload struct {
+	ID    string    `json:"id"`
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data"`
+}
+
+type event struct {
+	name string
+	data any
+	at   time.Time
+}
+
+type job struct {
+	webhook repository.Webhook
+	payload Payload
+	body    []byte
+	attempt int
+}
+
+// Dispatcher queues events and delivers them with exponential backoff.
+// Deliveries that keep failing end up in the dead-letter table.
+type Dispatcher struct {
+	Queries     Querier
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// CacheTTL is how long the active webhooks are reused before they are
+	// listed again, so changes take up to this long to apply
+	CacheTTL time.Duration
+
+	events chan event
+	queue  chan job
+
+	mu       sync.Mutex
+	hooks    []repository.Webhook
+	hooksAge time.Time
+}
+
+func NewDispatcher(queries Querier) *Dispatcher {
+	return &Dispatcher{
+		Queries:     queries,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    5 * time.Minute,
+		CacheTTL:    30 * time.Second,
+		events:      make(chan event, 256),
+		queue:       make(chan job, 256),
+	}
+}
+
+// Start runs the fan-out of events to webhooks and delivery workers until ctx
+// is cancelled
+func (d *Dispatcher) Start(ctx context.Context, workers int) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-d.events:
+				d.fanOut(ctx, e)
+			}
+		}
+	}()
+
+	for range workers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-d.queue:
+					d.deliver(ctx, j)
+				}
+			}
+		}()
+	}
+}
+
+// Publish queues an event for every active webhook subscribed to it.
+// It never blocks ingestion: the webhooks are looked up off the caller's
+// path and events are dropped when the queue is full.
+func (d *Dispatcher) Publish(ctx context.Context, name string, data any) {
+	select {
+	case d.events <- event{name: name, data: data, at: time.Now().UTC()}:
+	default:
+		log.Printf("webhook queue full, dropping %s", name)
+	}
+}
+
+// Invalidate makes the next event list the active webhooks again
+func (d *Dispatcher) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooksAge = time.Time{}
+}
+
+func (d *Dispatcher) activeWebhooks(ctx context.Context) ([]repository.Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.hooksAge.IsZero() && time.Since(d.hooksAge) < d.CacheTTL {
+		return d.hooks, nil
+	}
+
+	hooks, err := d.Queries.ListActiveWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.hooks, d.hooksAge = hooks, time.Now()
+
+	return hooks, nil
+}
+
+func (d *Dispatcher) fanOut(ctx context.Context, e event) {
+	hooks, err := d.activeWebhooks(ctx)
+	if err != nil {
+		log.Printf("webhook list failed: %v", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if !subscribed(hook, e.name) {
+			continue
+		}
+
+		payload := Payload{
+			ID:    newDeliveryID(),
+			Event: e.name,
+			Time:  e.at,
+			Data:  e.data,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			// the other hooks still get theirs
+			log.Printf("webhook payload encode failed for webhook %d: %v", hook.ID, err)
+			continue
+		}
+
+		select {
+		case d.queue <- job{webhook: hook, payload: payload, body: body, attempt: 1}:
+		default:
+			log.Printf("webhook queue full, dropping %s for webhook %d", e.name, hook.ID)
+		}
+	}
+}
+
+// deliver makes one attempt. Failed attempts are re-queued after a backoff
+// rather than waited out here, so a dead endpoint doesn't hold a worker.
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	start := time.Now()
+	status, err := d.send(ctx, j)
+	duration := time.Since(start)
+
+	delivery := repository.InsertWebhookDeliveryParams{
+		WebhookID:  j.webhook.ID,
+		DeliveryID: sql.NullString{String: j.payload.ID, Valid: true},
+		Event:      sql.NullString{String: j.payload.Event, Valid: true},
+		Attempt:    int32(j.attempt),
+		StatusCode: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+		DurationMs: int32(duration.Milliseconds()),
+	}
+	if err != nil {
+		delivery.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+	if err := d.Queries.InsertWebhookDelivery(ctx, delivery); err != nil {
+		log.Printf("webhook delivery log failed: %v", err)
+	}
+
+	if err == nil {
+		return
+	}
+
+	if j.attempt < d.MaxAttempts {
+		retry := j
+		retry.attempt++
+		time.AfterFunc(d.backoff(j.attempt), func() {
+			select {
+			case <-ctx.Done():
+			case d.queue <- retry:
+			}
+		})
+		return
+	}
+
+	err = d.Queries.InsertWebhookDeadLetter(ctx, repository.InsertWebhookDeadLetterParams{
+		WebhookID:  j.webhook.ID,
+		DeliveryID: sql.NullString{String: j.payload.ID, Valid: true},
+		Event:      sql.NullString{String: j.payload.Event, Valid: true},
+		Payload:    j.body,
+		Attempts:   int32(j.attempt),
+		LastError:  sql.NullString{String: delivery.Error.String, Valid: delivery.Error.Valid},
+	})
+	if err != nil {
+		log.Printf("webhook dead letter insert failed: %v", err)
+	}
+}
+
+// send posts the payload once, returning the status code when one was received
+func (d *Dispatcher) send(ctx context.Context, j job) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.webhook.Url.String, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, j.payload.Event)
+	req.Header.Set(DeliveryHeader, j.payload.ID)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(j.webhook.Secret.String, j.body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before retry n, doubling from BaseDelay
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.BaseDelay << (n - 1)
+	if delay <= 0 || delay > d.MaxDelay {
+		return d.MaxDelay
+	}
+
+	return delay
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// subscribed reports whether the webhook wants the event. No events means all.
+func subscribed(hook repository.Webhook, event string) bool {
+	var events []string
+	if err := json.Unmarshal(hook.Events, &events); err != nil {
+		return false
+	}
+
+	return len(events) == 0 || slices.Contains(events, event)
+}
@@ -0,0 +1,213 @@
+package webhook_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
+)
+
+type mockQueries struct {
+	mu          sync.Mutex
+	webhooks    []repository.Webhook
+	deliveries  []repository.InsertWebhookDeliveryParams
+	deadLetters []repository.InsertWebhookDeadLetterParams
+	done        chan struct{}
+	lists       atomic.Int32
+}
+
+func (m *mockQueries) ListActiveWebhooks(ctx context.Context) ([]repository.Webhook, error) {
+	m.lists.Add(1)
+	return m.webhooks, nil
+}
+
+func (m *mockQueries) InsertWebhookDelivery(ctx context.Context, arg repository.InsertWebhookDeliveryParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, arg)
+	if !arg.Error.Valid {
+		close(m.done)
+	}
+	return nil
+}
+
+func (m *mockQueries) InsertWebhookDeadLetter(ctx context.Context, arg repository.InsertWebhookDeadLetterParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetters = append(m.deadLetters, arg)
+	close(m.done)
+	return nil
+}
+
+func newDispatcher(t *testing.T, receiver *httptest.Server, events string) (*webhook.Dispatcher, *mockQueries) {
+	t.Helper()
+
+	mock := &mockQueries{
+		done: make(chan struct{}),
+		webhooks: []repository.Webhook{
+			{
+				ID:     1,
+				Url:    sql.NullString{String: receiver.URL, Valid: true},
+				Secret: sql.NullString{String: "s3cret", Valid: true},
+				Events: json.RawMessage(events),
+				Active: true,
+			},
+		},
+	}
+
+	d := webhook.NewDispatcher(mock)
+	d.BaseDelay = time.Millisecond
+	d.MaxAttempts = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	d.Start(ctx, 1)
+
+	return d, mock
+}
+
+func waitDone(t *testing.T, mock *mockQueries) {
+	t.Helper()
+	select {
+	case <-mock.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+}
+
+func TestDeliveryIsSignedAndRetried(t *testing.T) {
+	var calls atomic.Int32
+	var signatureOK atomic.Bool
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signatureOK.Store(r.Header.Get(webhook.SignatureHeader) == "sha256="+webhook.Sign("s3cret", body))
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d, mock := newDispatcher(t, receiver, `["flight.seen"]`)
+	d.Publish(context.Background(), webhook.EventFlightSeen, map[string]string{"icao24": "abc123"})
+	waitDone(t, mock)
+
+	if !signatureOK.Load() {
+		t.Error("expected a valid signature")
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	if len(mock.deliveries) != 2 {
+		t.Fatalf("expected 2 logged attempts, got %d", len(mock.deliveries))
+	}
+	if mock.deliveries[0].StatusCode.Int32 != 500 || mock.deliveries[1].Attempt != 2 {
+		t.Errorf("unexpected delivery log: %+v", mock.deliveries)
+	}
+	if len(mock.deadLetters) != 0 {
+		t.Errorf("expected no dead letters, got %d", len(mock.deadLetters))
+	}
+}
+
+func TestFailingDeliveryIsDeadLettered(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	d, mock := newDispatcher(t, receiver, `[]`)
+	d.Publish(context.Background(), webhook.EventIngestionFailing, map[string]int{"failures": 3})
+	waitDone(t, mock)
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	if len(mock.deliveries) != 3 {
+		t.Errorf("expected 3 logged attempts, got %d", len(mock.deliveries))
+	}
+	if len(mock.deadLetters) != 1 || mock.deadLetters[0].Attempts != 3 {
+		t.Fatalf("expected one dead letter after 3 attempts, got %+v", mock.deadLetters)
+	}
+
+	var payload webhook.Payload
+	if err := json.Unmarshal(mock.deadLetters[0].Payload, &payload); err != nil || payload.Event != webhook.EventIngestionFailing {
+		t.Errorf("unexpected dead letter payload: %s", mock.deadLetters[0].Payload)
+	}
+}
+
+func TestUnsubscribedEventIsSkipped(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected delivery")
+	}))
+	defer receiver.Close()
+
+	d, _ := newDispatcher(t, receiver, `["geofence.crossed"]`)
+	d.Publish(context.Background(), webhook.EventFlightSeen, nil)
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestRetryDoesNotHoldAWorker(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d, mock := newDispatcher(t, receiver, `[]`)
+	// the dead endpoint is delivered to first, and its retry is far off
+	d.BaseDelay = time.Hour
+	mock.webhooks = append([]repository.Webhook{{
+		ID:     2,
+		Url:    sql.NullString{String: dead.URL, Valid: true},
+		Events: json.RawMessage(`[]`),
+		Active: true,
+	}}, mock.webhooks...)
+
+	d.Publish(context.Background(), webhook.EventFlightSeen, nil)
+	waitDone(t, mock)
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.deliveries) != 2 || mock.deliveries[0].WebhookID != 2 || mock.deliveries[1].WebhookID != 1 {
+		t.Errorf("unexpected delivery log: %+v", mock.deliveries)
+	}
+}
+
+func TestActiveWebhooksAreCached(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected delivery")
+	}))
+	defer receiver.Close()
+
+	d, mock := newDispatcher(t, receiver, `["geofence.crossed"]`)
+	for range 3 {
+		d.Publish(context.Background(), webhook.EventFlightSeen, nil)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := mock.lists.Load(); n != 1 {
+		t.Errorf("expected the webhooks to be listed once, got %d", n)
+	}
+
+	d.Invalidate()
+	d.Publish(context.Background(), webhook.EventFlightSeen, nil)
+	time.Sleep(20 * time.Millisecond)
+	if n := mock.lists.Load(); n != 2 {
+		t.Errorf("expected invalidation to list the webhooks again, got %d", n)
+	}
+}
@@ -3,20 +3,31 @@ package main
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"time"
+	_ "time/tzdata" // time zones for TIME_ZONE when the host has none
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/ChristianVilen/flight-heatmap/server/internal/alert"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/api"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/config"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/geofence"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/live"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/middleware"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/opensky"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/partition"
 	"github.com/ChristianVilen/flight-heatmap/server/internal/repository"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/retention"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/rollup"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/spatial"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/stream"
+	"github.com/ChristianVilen/flight-heatmap/server/internal/webhook"
 )
 
 func init() {
@@ -54,6 +65,18 @@ func main() {
 	defer dbConn.Close()
 
 	repo := repository.New(dbConn)
+	postgis := cfg.PostGIS
+	if postgis {
+		ok, err := spatial.Available(ctx, dbConn)
+		if err != nil {
+			log.Fatal("failed to check for PostGIS:", err)
+		}
+		if !ok {
+			log.Println("PostGIS is not set up, run scripts/enable-postgis.sh; using plain spatial queries")
+			postgis = false
+		}
+	}
+	spatialRepo := spatial.New(dbConn, postgis)
 	baseURL, err := url.Parse(APIURLStatesAll)
 	if err != nil {
 		log.Fatal("invalid base URL:", err)
@@ -70,6 +93,38 @@ func main() {
 	params.Set("lamax", fmt.Sprintf("%.4f", bbox.LatMax))
 	params.Set("lomin", fmt.Sprintf("%.4f", bbox.LonMin))
 	params.Set("lomax", fmt.Sprintf("%.4f", bbox.LonMax))
+	params.Set("extended", "1") // includes aircraft category in the state vectors
+	baseURL.RawQuery = params.Encode()
+
+	// partitions for today and the days ahead must exist before ingestion
+	partitions := partition.NewManager(dbConn, repo, cfg.ThinnedRetention)
+	partitions.Detach = cfg.DetachPartitions
+	if err := partitions.Run(ctx); err != nil {
+		log.Printf("partition maintenance failed: %v", err)
+	}
+	partitions.Start(ctx, cfg.RetentionInterval)
+
+	dispatcher := webhook.NewDispatcher(repo)
+	dispatcher.Start(ctx, 4)
+
+	geofenceDetector := geofence.NewDetector(repo)
+	geofenceDetector.Publisher = dispatcher
+
+	alertDetector := alert.NewDetector(repo)
+	alertDetector.Publisher = dispatcher
+
+	broker := stream.NewBroker(20, 8)
+	liveIndex := live.NewIndex()
+
+	rollupJob := rollup.NewJob(repo)
+	rollupJob.Start(ctx)
+
+	retentionJob := retention.NewJob(repo, retention.Policy{
+		Raw:          cfg.RawRetention,
+		ThinInterval: cfg.ThinInterval,
+		Thinned:      cfg.ThinnedRetention,
+	})
+	retentionJob.Start(ctx, cfg.RetentionInterval)
 
 	fetcher := opensky.Fetcher{
 		Client:       http.DefaultClient,
@@ -77,26 +132,80 @@ func main() {
 		Inserter:     repository.New(dbConn),
 		Config:       cfg,
 		APIURL:       baseURL.String(),
+		Hooks: []opensky.PollHook{
+			broker,
+			liveIndex,
+			geofenceDetector,
+			alertDetector,
+			webhook.NewFlightHook(dispatcher),
+			rollupJob,
+		},
 	}
 
 	PollInterval := 45 * time.Second
+	const ingestionFailureThreshold = 3
 
 	go func() {
 		ticker := time.NewTicker(PollInterval)
 		defer ticker.Stop()
 
+		failures := 0
 		for range ticker.C {
 			log.Println("Polling OpenSky API...")
 			if err := fetcher.FetchAndStore(ctx); err != nil {
 				log.Printf("fetch error: %v", err)
+
+				failures++
+				if failures == ingestionFailureThreshold {
+					dispatcher.Publish(ctx, webhook.EventIngestionFailing, map[string]any{
+						"consecutive_failures": failures,
+						"error":                err.Error(),
+					})
+				}
+				continue
 			}
+			failures = 0
 		}
 	}()
 
 	router := http.NewServeMux()
 
-	router.HandleFunc("GET /api/heatmap", api.HeatmapHandler(repo))
+	router.HandleFunc("GET /api/heatmap", api.HeatmapHandler(repo, cfg.TimeZone))
+	router.HandleFunc("GET /api/tiles/{z}/{x}/{y}", api.VectorTileHandler(repo, cfg.TimeZone))
+	router.HandleFunc("GET /api/heatmap/{z}/{x}/{y}", api.RasterTileHandler(repo, cfg.TimeZone))
+	router.HandleFunc("GET /api/heatmap.png", api.HeatmapSnapshotHandler(repo, cfg.TimeZone))
 	router.HandleFunc("GET /api/marker-details", api.MarkerDetailsHandler(repo))
+	router.HandleFunc("GET /api/stream", api.StreamHandler(broker))
+	router.HandleFunc("GET /api/noise", api.NoiseHandler(repo))
+	router.HandleFunc("GET /api/aircraft/live", api.LiveAircraftHandler(liveIndex, cfg.LiveMaxAge))
+	router.HandleFunc("GET /api/aircraft/{icao24}/track", api.AircraftTrackHandler(repo))
+	router.HandleFunc("GET /api/positions", api.PositionsHandler(repo, spatialRepo))
+	router.HandleFunc("GET /api/flow", api.FlowHandler(repo))
+
+	router.HandleFunc("GET /api/points", api.ListPointsHandler(repo))
+	router.HandleFunc("POST /api/points", api.CreatePointHandler(repo))
+	router.HandleFunc("GET /api/points/{id}", api.GetPointHandler(repo))
+	router.HandleFunc("PUT /api/points/{id}", api.UpdatePointHandler(repo))
+	router.HandleFunc("DELETE /api/points/{id}", api.DeletePointHandler(repo))
+	router.HandleFunc("GET /api/points/{id}/overflights", api.OverflightsHandler(repo, spatialRepo))
+
+	router.HandleFunc("GET /api/geofences", api.ListGeofencesHandler(repo))
+	router.HandleFunc("POST /api/geofences", api.CreateGeofenceHandler(repo))
+	router.HandleFunc("GET /api/geofences/{id}", api.GetGeofenceHandler(repo))
+	router.HandleFunc("DELETE /api/geofences/{id}", api.DeleteGeofenceHandler(repo))
+	router.HandleFunc("GET /api/geofences/{id}/events", api.GeofenceEventsHandler(repo))
+
+	router.HandleFunc("GET /api/alerts", api.AlertsHandler(repo))
+	router.HandleFunc("GET /api/stats/timeseries", api.TimeseriesHandler(repo))
+	router.HandleFunc("GET /api/stats/weekly", api.WeeklyMatrixHandler(repo, cfg.TimeZone))
+
+	router.HandleFunc("GET /api/webhooks", api.ListWebhooksHandler(repo))
+	router.HandleFunc("POST /api/webhooks", api.CreateWebhookHandler(repo, dispatcher))
+	router.HandleFunc("DELETE /api/webhooks/{id}", api.DeleteWebhookHandler(repo, dispatcher))
+	router.HandleFunc("GET /api/webhooks/{id}/deliveries", api.WebhookDeliveriesHandler(repo))
+	router.HandleFunc("GET /api/webhooks/{id}/dead-letters", api.WebhookDeadLettersHandler(repo))
+
+	router.Handle("GET /debug/vars", expvar.Handler())
 
 	stack := middleware.CreateStack(
 		middleware.Logging,
@@ -0,0 +1 @@
+ALTER TABLE aircraft_positions ADD COLUMN category INTEGER;
@@ -0,0 +1,8 @@
+CREATE TABLE monitoring_points (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    radius_m DOUBLE PRECISION NOT NULL DEFAULT 2000,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
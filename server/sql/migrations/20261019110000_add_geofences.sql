@@ -0,0 +1,18 @@
+CREATE TABLE geofences (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    geometry JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE geofence_events (
+    id SERIAL PRIMARY KEY,
+    geofence_id INTEGER NOT NULL REFERENCES geofences(id) ON DELETE CASCADE,
+    icao24 TEXT,
+    callsign TEXT,
+    event TEXT NOT NULL,
+    time_position TIMESTAMP NOT NULL,
+    baro_altitude DOUBLE PRECISION
+);
+
+CREATE INDEX idx_geofence_events_fence_time ON geofence_events(geofence_id, time_position);
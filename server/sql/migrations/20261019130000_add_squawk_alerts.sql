@@ -0,0 +1,18 @@
+ALTER TABLE aircraft_positions ADD COLUMN squawk TEXT, ADD COLUMN spi BOOLEAN;
+
+CREATE TABLE alerts (
+    id SERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    icao24 TEXT,
+    callsign TEXT,
+    squawk TEXT,
+    time_position TIMESTAMP NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    baro_altitude DOUBLE PRECISION,
+    track JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_alerts_type_time ON alerts(type, time_position);
+CREATE INDEX IF NOT EXISTS idx_icao24_time ON aircraft_positions(icao24, time_position);
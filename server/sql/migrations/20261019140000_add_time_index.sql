@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_time_position ON aircraft_positions(time_position);
@@ -0,0 +1,14 @@
+CREATE TABLE heatmap_rollups (
+    bin_size INTEGER NOT NULL,
+    granularity TEXT NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    lat_bin DOUBLE PRECISION NOT NULL,
+    lon_bin DOUBLE PRECISION NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (bin_size, granularity, bucket, lat_bin, lon_bin)
+);
+
+CREATE TABLE rollup_state (
+    name TEXT PRIMARY KEY,
+    rolled_until TIMESTAMP NOT NULL
+);
@@ -0,0 +1,61 @@
+-- time_position becomes the partition key and NOT NULL. A fix without one
+-- can't be placed in a day, so rather than drop such rows the migration stops
+-- until they are deleted by hand.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM aircraft_positions WHERE time_position IS NULL) THEN
+        RAISE EXCEPTION 'aircraft_positions has rows without time_position, delete them before partitioning';
+    END IF;
+END $$;
+
+ALTER TABLE aircraft_positions RENAME TO aircraft_positions_unpartitioned;
+ALTER SEQUENCE aircraft_positions_id_seq OWNED BY NONE;
+
+CREATE TABLE aircraft_positions (
+    id INTEGER NOT NULL DEFAULT nextval('aircraft_positions_id_seq'),
+    icao24 TEXT,
+    callsign TEXT,
+    origin_country TEXT,
+    time_position TIMESTAMP NOT NULL,
+    longitude DOUBLE PRECISION,
+    latitude DOUBLE PRECISION,
+    baro_altitude DOUBLE PRECISION,
+    on_ground BOOLEAN,
+    velocity DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    vertical_rate DOUBLE PRECISION,
+    category INTEGER,
+    squawk TEXT,
+    spi BOOLEAN
+) PARTITION BY RANGE (time_position);
+
+ALTER SEQUENCE aircraft_positions_id_seq OWNED BY aircraft_positions.id;
+
+CREATE TABLE aircraft_positions_default PARTITION OF aircraft_positions DEFAULT;
+
+DO $$
+DECLARE
+    day DATE;
+BEGIN
+    SELECT COALESCE(min(time_position)::date, current_date) INTO day FROM aircraft_positions_unpartitioned;
+    WHILE day <= current_date + 7 LOOP
+        EXECUTE format(
+            'CREATE TABLE aircraft_positions_p%s PARTITION OF aircraft_positions FOR VALUES FROM (%L) TO (%L)',
+            to_char(day, 'YYYYMMDD'), day, day + 1
+        );
+        day := day + 1;
+    END LOOP;
+END $$;
+
+INSERT INTO aircraft_positions
+SELECT id, icao24, callsign, origin_country, time_position, longitude, latitude, baro_altitude,
+       on_ground, velocity, heading, vertical_rate, category, squawk, spi
+FROM aircraft_positions_unpartitioned;
+
+DROP TABLE aircraft_positions_unpartitioned;
+
+ALTER TABLE aircraft_positions ADD PRIMARY KEY (id, time_position);
+-- idx_icao24_time went with the old table, this unique index on the same columns replaces it
+ALTER TABLE aircraft_positions ADD CONSTRAINT aircraft_positions_icao24_time_position_key UNIQUE (icao24, time_position);
+CREATE INDEX idx_lat_lon ON aircraft_positions(latitude, longitude);
+CREATE INDEX idx_time_position ON aircraft_positions(time_position);
@@ -0,0 +1,49 @@
+CREATE FUNCTION filtered_positions(
+    from_time TIMESTAMP,
+    to_time TIMESTAMP,
+    lat_min DOUBLE PRECISION,
+    lat_max DOUBLE PRECISION,
+    lon_min DOUBLE PRECISION,
+    lon_max DOUBLE PRECISION,
+    alt_min DOUBLE PRECISION,
+    alt_max DOUBLE PRECISION,
+    velocity_min DOUBLE PRECISION,
+    velocity_max DOUBLE PRECISION,
+    vertical_sign INTEGER,
+    callsign_prefix TEXT,
+    icao24_list TEXT,
+    origin_country TEXT,
+    hours TEXT,
+    time_zone TEXT,
+    weekdays TEXT
+) RETURNS SETOF aircraft_positions
+LANGUAGE sql STABLE
+AS $$
+    SELECT p.*
+    FROM aircraft_positions p
+    WHERE
+        (from_time IS NULL OR p.time_position >= from_time)
+        AND (to_time IS NULL OR p.time_position < to_time)
+        AND (lat_min IS NULL OR (p.latitude >= lat_min AND p.latitude < lat_max))
+        AND (
+            lon_min IS NULL
+            OR (lon_min <= lon_max AND p.longitude >= lon_min AND p.longitude < lon_max)
+            OR (lon_min > lon_max AND (p.longitude >= lon_min OR p.longitude < lon_max))
+        )
+        AND (alt_min IS NULL OR p.baro_altitude >= alt_min)
+        AND (alt_max IS NULL OR p.baro_altitude <= alt_max)
+        AND (velocity_min IS NULL OR p.velocity >= velocity_min)
+        AND (velocity_max IS NULL OR p.velocity <= velocity_max)
+        AND (vertical_sign IS NULL OR sign(p.vertical_rate) = vertical_sign)
+        AND (callsign_prefix IS NULL OR p.callsign LIKE callsign_prefix || '%')
+        AND (icao24_list IS NULL OR p.icao24 = ANY(string_to_array(icao24_list, ',')))
+        AND (filtered_positions.origin_country IS NULL OR p.origin_country = filtered_positions.origin_country)
+        AND (
+            hours IS NULL
+            OR extract(hour FROM timezone(time_zone, p.time_position AT TIME ZONE 'UTC'))::int = ANY(string_to_array(hours, ',')::int[])
+        )
+        AND (
+            weekdays IS NULL
+            OR extract(isodow FROM timezone(time_zone, p.time_position AT TIME ZONE 'UTC'))::int = ANY(string_to_array(weekdays, ',')::int[])
+        )
+$$;
@@ -0,0 +1,26 @@
+-- geo.TM35FIN.Forward, the GRS80 Krüger series with its coefficients worked out
+CREATE FUNCTION tm35fin(lat DOUBLE PRECISION, lon DOUBLE PRECISION, OUT easting DOUBLE PRECISION, OUT northing DOUBLE PRECISION)
+LANGUAGE sql IMMUTABLE STRICT
+AS $$
+    SELECT
+        500000 + 6364902.16611274 * (
+            eta
+            + 0.0008377318229233356 * cos(2 * xi) * sinh(2 * eta)
+            + 7.608497033275728e-07 * cos(4 * xi) * sinh(4 * eta)
+            + 1.203487805324186e-09 * cos(6 * xi) * sinh(6 * eta)
+        ),
+        6364902.16611274 * (
+            xi
+            + 0.0008377318229233356 * sin(2 * xi) * cosh(2 * eta)
+            + 7.608497033275728e-07 * sin(4 * xi) * cosh(4 * eta)
+            + 1.203487805324186e-09 * sin(6 * xi) * cosh(6 * eta)
+        )
+    FROM (
+        SELECT atan2(t, cos(d_lambda)) AS xi, atanh(sin(d_lambda) / sqrt(1 + t * t)) AS eta
+        FROM (
+            SELECT
+                sinh(atanh(sin(radians(lat))) - 0.08181919104281579 * atanh(0.08181919104281579 * sin(radians(lat)))) AS t,
+                radians(lon - 27) AS d_lambda
+        ) conformal
+    ) gauss
+$$;
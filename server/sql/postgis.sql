@@ -0,0 +1,11 @@
+-- Run once by scripts/enable-postgis.sh, on a database that has PostGIS
+-- installed. Not a migration, plain Postgres has no PostGIS to create.
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- filled from latitude and longitude on every insert
+ALTER TABLE aircraft_positions ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_positions_geog ON aircraft_positions USING GIST (geog);
+-- boxes follow parallels and meridians, so they are tested as geometry
+CREATE INDEX IF NOT EXISTS idx_positions_geom ON aircraft_positions USING GIST ((geog::geometry));
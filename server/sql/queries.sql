@@ -2,23 +2,386 @@
 INSERT INTO aircraft_positions (
     icao24, callsign, origin_country, time_position,
     longitude, latitude, baro_altitude, on_ground,
-    velocity, heading, vertical_rate
+    velocity, heading, vertical_rate, category,
+    squawk, spi
 ) VALUES (
     $1, $2, $3, to_timestamp($4),
     $5, $6, $7, $8,
-    $9, $10, $11
+    $9, $10, $11, $12,
+    $13, $14
 );
 
 -- name: GetHeatmapDataDynamic :many
+-- filtered_positions applies the time window, bbox, filters and selectors
+-- the heatmap, tile, flow and timeseries queries share
 SELECT
-  id,
   (floor(latitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lat_bin,
   (floor(longitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lon_bin,
+  COUNT(*) AS count,
+  COUNT(DISTINCT icao24) AS aircraft,
+  -- bounded drill-down samples, newest fixes first
+  (array_agg(id ORDER BY time_position DESC))[1:sqlc.arg(sample_size)::int]::int[] AS sample_ids,
+  (array_agg(DISTINCT icao24 ORDER BY icao24))[1:sqlc.arg(sample_size)::int]::text[] AS sample_icao24s
+FROM filtered_positions(
+  sqlc.narg(from_time), sqlc.narg(to_time),
+  sqlc.narg(lat_min), sqlc.narg(lat_max), sqlc.narg(lon_min), sqlc.narg(lon_max),
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  sqlc.narg(hours), sqlc.arg(time_zone), sqlc.narg(weekdays)
+)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin;
+
+-- name: GetHeatmapValueBins :many
+-- counts and one metric per bin, without the drill-down samples. The other
+-- metrics' aggregates see no rows, so only the requested one does any work.
+SELECT
+  (floor(latitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lat_bin,
+  (floor(longitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lon_bin,
+  COUNT(*) AS count,
+  (CASE sqlc.arg(metric)::text
+    WHEN 'aircraft' THEN COUNT(DISTINCT icao24) FILTER (WHERE sqlc.arg(metric)::text = 'aircraft')
+    WHEN 'avg_altitude' THEN avg(baro_altitude) FILTER (WHERE sqlc.arg(metric)::text = 'avg_altitude')
+    WHEN 'min_altitude' THEN min(baro_altitude) FILTER (WHERE sqlc.arg(metric)::text = 'min_altitude')
+    WHEN 'max_altitude' THEN max(baro_altitude) FILTER (WHERE sqlc.arg(metric)::text = 'max_altitude')
+    WHEN 'avg_velocity' THEN avg(velocity) FILTER (WHERE sqlc.arg(metric)::text = 'avg_velocity')
+    WHEN 'median_vertical_rate' THEN percentile_cont(0.5) WITHIN GROUP (ORDER BY vertical_rate) FILTER (WHERE sqlc.arg(metric)::text = 'median_vertical_rate')
+  END)::float8 AS value
+FROM filtered_positions(
+  sqlc.narg(from_time), sqlc.narg(to_time),
+  sqlc.narg(lat_min), sqlc.narg(lat_max), sqlc.narg(lon_min), sqlc.narg(lon_max),
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  sqlc.narg(hours), sqlc.arg(time_zone), sqlc.narg(weekdays)
+)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin;
+
+-- name: GetMetricCells :many
+-- each fix projected to ETRS-TM35FIN and counted in the cell_size meter cell
+-- holding it, cells numbered from the false origin like geo.Grid
+SELECT
+  floor(p.easting / sqlc.arg(cell_size)::float8)::int AS cell_col,
+  floor(p.northing / sqlc.arg(cell_size)::float8)::int AS cell_row,
   COUNT(*) AS count
+FROM filtered_positions(
+  sqlc.narg(from_time), sqlc.narg(to_time),
+  sqlc.narg(lat_min), sqlc.narg(lat_max), sqlc.narg(lon_min), sqlc.narg(lon_max),
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  sqlc.narg(hours), sqlc.arg(time_zone), sqlc.narg(weekdays)
+) f
+CROSS JOIN LATERAL tm35fin(f.latitude, f.longitude) p
+WHERE f.latitude IS NOT NULL AND f.longitude IS NOT NULL
+GROUP BY cell_col, cell_row;
+
+-- name: GetNoiseFixes :many
+SELECT latitude, longitude, baro_altitude, category
 FROM aircraft_positions
-WHERE 
-  (@interval::text IS NULL OR time_position > now() - (@interval || ' minutes')::interval)
-GROUP BY id, lat_bin, lon_bin;
+WHERE
+  latitude IS NOT NULL AND longitude IS NOT NULL AND baro_altitude IS NOT NULL
+  AND (sqlc.narg(from_time)::timestamp IS NULL OR time_position >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamp IS NULL OR time_position < sqlc.narg(to_time));
 
 -- name: GetAircraftData :one
+-- the id alone can't prune partitions, each one's primary key is probed
 SELECT * FROM aircraft_positions WHERE id = $1;
+
+-- name: GetPositionsInBox :many
+SELECT id, icao24, callsign, time_position, latitude, longitude, baro_altitude, category
+FROM aircraft_positions
+WHERE
+  latitude BETWEEN @lat_min AND @lat_max
+  AND longitude BETWEEN @lon_min AND @lon_max
+  AND (sqlc.narg(from_time)::timestamp IS NULL OR time_position >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamp IS NULL OR time_position < sqlc.narg(to_time))
+ORDER BY icao24, time_position;
+
+-- name: CreateMonitoringPoint :one
+INSERT INTO monitoring_points (name, latitude, longitude, radius_m)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListMonitoringPoints :many
+SELECT * FROM monitoring_points ORDER BY id;
+
+-- name: GetMonitoringPoint :one
+SELECT * FROM monitoring_points WHERE id = $1;
+
+-- name: UpdateMonitoringPoint :one
+UPDATE monitoring_points
+SET name = $2, latitude = $3, longitude = $4, radius_m = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteMonitoringPoint :execrows
+DELETE FROM monitoring_points WHERE id = $1;
+
+-- name: CreateGeofence :one
+INSERT INTO geofences (name, geometry)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListGeofences :many
+SELECT * FROM geofences ORDER BY id;
+
+-- name: GetGeofence :one
+SELECT * FROM geofences WHERE id = $1;
+
+-- name: DeleteGeofence :execrows
+DELETE FROM geofences WHERE id = $1;
+
+-- name: InsertGeofenceEvent :exec
+INSERT INTO geofence_events (
+    geofence_id, icao24, callsign, event, time_position, baro_altitude
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ListGeofenceEvents :many
+SELECT * FROM geofence_events
+WHERE
+  geofence_id = @geofence_id
+  AND (sqlc.narg(from_time)::timestamp IS NULL OR time_position >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamp IS NULL OR time_position < sqlc.narg(to_time))
+ORDER BY time_position DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, events)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks ORDER BY id;
+
+-- name: ListActiveWebhooks :many
+SELECT * FROM webhooks WHERE active ORDER BY id;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: InsertWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+    webhook_id, delivery_id, event, attempt, status_code, error, duration_ms
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT 100;
+
+-- name: InsertWebhookDeadLetter :exec
+INSERT INTO webhook_dead_letters (
+    webhook_id, delivery_id, event, payload, attempts, last_error
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ListWebhookDeadLetters :many
+SELECT * FROM webhook_dead_letters
+WHERE webhook_id = $1
+ORDER BY id DESC;
+
+-- name: GetRecentTrack :many
+SELECT time_position, latitude, longitude, baro_altitude
+FROM aircraft_positions
+WHERE icao24 = @icao24 AND time_position >= @since
+ORDER BY time_position;
+
+-- name: InsertAlert :one
+INSERT INTO alerts (
+    type, icao24, callsign, squawk, time_position,
+    latitude, longitude, baro_altitude, track
+) VALUES (
+    $1, $2, $3, $4, $5,
+    $6, $7, $8, $9
+)
+RETURNING id;
+
+-- name: UpdateAlertTrack :exec
+UPDATE alerts SET track = $2 WHERE id = $1;
+
+-- name: ListAlerts :many
+SELECT * FROM alerts
+WHERE
+  (sqlc.narg(type)::text IS NULL OR type = sqlc.narg(type))
+  AND (sqlc.narg(from_time)::timestamp IS NULL OR time_position >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamp IS NULL OR time_position < sqlc.narg(to_time))
+ORDER BY time_position DESC
+LIMIT 200;
+
+-- name: GetTrack :many
+SELECT time_position, latitude, longitude, baro_altitude, velocity
+FROM aircraft_positions
+WHERE icao24 = @icao24
+  AND time_position >= @from_time
+  AND time_position < @to_time
+  AND latitude IS NOT NULL
+  AND longitude IS NOT NULL
+ORDER BY time_position;
+
+-- name: GetTileBins :many
+SELECT
+  (floor(latitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lat_bin,
+  (floor(longitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lon_bin,
+  COUNT(*) AS count
+FROM filtered_positions(
+  sqlc.narg(from_time), sqlc.narg(to_time),
+  @lat_min, @lat_max, @lon_min, @lon_max,
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  sqlc.narg(hours), sqlc.arg(time_zone), sqlc.narg(weekdays)
+)
+GROUP BY lat_bin, lon_bin;
+
+-- name: GetFlowBins :many
+SELECT
+  (floor(latitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lat_bin,
+  (floor(longitude * sqlc.arg(bin_size)) / sqlc.arg(bin_size))::float8 AS lon_bin,
+  COUNT(*) AS count,
+  COUNT(heading) AS headings,
+  -- headings are summed as unit vectors, the mean is taken from those
+  sum(sin(radians(heading)))::float8 AS sin_sum,
+  sum(cos(radians(heading)))::float8 AS cos_sum,
+  avg(velocity)::float8 AS avg_velocity
+FROM filtered_positions(
+  @from_time, @to_time,
+  sqlc.narg(lat_min), sqlc.narg(lat_max), sqlc.narg(lon_min), sqlc.narg(lon_max),
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  NULL, NULL, NULL
+)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+GROUP BY lat_bin, lon_bin;
+
+-- name: GetTimeseries :many
+SELECT
+  date_bin(sqlc.arg(bucket_seconds)::int * interval '1 second', time_position, TIMESTAMP '2000-01-01')::timestamp AS bucket,
+  COUNT(*) AS fixes,
+  COUNT(DISTINCT icao24) AS aircraft,
+  COUNT(DISTINCT callsign) AS callsigns
+FROM filtered_positions(
+  @from_time, @to_time,
+  sqlc.narg(lat_min), sqlc.narg(lat_max), sqlc.narg(lon_min), sqlc.narg(lon_max),
+  sqlc.narg(alt_min), sqlc.narg(alt_max), sqlc.narg(velocity_min), sqlc.narg(velocity_max),
+  sqlc.narg(vertical_sign), sqlc.narg(callsign_prefix), sqlc.narg(icao24_list), sqlc.narg(origin_country),
+  NULL, NULL, NULL
+)
+GROUP BY bucket
+ORDER BY bucket;
+
+-- name: GetWeeklyMatrix :many
+SELECT
+  extract(isodow FROM timezone(sqlc.arg(time_zone)::text, time_position AT TIME ZONE 'UTC'))::int AS weekday,
+  extract(hour FROM timezone(sqlc.arg(time_zone)::text, time_position AT TIME ZONE 'UTC'))::int AS hour,
+  COUNT(*) AS count
+FROM aircraft_positions
+WHERE
+  (sqlc.narg(from_time)::timestamp IS NULL OR time_position >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamp IS NULL OR time_position < sqlc.narg(to_time))
+GROUP BY weekday, hour;
+
+-- name: GetOldestPositionDay :one
+SELECT date_trunc('day', time_position)::timestamp AS oldest
+FROM aircraft_positions
+ORDER BY time_position
+LIMIT 1;
+
+-- name: GetRollupWatermark :one
+SELECT rolled_until FROM rollup_state WHERE name = 'heatmap';
+
+-- name: SetRollupWatermark :exec
+INSERT INTO rollup_state (name, rolled_until) VALUES ('heatmap', $1)
+ON CONFLICT (name) DO UPDATE SET rolled_until = EXCLUDED.rolled_until;
+
+-- name: RollupHours :exec
+INSERT INTO heatmap_rollups (bin_size, granularity, bucket, lat_bin, lon_bin, count)
+SELECT
+  sqlc.arg(bin_size)::int,
+  'hour',
+  date_trunc('hour', time_position) AS bucket,
+  -- same expressions as GetHeatmapDataDynamic so bins line up with raw data
+  floor(latitude * sqlc.arg(bin_size)::float8) / sqlc.arg(bin_size)::float8 AS lat_bin,
+  floor(longitude * sqlc.arg(bin_size)::float8) / sqlc.arg(bin_size)::float8 AS lon_bin,
+  COUNT(*)
+FROM aircraft_positions
+WHERE
+  latitude IS NOT NULL AND longitude IS NOT NULL
+  AND time_position >= @from_time AND time_position < @to_time
+GROUP BY bucket, lat_bin, lon_bin
+ON CONFLICT (bin_size, granularity, bucket, lat_bin, lon_bin) DO UPDATE SET count = EXCLUDED.count;
+
+-- name: RollupDays :exec
+INSERT INTO heatmap_rollups (bin_size, granularity, bucket, lat_bin, lon_bin, count)
+SELECT bin_size, 'day', date_trunc('day', bucket) AS day, lat_bin, lon_bin, SUM(count)
+FROM heatmap_rollups
+WHERE granularity = 'hour' AND bucket >= @from_time AND bucket < @to_time
+GROUP BY bin_size, day, lat_bin, lon_bin
+ON CONFLICT (bin_size, granularity, bucket, lat_bin, lon_bin) DO UPDATE SET count = EXCLUDED.count;
+
+-- name: GetRollupBins :many
+SELECT lat_bin, lon_bin, SUM(count)::bigint AS count
+FROM heatmap_rollups
+WHERE
+  bin_size = @bin_size
+  AND (
+    (granularity = 'day' AND bucket >= @day_from AND bucket < @day_to)
+    -- hours at either end that don't fill a whole day
+    OR (
+      granularity = 'hour' AND bucket >= @from_time AND bucket < @to_time
+      AND NOT (bucket >= @day_from AND bucket < @day_to)
+    )
+  )
+  -- the box is snapped to whole bins, the same rule as for raw fixes
+  AND (sqlc.narg(lat_min)::float8 IS NULL OR (lat_bin >= sqlc.narg(lat_min) AND lat_bin < sqlc.narg(lat_max)))
+  AND (
+    sqlc.narg(lon_min)::float8 IS NULL
+    OR (sqlc.narg(lon_min)::float8 <= sqlc.narg(lon_max)::float8 AND lon_bin >= sqlc.narg(lon_min) AND lon_bin < sqlc.narg(lon_max))
+    -- the box crosses the antimeridian
+    OR (sqlc.narg(lon_min)::float8 > sqlc.narg(lon_max)::float8 AND (lon_bin >= sqlc.narg(lon_min) OR lon_bin < sqlc.narg(lon_max)))
+  )
+GROUP BY lat_bin, lon_bin;
+
+-- name: GetThinnedUntil :one
+SELECT rolled_until FROM rollup_state WHERE name = 'thinned';
+
+-- name: SetThinnedUntil :exec
+INSERT INTO rollup_state (name, rolled_until) VALUES ('thinned', $1)
+ON CONFLICT (name) DO UPDATE SET rolled_until = EXCLUDED.rolled_until;
+
+-- name: ThinPositions :execrows
+DELETE FROM aircraft_positions
+WHERE
+  -- the time range prunes the partitions the delete touches
+  time_position >= @from_time AND time_position < @to_time
+  AND (id, time_position) IN (
+    SELECT id, time_position FROM (
+      SELECT
+        id,
+        time_position,
+        row_number() OVER (
+          PARTITION BY icao24, date_bin(sqlc.arg(thin_seconds)::int * interval '1 second', time_position, TIMESTAMP '2000-01-01')
+          ORDER BY time_position, id
+        ) AS n
+      FROM aircraft_positions
+      WHERE time_position >= @from_time AND time_position < @to_time
+    ) ranked
+    -- keep the first fix of each aircraft per interval
+    WHERE n > 1
+    LIMIT @batch_size
+  );
+
+-- name: ListPositionPartitions :many
+SELECT c.relname::text AS name
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'aircraft_positions'::regclass
+ORDER BY c.relname;
+
+-- name: CountPositionsInRange :one
+SELECT COUNT(*) FROM aircraft_positions
+WHERE time_position >= sqlc.arg(from_time) AND time_position < sqlc.arg(to_time);